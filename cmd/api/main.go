@@ -1,10 +1,39 @@
 package main
 
 import (
+	"fmt"
+	"os"
+
 	"github.com/LaQuannT/astronaut-data-api/internal/app"
 	_ "github.com/joho/godotenv/autoload"
 )
 
+const usage = `usage: astronaut-data-api [command]
+
+commands:
+  serve     run the api server (default)
+  backup    dump the database to an archive (-o file)
+  restore   load an archive into an empty database (-i file)
+  bootstrap create the first admin (-email, -first-name, -surname), the
+            password is read from ADMIN_PASSWORD or stdin`
+
 func main() {
-	app.Run()
+	if len(os.Args) < 2 {
+		app.Run()
+		return
+	}
+
+	switch os.Args[1] {
+	case "serve":
+		app.Run()
+	case "backup":
+		app.Backup(os.Args[2:])
+	case "restore":
+		app.Restore(os.Args[2:])
+	case "bootstrap":
+		app.Bootstrap(os.Args[2:])
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
 }
@@ -2,12 +2,17 @@ package app
 
 import (
 	"context"
+	"crypto/rand"
 	"fmt"
 	"log/slog"
 	"os"
+	"time"
 
+	"github.com/LaQuannT/astronaut-data-api/internal/auth"
 	"github.com/LaQuannT/astronaut-data-api/internal/config"
 	"github.com/LaQuannT/astronaut-data-api/internal/database"
+	"github.com/LaQuannT/astronaut-data-api/internal/mail"
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
 	"github.com/LaQuannT/astronaut-data-api/internal/store"
 	"github.com/LaQuannT/astronaut-data-api/internal/transport"
 )
@@ -26,9 +31,87 @@ func Run() {
 
 	us := store.NewUserStore(dbPool)
 	as := store.NewAstronautStore(dbPool)
+	is := store.NewIdempotencyStore(dbPool)
+	ts := store.NewRefreshTokenStore(dbPool)
+	rs := store.NewRoleStore(dbPool)
+
+	secret := []byte(env.JWTSecret)
+	if len(secret) == 0 {
+		if env.Stage == "production" {
+			logger.Log(context.Background(), config.LevelTrace, "JWT_SECRET must be set in production")
+			os.Exit(1)
+		}
+
+		// tokens signed with a random secret stop verifying on restart
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			logger.Log(context.Background(), config.LevelTrace, "failed generating jwt secret", slog.Any("error", err))
+			os.Exit(1)
+		}
+		logger.Warn("JWT_SECRET is not set, using a random secret")
+	}
+	signer := auth.NewSigner(secret, "astronaut-data-api", env.AccessTokenTTL)
+
+	var ls model.RateLimitStore
+	switch env.RateLimitStore {
+	case "memory":
+		ls = store.NewMemoryRateLimitStore()
+	case "postgres":
+		ls = store.NewRateLimitStore(dbPool)
+	case "off":
+	default:
+		logger.Log(context.Background(), config.LevelTrace, "RATE_LIMIT_STORE must be memory, postgres or off", slog.String("value", env.RateLimitStore))
+		os.Exit(1)
+	}
+
+	limits := model.RateLimits{
+		Default:   model.RateLimit{Requests: env.RateLimit, Per: time.Minute},
+		Anonymous: model.RateLimit{Requests: env.RateLimitAnonymous, Per: time.Minute},
+		Roles:     make(map[string]model.RateLimit),
+	}
+	for role, n := range env.RateLimitRoles {
+		limits.Roles[role] = model.RateLimit{Requests: n, Per: time.Minute}
+	}
+
+	var mailer model.Mailer
+	switch env.Mailer {
+	case "smtp":
+		mailer = mail.NewSMTPMailer(env.SMTPHost, env.SMTPPort, env.SMTPUsername, env.SMTPPassword, env.MailFrom)
+	case "file":
+		file, err := os.OpenFile(env.MailFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+		if err != nil {
+			logger.Log(context.Background(), config.LevelTrace, "unable to open mail file", slog.Any("error", err))
+			os.Exit(1)
+		}
+		defer file.Close()
+		mailer = mail.NewFileMailer(file, env.MailFrom)
+	case "log":
+		// the log holds reset tokens, fine locally but not in production
+		if env.Stage == "production" {
+			logger.Warn("MAILER is log, account mails are logged instead of sent")
+		}
+		mailer = mail.NewLogMailer(logger)
+	default:
+		logger.Log(context.Background(), config.LevelTrace, "MAILER must be smtp, file or log", slog.String("value", env.Mailer))
+		os.Exit(1)
+	}
+
+	quotas := model.Quotas{Default: int64(env.Quota), Roles: make(map[string]int64)}
+	for role, n := range env.QuotaRoles {
+		quotas.Roles[role] = int64(n)
+	}
 
 	addr := fmt.Sprintf(":%s", env.Port)
 
-	s := transport.NewServer(addr, us, as, logger)
+	s := transport.NewServer(addr, us, as, rs, logger).
+		WithIdempotency(is, env.IdempotencyTTL).
+		WithAuth(ts, signer, env.RefreshTokenTTL).
+		WithUsage(store.NewUsageStore(dbPool), quotas).
+		WithLockout(store.NewLockoutStore(dbPool)).
+		WithAccounts(store.NewUserTokenStore(dbPool), mailer, env.PublicURL, env.PasswordResetTTL, env.EmailVerificationTTL).
+		WithInvitations(store.NewInvitationStore(dbPool), env.InvitationTTL)
+	if ls != nil {
+		s = s.WithRateLimit(ls, limits)
+	}
 	s.Serve()
 }
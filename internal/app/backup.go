@@ -0,0 +1,91 @@
+package app
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/config"
+	"github.com/LaQuannT/astronaut-data-api/internal/database"
+)
+
+// Backup dumps the database into the archive named by the -o flag
+func Backup(args []string) {
+	fs := flag.NewFlagSet("backup", flag.ExitOnError)
+	out := fs.String("o", "backup.json.gz", "archive file to write")
+	fs.Parse(args)
+
+	env := config.Init()
+	logger := config.InitLogger(os.Stderr, env.Stage)
+	ctx := context.Background()
+
+	db := database.NewPostgresDB(env.BuildDBConnStr(), logger)
+
+	err := replaceFile(*out, func(w io.Writer) error {
+		return db.Backup(ctx, w)
+	})
+	if err != nil {
+		logger.Log(ctx, config.LevelTrace, "failed database backup", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	logger.Info(fmt.Sprintf("Backup written to '%s'", *out))
+}
+
+// replaceFile has write fill a temporary file next to name and only moves it
+// over name once it is complete, so a failed backup keeps the last archive
+func replaceFile(name string, write func(io.Writer) error) error {
+	file, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".*")
+	if err != nil {
+		return fmt.Errorf("unable to create archive file: %w", err)
+	}
+	// after the rename there is nothing left to remove
+	defer os.Remove(file.Name())
+
+	if err := write(file); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return fmt.Errorf("unable to flush archive file: %w", err)
+	}
+
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("unable to close archive file: %w", err)
+	}
+
+	return os.Rename(file.Name(), name)
+}
+
+// Restore loads the archive named by the -i flag into an empty database
+func Restore(args []string) {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	in := fs.String("i", "backup.json.gz", "archive file to read")
+	fs.Parse(args)
+
+	env := config.Init()
+	logger := config.InitLogger(os.Stderr, env.Stage)
+	ctx := context.Background()
+
+	db := database.NewPostgresDB(env.BuildDBConnStr(), logger)
+
+	file, err := os.Open(*in)
+	if err != nil {
+		logger.Log(ctx, config.LevelTrace, "unable to open archive file", slog.Any("error", err))
+		os.Exit(1)
+	}
+	defer file.Close()
+
+	if err := db.Restore(ctx, file); err != nil {
+		logger.Log(ctx, config.LevelTrace, "failed database restore", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	logger.Info(fmt.Sprintf("Backup restored from '%s'", *in))
+}
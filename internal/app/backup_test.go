@@ -0,0 +1,56 @@
+package app
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReplaceFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "backup.json.gz")
+	if err := os.WriteFile(name, []byte("last good archive"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	read := func(t *testing.T) string {
+		t.Helper()
+		b, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+
+	t.Run("failed write keeps the file", func(t *testing.T) {
+		err := replaceFile(name, func(w io.Writer) error {
+			io.WriteString(w, "partial")
+			return errors.New("database unavailable")
+		})
+		if err == nil {
+			t.Fatal("expected the write error")
+		}
+
+		if got := read(t); got != "last good archive" {
+			t.Fatalf("expected the last archive to be kept, got %q", got)
+		}
+		if entries, _ := os.ReadDir(filepath.Dir(name)); len(entries) != 1 {
+			t.Fatalf("expected the temporary file to be removed, got %d files", len(entries))
+		}
+	})
+
+	t.Run("complete write replaces the file", func(t *testing.T) {
+		err := replaceFile(name, func(w io.Writer) error {
+			_, err := io.WriteString(w, "new archive")
+			return err
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if got := read(t); got != "new archive" {
+			t.Fatalf("expected the new archive, got %q", got)
+		}
+	})
+}
@@ -0,0 +1,58 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/config"
+	"github.com/LaQuannT/astronaut-data-api/internal/database"
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+	usecase "github.com/LaQuannT/astronaut-data-api/internal/service"
+	"github.com/LaQuannT/astronaut-data-api/internal/store"
+)
+
+// Bootstrap creates the first admin and prints their API key, it refuses to
+// run once any admin exists. Only the key's digest is stored, so the printed
+// key is shown only once. The password comes from ADMIN_PASSWORD or the
+// first line of stdin, so it stays out of the shell history.
+func Bootstrap(args []string) {
+	fs := flag.NewFlagSet("bootstrap", flag.ExitOnError)
+	email := fs.String("email", "", "email of the admin")
+	firstName := fs.String("first-name", "", "first name of the admin")
+	surname := fs.String("surname", "", "surname of the admin")
+	fs.Parse(args)
+
+	env := config.Init()
+	logger := config.InitLogger(os.Stderr, env.Stage)
+	ctx := context.Background()
+
+	password, ok := os.LookupEnv("ADMIN_PASSWORD")
+	if !ok {
+		fmt.Fprint(os.Stderr, "password: ")
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			logger.Log(ctx, config.LevelTrace, "unable to read admin password", slog.Any("error", err))
+			os.Exit(1)
+		}
+		password = strings.TrimRight(line, "\r\n")
+	}
+
+	db := database.NewPostgresDB(env.BuildDBConnStr(), logger)
+	pool := db.Pool()
+
+	us := usecase.NewUserUsecase(store.NewUserStore(pool), store.NewRoleStore(pool), nil, nil)
+
+	u, err := us.Bootstrap(ctx, &model.User{FirstName: *firstName, Surename: *surname, Email: *email, Password: password})
+	if err != nil {
+		logger.Log(ctx, config.LevelTrace, "failed admin bootstrap", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	logger.Info(fmt.Sprintf("Admin '%s' created with id %d", u.Email, u.ID))
+	fmt.Printf("api key: %s\n", u.ApiKey)
+}
@@ -0,0 +1,108 @@
+// Package auth signs and verifies the HS256 JSON Web Tokens used as access
+// tokens.
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	ErrMalformedToken = errors.New("token is malformed")
+	ErrInvalidToken   = errors.New("token signature is invalid")
+	ErrExpiredToken   = errors.New("token has expired")
+)
+
+// header is the only JOSE header issued and accepted
+var header = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// Claims are the registered claims used plus the user's role
+type Claims struct {
+	Issuer    string `json:"iss"`
+	Subject   string `json:"sub"`
+	Role      string `json:"role"`
+	ID        string `json:"jti"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// Signer issues access tokens valid for ttl
+type Signer struct {
+	secret []byte
+	issuer string
+	ttl    time.Duration
+	now    func() time.Time
+}
+
+func NewSigner(secret []byte, issuer string, ttl time.Duration) *Signer {
+	return &Signer{
+		secret: secret,
+		issuer: issuer,
+		ttl:    ttl,
+		now:    time.Now,
+	}
+}
+
+// TTL is how long issued tokens are valid
+func (s *Signer) TTL() time.Duration {
+	return s.ttl
+}
+
+// Sign fills in the issuer and validity of c and returns the signed token
+func (s *Signer) Sign(c Claims) (string, error) {
+	now := s.now()
+	c.Issuer = s.issuer
+	c.IssuedAt = now.Unix()
+	c.ExpiresAt = now.Add(s.ttl).Unix()
+
+	payload, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := header + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return unsigned + "." + s.signature(unsigned), nil
+}
+
+// Verify checks the signature, header, issuer and expiry of token
+func (s *Signer) Verify(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != header {
+		return nil, ErrMalformedToken
+	}
+
+	unsigned := parts[0] + "." + parts[1]
+	if !hmac.Equal([]byte(parts[2]), []byte(s.signature(unsigned))) {
+		return nil, ErrInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrMalformedToken
+	}
+
+	c := new(Claims)
+	if err := json.Unmarshal(payload, c); err != nil {
+		return nil, ErrMalformedToken
+	}
+
+	if c.Issuer != s.issuer {
+		return nil, ErrInvalidToken
+	}
+	if s.now().Unix() >= c.ExpiresAt {
+		return nil, ErrExpiredToken
+	}
+
+	return c, nil
+}
+
+func (s *Signer) signature(unsigned string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(unsigned))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,42 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSigner(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	s := NewSigner([]byte("secret"), "astronaut-data-api", time.Minute)
+	s.now = func() time.Time { return now }
+
+	token, err := s.Sign(Claims{Subject: "7", Role: "admin", ID: "abc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := s.Verify(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Subject != "7" || c.Role != "admin" || c.ExpiresAt != now.Add(time.Minute).Unix() {
+		t.Errorf("claims = %+v", c)
+	}
+
+	other := NewSigner([]byte("other"), "astronaut-data-api", time.Minute)
+	if _, err := other.Verify(token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("wrong secret error = %v, want %v", err, ErrInvalidToken)
+	}
+
+	parts := strings.Split(token, ".")
+	if _, err := s.Verify("eyJhbGciOiJub25lIn0." + parts[1] + "."); !errors.Is(err, ErrMalformedToken) {
+		t.Errorf("alg none error = %v, want %v", err, ErrMalformedToken)
+	}
+
+	now = now.Add(time.Minute)
+	if _, err := s.Verify(token); !errors.Is(err, ErrExpiredToken) {
+		t.Errorf("expired error = %v, want %v", err, ErrExpiredToken)
+	}
+}
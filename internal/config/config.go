@@ -5,6 +5,9 @@ import (
 	"io"
 	"log/slog"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 const LevelTrace = slog.Level(12)
@@ -19,6 +22,31 @@ type config struct {
 	SSLMode   string
 	JWTSecret string
 	Stage     string
+	// IdempotencyTTL is how long responses to Idempotency-Key requests are kept
+	IdempotencyTTL  time.Duration
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	// rate limits are in requests per minute, RateLimitRoles overrides
+	// RateLimit for the named roles
+	RateLimit          int
+	RateLimitAnonymous int
+	RateLimitRoles     map[string]int
+	RateLimitStore     string
+	// quotas are in requests per calendar month, 0 is unlimited
+	Quota      int
+	QuotaRoles map[string]int
+	// Mailer is smtp, file or log, PublicURL is where mails point clients
+	Mailer               string
+	MailFrom             string
+	MailFile             string
+	SMTPHost             string
+	SMTPPort             string
+	SMTPUsername         string
+	SMTPPassword         string
+	PublicURL            string
+	PasswordResetTTL     time.Duration
+	EmailVerificationTTL time.Duration
+	InvitationTTL        time.Duration
 }
 
 func (c *config) BuildDBConnStr() string {
@@ -36,6 +64,31 @@ func Init() config {
 		DBName:   getEnv("PG_DATABASE", "testDB"),
 		SSLMode:  getEnv("PG_SSLMODE", "disable"),
 		Stage:    getEnv("APP_ENV", "development"),
+
+		JWTSecret:       getEnv("JWT_SECRET", ""),
+		IdempotencyTTL:  getDuration("IDEMPOTENCY_TTL", 24*time.Hour),
+		AccessTokenTTL:  getDuration("ACCESS_TOKEN_TTL", 15*time.Minute),
+		RefreshTokenTTL: getDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour),
+
+		RateLimit:          getInt("RATE_LIMIT", 60),
+		RateLimitAnonymous: getInt("RATE_LIMIT_ANONYMOUS", 10),
+		RateLimitRoles:     getIntMap("RATE_LIMIT_ROLES", map[string]int{"admin": 600}),
+		RateLimitStore:     getEnv("RATE_LIMIT_STORE", "memory"),
+
+		Quota:      getInt("QUOTA", 0),
+		QuotaRoles: getIntMap("QUOTA_ROLES", nil),
+
+		Mailer:               getEnv("MAILER", "log"),
+		MailFrom:             getEnv("MAIL_FROM", "no-reply@localhost"),
+		MailFile:             getEnv("MAIL_FILE", "mail.log"),
+		SMTPHost:             getEnv("SMTP_HOST", "localhost"),
+		SMTPPort:             getEnv("SMTP_PORT", "587"),
+		SMTPUsername:         getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:         getEnv("SMTP_PASSWORD", ""),
+		PublicURL:            strings.TrimSuffix(getEnv("PUBLIC_URL", "http://localhost:8080"), "/"),
+		PasswordResetTTL:     getDuration("PASSWORD_RESET_TTL", time.Hour),
+		EmailVerificationTTL: getDuration("EMAIL_VERIFICATION_TTL", 48*time.Hour),
+		InvitationTTL:        getDuration("INVITATION_TTL", 7*24*time.Hour),
 	}
 }
 
@@ -48,6 +101,49 @@ func getEnv(key, fallback string) string {
 	return value
 }
 
+// getDuration parses a Go duration such as "24h", falling back on a missing
+// or malformed value
+func getDuration(key string, fallback time.Duration) time.Duration {
+	d, err := time.ParseDuration(getEnv(key, ""))
+	if err != nil || d <= 0 {
+		return fallback
+	}
+
+	return d
+}
+
+// getInt parses a positive integer, falling back on a missing or malformed
+// value
+func getInt(key string, fallback int) int {
+	n, err := strconv.Atoi(getEnv(key, ""))
+	if err != nil || n <= 0 {
+		return fallback
+	}
+
+	return n
+}
+
+// getIntMap parses comma separated name=value pairs such as
+// "admin=600,editor=120", malformed pairs are skipped
+func getIntMap(key string, fallback map[string]int) map[string]int {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+
+	m := make(map[string]int)
+	for _, pair := range strings.Split(value, ",") {
+		name, v, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		n, err := strconv.Atoi(v)
+		if !ok || name == "" || err != nil || n <= 0 {
+			continue
+		}
+		m[name] = n
+	}
+
+	return m
+}
+
 func InitLogger(w io.Writer, stage string) *slog.Logger {
 	levelNames := map[slog.Leveler]string{
 		LevelTrace: "FATAL",
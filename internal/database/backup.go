@@ -0,0 +1,258 @@
+package database
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// backupFormatVersion 2 checksums the whole archive, not only its tables
+const backupFormatVersion = 2
+
+type (
+	backupTable struct {
+		name  string
+		order string
+		// serial column whose sequence is moved past the restored rows
+		serial string
+		// seeded tables are filled by migrations, the archive replaces their
+		// rows instead of requiring them to be empty
+		seeded bool
+	}
+
+	Archive struct {
+		FormatVersion int         `json:"formatVersion"`
+		SchemaVersion int         `json:"schemaVersion"`
+		CreatedAt     time.Time   `json:"createdAt"`
+		Checksum      string      `json:"checksum"`
+		Tables        []TableDump `json:"tables"`
+	}
+
+	TableDump struct {
+		Name string            `json:"name"`
+		Rows []json.RawMessage `json:"rows"`
+	}
+)
+
+// backupTables are dumped and restored in this order so foreign keys resolve,
+// idempotency_key, refresh_token, user_token, invitation, rate_limit_bucket
+// and auth_failure only hold short lived state and are left out, restoring
+// users drops their sessions, mailed tokens and pending invitations
+var backupTables = []backupTable{
+	{name: "role", order: "name", seeded: true},
+	{name: `"user"`, order: "id", serial: "id"},
+	{name: "api_key", order: "id", serial: "id"},
+	{name: "usage", order: "user_id, day, endpoint"},
+	{name: "security_event", order: "id", serial: "id"},
+	{name: "astronaut", order: "id", serial: "id"},
+	{name: "astronaut_history", order: "id", serial: "id"},
+	{name: "astronaut_redirect", order: "old_id"},
+}
+
+// Backup writes every application table, row for row, into a gzip compressed
+// and checksummed archive tied to the current schema version
+func (p *PostgresDB) Backup(ctx context.Context, w io.Writer) error {
+	version, err := schemaVersion(ctx, p.db)
+	if err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	tx, err := p.db.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	archive := &Archive{
+		FormatVersion: backupFormatVersion,
+		SchemaVersion: version,
+		CreatedAt:     time.Now().UTC(),
+		Tables:        make([]TableDump, 0, len(backupTables)),
+	}
+
+	for _, t := range backupTables {
+		dump, err := dumpTable(ctx, tx, t)
+		if err != nil {
+			return fmt.Errorf("failed to dump table %s: %w", t.name, err)
+		}
+		archive.Tables = append(archive.Tables, dump)
+	}
+
+	archive.Checksum, err = checksum(archive)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(w)
+	if err := json.NewEncoder(gz).Encode(archive); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+
+	return gz.Close()
+}
+
+// Restore loads an archive into an empty database inside a single transaction,
+// the archive, the database and the shipped migrations must share a schema version
+func (p *PostgresDB) Restore(ctx context.Context, r io.Reader) error {
+	archive, err := ReadArchive(r)
+	if err != nil {
+		return err
+	}
+
+	latest, err := LatestMigrationVersion()
+	if err != nil {
+		return err
+	}
+
+	current, err := schemaVersion(ctx, p.db)
+	if err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	if archive.SchemaVersion != latest || current != latest {
+		return fmt.Errorf("schema version mismatch: archive %d, database %d, migrations %d",
+			archive.SchemaVersion, current, latest)
+	}
+
+	dumps := make(map[string]TableDump, len(archive.Tables))
+	for _, d := range archive.Tables {
+		dumps[d.Name] = d
+	}
+
+	tx, err := p.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	for _, t := range backupTables {
+		if t.seeded {
+			continue
+		}
+
+		var exists bool
+		if err := tx.QueryRow(ctx, fmt.Sprintf(`SELECT EXISTS (SELECT 1 FROM %s);`, t.name)).Scan(&exists); err != nil {
+			return err
+		}
+		if exists {
+			return fmt.Errorf("table %s is not empty", t.name)
+		}
+	}
+
+	for _, t := range backupTables {
+		d, ok := dumps[t.name]
+		if !ok {
+			return fmt.Errorf("archive is missing table %s", t.name)
+		}
+
+		if err := restoreTable(ctx, tx, t, d); err != nil {
+			return fmt.Errorf("failed to restore table %s: %w", t.name, err)
+		}
+		p.logger.Info("restored table", "table", t.name, "rows", len(d.Rows))
+	}
+
+	return tx.Commit(ctx)
+}
+
+// ReadArchive decodes an archive and verifies its format and checksum
+func ReadArchive(r io.Reader) (*Archive, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open archive: %w", err)
+	}
+	defer gz.Close()
+
+	archive := new(Archive)
+	if err := json.NewDecoder(gz).Decode(archive); err != nil {
+		return nil, fmt.Errorf("unable to decode archive: %w", err)
+	}
+
+	if archive.FormatVersion != backupFormatVersion {
+		return nil, fmt.Errorf("unsupported archive format version %d", archive.FormatVersion)
+	}
+
+	sum, err := checksum(archive)
+	if err != nil {
+		return nil, err
+	}
+
+	if sum != archive.Checksum {
+		return nil, errors.New("archive checksum mismatch")
+	}
+
+	return archive, nil
+}
+
+func dumpTable(ctx context.Context, tx pgx.Tx, t backupTable) (TableDump, error) {
+	dump := TableDump{Name: t.name, Rows: make([]json.RawMessage, 0)}
+
+	query := fmt.Sprintf(`SELECT row_to_json(t) FROM %s t ORDER BY %s;`, t.name, t.order)
+	rows, err := tx.Query(ctx, query)
+	if err != nil {
+		return dump, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var row []byte
+		if err := rows.Scan(&row); err != nil {
+			return dump, err
+		}
+		dump.Rows = append(dump.Rows, row)
+	}
+
+	return dump, rows.Err()
+}
+
+func restoreTable(ctx context.Context, tx pgx.Tx, t backupTable, d TableDump) error {
+	// drop rows written by triggers while restoring earlier tables, the
+	// archive holds the originals
+	if _, err := tx.Exec(ctx, fmt.Sprintf(`DELETE FROM %s;`, t.name)); err != nil {
+		return err
+	}
+
+	if len(d.Rows) == 0 {
+		return nil
+	}
+
+	rows, err := json.Marshal(d.Rows)
+	if err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf(`INSERT INTO %[1]s SELECT * FROM json_populate_recordset(NULL::%[1]s, $1);`, t.name)
+	if _, err := tx.Exec(ctx, query, string(rows)); err != nil {
+		return err
+	}
+
+	if t.serial == "" {
+		return nil
+	}
+
+	query = fmt.Sprintf(`SELECT setval(pg_get_serial_sequence('%[1]s', '%[2]s'), MAX(%[2]s)) FROM %[1]s;`, t.name, t.serial)
+	_, err = tx.Exec(ctx, query)
+	return err
+}
+
+// checksum covers every field of the archive but the checksum itself, so the
+// schema version Restore relies on is verified along with the rows
+func checksum(a *Archive) (string, error) {
+	c := *a
+	c.Checksum = ""
+
+	b, err := json.Marshal(&c)
+	if err != nil {
+		return "", fmt.Errorf("failed to compute archive checksum: %w", err)
+	}
+
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
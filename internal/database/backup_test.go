@@ -0,0 +1,77 @@
+package database
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func writeArchive(t *testing.T, a *Archive) *bytes.Buffer {
+	t.Helper()
+
+	buf := new(bytes.Buffer)
+	gz := gzip.NewWriter(buf)
+	if err := json.NewEncoder(gz).Encode(a); err != nil {
+		t.Fatalf("unexpected error encoding archive: %v", err)
+	}
+	gz.Close()
+
+	return buf
+}
+
+func TestReadArchive(t *testing.T) {
+	newArchive := func(t *testing.T) *Archive {
+		t.Helper()
+
+		a := &Archive{
+			FormatVersion: backupFormatVersion,
+			SchemaVersion: 2,
+			CreatedAt:     time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+			Tables:        []TableDump{{Name: "astronaut", Rows: []json.RawMessage{json.RawMessage(`{"id":1,"name":"loren w. acton"}`)}}},
+		}
+
+		sum, err := checksum(a)
+		if err != nil {
+			t.Fatalf("unexpected error computing checksum: %v", err)
+		}
+		a.Checksum = sum
+		return a
+	}
+
+	t.Run("accepts an intact archive", func(t *testing.T) {
+		if _, err := ReadArchive(writeArchive(t, newArchive(t))); err != nil {
+			t.Fatalf("unexpected error reading archive: %v", err)
+		}
+	})
+
+	t.Run("rejects a tampered archive", func(t *testing.T) {
+		a := newArchive(t)
+		a.Tables = []TableDump{{Name: "astronaut", Rows: []json.RawMessage{json.RawMessage(`{"id":1,"name":"someone else"}`)}}}
+
+		if _, err := ReadArchive(writeArchive(t, a)); err == nil {
+			t.Fatal("expected checksum error reading tampered archive")
+		}
+	})
+
+	t.Run("rejects a changed schema version", func(t *testing.T) {
+		a := newArchive(t)
+		a.SchemaVersion = 3
+
+		if _, err := ReadArchive(writeArchive(t, a)); err == nil {
+			t.Fatal("expected checksum error reading archive with a changed schema version")
+		}
+	})
+}
+
+func TestLatestMigrationVersion(t *testing.T) {
+	v, err := LatestMigrationVersion()
+	if err != nil {
+		t.Fatalf("unexpected error reading migrations: %v", err)
+	}
+
+	if v < 2 {
+		t.Fatalf("expected at least migration version 2, got %d", v)
+	}
+}
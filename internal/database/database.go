@@ -5,12 +5,10 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
-	"strings"
 	"time"
 
 	"github.com/LaQuannT/astronaut-data-api/internal/config"
-	"github.com/LaQuannT/astronaut-data-api/internal/model"
-	"github.com/gocarina/gocsv"
+	"github.com/LaQuannT/astronaut-data-api/internal/dataset"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/lib/pq"
 )
@@ -45,6 +43,12 @@ func NewPostgresDB(connStr string, l *slog.Logger) *PostgresDB {
 	}
 }
 
+// Pool returns the connection pool without seeding, for commands that only
+// touch users
+func (p *PostgresDB) Pool() *pgxpool.Pool {
+	return p.db
+}
+
 func (p *PostgresDB) Init() (*pgxpool.Pool, error) {
 	count, err := p.checkAstronautCount()
 	if err != nil {
@@ -88,10 +92,9 @@ func (p *PostgresDB) populateAstronautTable() error {
 	}
 	defer file.Close()
 
-	var astronauts []*model.Astronaut
-
-	if err := gocsv.UnmarshalFile(file, &astronauts); err != nil {
-		return fmt.Errorf("unable to unmarshal CSV data to stuct slice: %w", err)
+	astronauts, err := dataset.Load(file)
+	if err != nil {
+		return err
 	}
 
 	query := `
@@ -102,13 +105,6 @@ func (p *PostgresDB) populateAstronautTable() error {
   VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19);`
 
 	for _, a := range astronauts {
-		formatStrsToLower(a)
-
-		a.AlmaMater = strings.Split(a.AlmaMaterStr, ";")
-		a.UndergraduateMajor = strings.Split(a.UndergraduateMajorStr, ";")
-		a.GraduateMajor = strings.Split(a.GraduateMajorStr, ";")
-		a.Missions = strings.Split(a.MissionStr, ",")
-
 		_, err := p.db.Exec(ctx, query, a.Name, a.Year, a.Group, a.Status, a.BirthDate, a.BirthPlace, a.Gender, pq.Array(a.AlmaMater),
 			pq.Array(a.UndergraduateMajor), pq.Array(a.GraduateMajor), a.MilitaryRank, a.MilitaryBranch, a.SpaceFlights, a.SpaceFlightHours,
 			a.SpaceWalks, a.SpaceWalkHours, pq.Array(a.Missions), a.DeathDate, a.DeathMission)
@@ -119,17 +115,3 @@ func (p *PostgresDB) populateAstronautTable() error {
 
 	return nil
 }
-
-func formatStrsToLower(a *model.Astronaut) {
-	a.Name = strings.ToLower(a.Name)
-	a.Status = strings.ToLower(a.Status)
-	a.BirthPlace = strings.ToLower(a.BirthPlace)
-	a.Gender = strings.ToLower(a.Gender)
-	a.AlmaMaterStr = strings.ToLower(a.AlmaMaterStr)
-	a.UndergraduateMajorStr = strings.ToLower(a.UndergraduateMajorStr)
-	a.GraduateMajorStr = strings.ToLower(a.GraduateMajorStr)
-	a.MilitaryBranch = strings.ToLower(a.MilitaryBranch)
-	a.MilitaryRank = strings.ToLower(a.MilitaryRank)
-	a.DeathMission = strings.ToLower(a.DeathMission)
-	a.MissionStr = strings.ToLower(a.MissionStr)
-}
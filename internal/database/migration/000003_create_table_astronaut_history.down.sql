@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS astronaut_history_trigger ON astronaut;
+DROP FUNCTION IF EXISTS record_astronaut_history;
+DROP TABLE IF EXISTS astronaut_history;
@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS astronaut_history (
+  id SERIAL PRIMARY KEY,
+  astronaut_id INT NOT NULL,
+  event VARCHAR(10) NOT NULL,
+  snapshot JSONB NOT NULL,
+  recorded_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS astronaut_history_astronaut_id_idx ON astronaut_history (astronaut_id);
+
+CREATE OR REPLACE FUNCTION record_astronaut_history() RETURNS TRIGGER AS $$
+BEGIN
+  IF TG_OP = 'DELETE' THEN
+    INSERT INTO astronaut_history (astronaut_id, event, snapshot) VALUES (OLD.id, 'delete', to_jsonb(OLD));
+    RETURN OLD;
+  END IF;
+
+  INSERT INTO astronaut_history (astronaut_id, event, snapshot) VALUES (NEW.id, lower(TG_OP), to_jsonb(NEW));
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER astronaut_history_trigger
+  AFTER INSERT OR UPDATE OR DELETE ON astronaut
+  FOR EACH ROW EXECUTE FUNCTION record_astronaut_history();
@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS astronaut_redirect (
+  old_id INT NOT NULL PRIMARY KEY,
+  new_id INT NOT NULL REFERENCES astronaut (id) ON DELETE CASCADE,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,2 @@
+ALTER TABLE astronaut DROP COLUMN IF EXISTS version;
+ALTER TABLE "user" DROP COLUMN IF EXISTS version;
@@ -0,0 +1,2 @@
+ALTER TABLE astronaut ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 1;
+ALTER TABLE "user" ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 1;
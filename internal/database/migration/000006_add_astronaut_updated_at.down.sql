@@ -0,0 +1,2 @@
+ALTER TABLE astronaut_history ALTER COLUMN recorded_at SET DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE astronaut DROP COLUMN IF EXISTS updated_at;
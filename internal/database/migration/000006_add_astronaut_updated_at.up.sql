@@ -0,0 +1,2 @@
+ALTER TABLE astronaut ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP NOT NULL DEFAULT (now() AT TIME ZONE 'utc');
+ALTER TABLE astronaut_history ALTER COLUMN recorded_at SET DEFAULT (now() AT TIME ZONE 'utc');
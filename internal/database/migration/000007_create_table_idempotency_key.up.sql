@@ -0,0 +1,13 @@
+-- user_id 0 scopes keys sent without an API key, such as sign ups
+CREATE TABLE IF NOT EXISTS idempotency_key (
+  user_id INT NOT NULL,
+  key VARCHAR(255) NOT NULL,
+  request_hash CHAR(64) NOT NULL,
+  status INT,
+  headers JSONB,
+  body BYTEA,
+  created_at TIMESTAMP NOT NULL DEFAULT (now() AT TIME ZONE 'utc'),
+  PRIMARY KEY (user_id, key)
+);
+
+CREATE INDEX IF NOT EXISTS idempotency_key_created_at_idx ON idempotency_key (created_at);
@@ -0,0 +1,14 @@
+-- family links every token rotated from the same login, reuse of a rotated
+-- token revokes the whole family
+CREATE TABLE IF NOT EXISTS refresh_token (
+  id SERIAL PRIMARY KEY,
+  user_id INT NOT NULL REFERENCES "user" (id) ON DELETE CASCADE,
+  token_hash CHAR(64) NOT NULL UNIQUE,
+  family UUID NOT NULL,
+  expires_at TIMESTAMP NOT NULL,
+  revoked_at TIMESTAMP,
+  created_at TIMESTAMP NOT NULL DEFAULT (now() AT TIME ZONE 'utc')
+);
+
+CREATE INDEX IF NOT EXISTS refresh_token_family_idx ON refresh_token (family);
+CREATE INDEX IF NOT EXISTS refresh_token_expires_at_idx ON refresh_token (expires_at);
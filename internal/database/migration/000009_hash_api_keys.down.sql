@@ -0,0 +1,3 @@
+-- digests can not be reversed, users have to issue themselves new keys
+ALTER TABLE "user" RENAME COLUMN api_key_hash TO api_key;
+ALTER TABLE "user" DROP COLUMN IF EXISTS api_key_prefix;
@@ -0,0 +1,7 @@
+-- keys are replaced by their sha256 digest, the first 8 characters are kept
+-- so users can tell their keys apart
+ALTER TABLE "user" ADD COLUMN IF NOT EXISTS api_key_prefix VARCHAR(8) NOT NULL DEFAULT '';
+
+UPDATE "user" SET api_key_prefix = left(api_key, 8), api_key = encode(sha256(convert_to(api_key, 'UTF8')), 'hex');
+
+ALTER TABLE "user" RENAME COLUMN api_key TO api_key_hash;
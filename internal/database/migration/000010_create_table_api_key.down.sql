@@ -0,0 +1,18 @@
+ALTER TABLE "user" ADD COLUMN IF NOT EXISTS api_key_hash TEXT;
+ALTER TABLE "user" ADD COLUMN IF NOT EXISTS api_key_prefix VARCHAR(8) NOT NULL DEFAULT '';
+
+-- each user keeps their newest active key, users without one get a digest
+-- no key matches so they have to issue themselves a new one
+UPDATE "user" u SET api_key_hash = k.key_hash, api_key_prefix = k.prefix
+FROM (
+  SELECT DISTINCT ON (user_id) user_id, key_hash, prefix FROM api_key
+  WHERE revoked_at IS NULL ORDER BY user_id, id DESC
+) k
+WHERE k.user_id = u.id;
+
+UPDATE "user" SET api_key_hash = md5(random()::text) WHERE api_key_hash IS NULL;
+
+ALTER TABLE "user" ALTER COLUMN api_key_hash SET NOT NULL;
+ALTER TABLE "user" ADD CONSTRAINT user_api_key_key UNIQUE (api_key_hash);
+
+DROP TABLE IF EXISTS api_key;
@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS api_key (
+  id SERIAL PRIMARY KEY,
+  user_id INT NOT NULL REFERENCES "user" (id) ON DELETE CASCADE,
+  name VARCHAR(50) NOT NULL,
+  key_hash CHAR(64) NOT NULL UNIQUE,
+  prefix VARCHAR(8) NOT NULL,
+  scopes TEXT[] NOT NULL,
+  expires_at TIMESTAMP,
+  revoked_at TIMESTAMP,
+  created_at TIMESTAMP NOT NULL DEFAULT (now() AT TIME ZONE 'utc')
+);
+
+CREATE INDEX IF NOT EXISTS api_key_user_id_idx ON api_key (user_id);
+
+-- existing keys keep every scope their user's role can use
+INSERT INTO api_key (user_id, name, key_hash, prefix, scopes, created_at)
+SELECT id, 'default', api_key_hash, api_key_prefix,
+  CASE WHEN role = 'admin'
+    THEN ARRAY['astronauts:read', 'astronauts:write', 'users:read', 'users:write', 'users:admin']
+    ELSE ARRAY['astronauts:read', 'users:read', 'users:write']
+  END,
+  created_at
+FROM "user";
+
+ALTER TABLE "user" DROP COLUMN IF EXISTS api_key_hash;
+ALTER TABLE "user" DROP COLUMN IF EXISTS api_key_prefix;
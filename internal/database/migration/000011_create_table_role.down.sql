@@ -0,0 +1,9 @@
+UPDATE api_key SET scopes = array_remove(array_remove(scopes, 'astronauts:audit'), 'roles:admin');
+
+-- users of roles added later fall back to the base role
+UPDATE "user" SET role = 'user' WHERE role NOT IN ('admin', 'user');
+
+ALTER TABLE "user" DROP CONSTRAINT IF EXISTS user_role_fkey;
+ALTER TABLE "user" ALTER COLUMN role TYPE VARCHAR(10);
+
+DROP TABLE IF EXISTS role;
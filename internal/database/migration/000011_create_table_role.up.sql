@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS role (
+  name VARCHAR(50) PRIMARY KEY,
+  description TEXT NOT NULL DEFAULT '',
+  permissions TEXT[] NOT NULL,
+  builtin BOOLEAN NOT NULL DEFAULT FALSE,
+  created_at TIMESTAMP NOT NULL DEFAULT (now() AT TIME ZONE 'utc'),
+  updated_at TIMESTAMP NOT NULL DEFAULT (now() AT TIME ZONE 'utc'),
+  version INT NOT NULL DEFAULT 1
+);
+
+INSERT INTO role (name, description, permissions, builtin) VALUES
+  ('admin', 'Full access', ARRAY['astronauts:read', 'astronauts:write', 'astronauts:audit', 'users:read', 'users:write', 'users:admin', 'roles:admin'], TRUE),
+  ('user', 'Reads astronauts and manages their own account', ARRAY['astronauts:read', 'users:read', 'users:write'], TRUE),
+  ('editor', 'Curates astronauts without managing users', ARRAY['astronauts:read', 'astronauts:write', 'astronauts:audit', 'users:read', 'users:write'], FALSE),
+  ('auditor', 'Reads astronauts and data quality reports', ARRAY['astronauts:read', 'astronauts:audit', 'users:read'], FALSE)
+ON CONFLICT (name) DO NOTHING;
+
+ALTER TABLE "user" ALTER COLUMN role TYPE VARCHAR(50);
+ALTER TABLE "user" ADD CONSTRAINT user_role_fkey FOREIGN KEY (role) REFERENCES role (name) ON UPDATE CASCADE;
+
+-- admin keys holding every scope keep doing so
+UPDATE api_key k SET scopes = k.scopes || ARRAY['astronauts:audit', 'roles:admin']
+FROM "user" u
+WHERE u.id = k.user_id AND u.role = 'admin'
+  AND k.scopes @> ARRAY['astronauts:read', 'astronauts:write', 'users:read', 'users:write', 'users:admin'];
@@ -0,0 +1,9 @@
+-- token buckets shared by every instance when RATE_LIMIT_STORE=postgres, a
+-- bucket idle long enough to refill is the same as a missing one
+CREATE TABLE IF NOT EXISTS rate_limit_bucket (
+  key VARCHAR(100) PRIMARY KEY,
+  tokens DOUBLE PRECISION NOT NULL,
+  updated_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS rate_limit_bucket_updated_at_idx ON rate_limit_bucket (updated_at);
@@ -0,0 +1,12 @@
+-- daily request counts and bytes served per user and endpoint, endpoint is
+-- the method and route template such as GET /api/v1/astronauts/{astronautID}
+CREATE TABLE IF NOT EXISTS usage (
+  user_id INT NOT NULL REFERENCES "user" (id) ON DELETE CASCADE,
+  day DATE NOT NULL,
+  endpoint VARCHAR(255) NOT NULL,
+  requests BIGINT NOT NULL DEFAULT 0,
+  bytes BIGINT NOT NULL DEFAULT 0,
+  PRIMARY KEY (user_id, day, endpoint)
+);
+
+CREATE INDEX IF NOT EXISTS usage_day_idx ON usage (day);
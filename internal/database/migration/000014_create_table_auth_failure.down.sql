@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS security_event;
+DROP TABLE IF EXISTS auth_failure;
@@ -0,0 +1,18 @@
+-- failed logins and API key lookups per account (by email) and client IP,
+-- lockouts are derived from the count and the time of the last failure
+CREATE TABLE IF NOT EXISTS auth_failure (
+  key VARCHAR(320) PRIMARY KEY,
+  failures INT NOT NULL,
+  last_failure_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS auth_failure_last_failure_at_idx ON auth_failure (last_failure_at);
+
+CREATE TABLE IF NOT EXISTS security_event (
+  id SERIAL PRIMARY KEY,
+  type VARCHAR(50) NOT NULL,
+  user_id INT REFERENCES "user" (id) ON DELETE SET NULL,
+  ip VARCHAR(64) NOT NULL DEFAULT '',
+  detail TEXT NOT NULL DEFAULT '',
+  created_at TIMESTAMP NOT NULL DEFAULT (now() AT TIME ZONE 'utc')
+);
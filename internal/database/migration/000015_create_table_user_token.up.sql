@@ -0,0 +1,18 @@
+-- accounts created before verification existed keep working
+ALTER TABLE "user" ADD COLUMN IF NOT EXISTS email_verified_at TIMESTAMP;
+UPDATE "user" SET email_verified_at = created_at WHERE email_verified_at IS NULL;
+
+-- single use tokens mailed to users, purpose is password_reset or
+-- email_verification
+CREATE TABLE IF NOT EXISTS user_token (
+  id SERIAL PRIMARY KEY,
+  user_id INT NOT NULL REFERENCES "user" (id) ON DELETE CASCADE,
+  purpose VARCHAR(50) NOT NULL,
+  token_hash CHAR(64) NOT NULL UNIQUE,
+  expires_at TIMESTAMP NOT NULL,
+  used_at TIMESTAMP,
+  created_at TIMESTAMP NOT NULL DEFAULT (now() AT TIME ZONE 'utc')
+);
+
+CREATE INDEX IF NOT EXISTS user_token_user_id_purpose_idx ON user_token (user_id, purpose);
+CREATE INDEX IF NOT EXISTS user_token_expires_at_idx ON user_token (expires_at);
@@ -0,0 +1,13 @@
+-- admin invitations, accepting one creates the admin account for email
+CREATE TABLE IF NOT EXISTS invitation (
+  id SERIAL PRIMARY KEY,
+  email VARCHAR(255) NOT NULL,
+  token_hash CHAR(64) NOT NULL UNIQUE,
+  invited_by INT REFERENCES "user" (id) ON DELETE SET NULL,
+  expires_at TIMESTAMP NOT NULL,
+  accepted_at TIMESTAMP,
+  created_at TIMESTAMP NOT NULL DEFAULT (now() AT TIME ZONE 'utc')
+);
+
+CREATE INDEX IF NOT EXISTS invitation_email_idx ON invitation (email);
+CREATE INDEX IF NOT EXISTS invitation_expires_at_idx ON invitation (expires_at);
@@ -0,0 +1,5 @@
+-- the address a token was mailed to, tokens of an email the user no longer
+-- has must not verify it
+ALTER TABLE user_token ADD COLUMN IF NOT EXISTS email VARCHAR(255);
+UPDATE user_token SET email = "user".email FROM "user" WHERE "user".id = user_token.user_id AND user_token.email IS NULL;
+ALTER TABLE user_token ALTER COLUMN email SET NOT NULL;
@@ -0,0 +1,66 @@
+package database
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+//go:embed migration/*.sql
+var migrations embed.FS
+
+// LatestMigrationVersion returns the highest migration version shipped with
+// the app, the schema version every archive and database must match
+func LatestMigrationVersion() (int, error) {
+	entries, err := fs.ReadDir(migrations, "migration")
+	if err != nil {
+		return 0, fmt.Errorf("unable to read migrations: %w", err)
+	}
+
+	latest := 0
+	for _, e := range entries {
+		prefix, _, ok := strings.Cut(e.Name(), "_")
+		if !ok {
+			continue
+		}
+
+		v, err := strconv.Atoi(prefix)
+		if err != nil {
+			return 0, fmt.Errorf("invalid migration file name %q: %w", e.Name(), err)
+		}
+		latest = max(latest, v)
+	}
+
+	return latest, nil
+}
+
+// schemaVersion reads the version golang-migrate recorded for the database
+func schemaVersion(ctx context.Context, db *pgxpool.Pool) (int, error) {
+	var (
+		version int
+		dirty   bool
+	)
+
+	query := `SELECT version, dirty FROM schema_migrations LIMIT 1;`
+
+	err := db.QueryRow(ctx, query).Scan(&version, &dirty)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, errors.New("database has no applied migrations")
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if dirty {
+		return 0, fmt.Errorf("database migration %d is dirty", version)
+	}
+
+	return version, nil
+}
@@ -0,0 +1,179 @@
+package dataset
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+	"github.com/gocarina/gocsv"
+)
+
+// field describes an astronaut property compared during reconciliation,
+// named after its json key
+type field struct {
+	name  string
+	value func(a *model.Astronaut) any
+}
+
+var fields = []field{
+	{"name", func(a *model.Astronaut) any { return a.Name }},
+	{"year", func(a *model.Astronaut) any { return a.Year }},
+	{"group", func(a *model.Astronaut) any { return a.Group }},
+	{"status", func(a *model.Astronaut) any { return a.Status }},
+	{"birthDate", func(a *model.Astronaut) any { return a.BirthDate }},
+	{"birthPlace", func(a *model.Astronaut) any { return a.BirthPlace }},
+	{"gender", func(a *model.Astronaut) any { return a.Gender }},
+	{"almaMater", func(a *model.Astronaut) any { return a.AlmaMater }},
+	{"undergraduateMajor", func(a *model.Astronaut) any { return a.UndergraduateMajor }},
+	{"graduateMajor", func(a *model.Astronaut) any { return a.GraduateMajor }},
+	{"militaryRank", func(a *model.Astronaut) any { return a.MilitaryRank }},
+	{"militaryBranch", func(a *model.Astronaut) any { return a.MilitaryBranch }},
+	{"spaceFlights", func(a *model.Astronaut) any { return a.SpaceFlights }},
+	{"spaceFlightHours", func(a *model.Astronaut) any { return a.SpaceFlightHours }},
+	{"spaceWalks", func(a *model.Astronaut) any { return a.SpaceWalks }},
+	{"spaceWalkHours", func(a *model.Astronaut) any { return a.SpaceWalkHours }},
+	{"missions", func(a *model.Astronaut) any { return a.Missions }},
+	{"deathDate", func(a *model.Astronaut) any { return a.DeathDate }},
+	{"deathMission", func(a *model.Astronaut) any { return a.DeathMission }},
+}
+
+// Load reads astronauts from a CSV file in the seed format and normalizes them
+// the same way the database seed does
+func Load(r io.Reader) ([]*model.Astronaut, error) {
+	var astronauts []*model.Astronaut
+
+	if err := gocsv.Unmarshal(r, &astronauts); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal CSV data to stuct slice: %w", err)
+	}
+
+	for _, a := range astronauts {
+		formatStrsToLower(a)
+
+		a.AlmaMater = strings.Split(a.AlmaMaterStr, ";")
+		a.UndergraduateMajor = strings.Split(a.UndergraduateMajorStr, ";")
+		a.GraduateMajor = strings.Split(a.GraduateMajorStr, ";")
+		a.Missions = strings.Split(a.MissionStr, ",")
+	}
+
+	return astronauts, nil
+}
+
+// Key returns the natural key of an astronaut, its name and birth date
+func Key(a *model.Astronaut) string {
+	return fmt.Sprintf("%s|%s", strings.TrimSpace(strings.ToLower(a.Name)), strings.TrimSpace(a.BirthDate))
+}
+
+// Diff compares the current astronaut records against an incoming dataset.
+// When several current records share a natural key only the first one is
+// reconciled, the others are left for duplicate handling.
+func Diff(current, incoming []*model.Astronaut) *model.DatasetDiff {
+	diff := &model.DatasetDiff{
+		Added:   make([]*model.AstronautChange, 0),
+		Removed: make([]*model.AstronautChange, 0),
+		Changed: make([]*model.AstronautChange, 0),
+	}
+
+	existing := make(map[string]*model.Astronaut, len(current))
+	order := make([]string, 0, len(current))
+	for _, a := range current {
+		k := Key(a)
+		if _, ok := existing[k]; ok {
+			continue
+		}
+		existing[k] = a
+		order = append(order, k)
+	}
+
+	seen := make(map[string]bool, len(incoming))
+	for _, a := range incoming {
+		k := Key(a)
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+
+		old, ok := existing[k]
+		if !ok {
+			diff.Added = append(diff.Added, &model.AstronautChange{Key: k, Type: model.ChangeAdded, Astronaut: a})
+			continue
+		}
+
+		changes := compare(old, a)
+		if len(changes) > 0 {
+			a.ID, a.Version = old.ID, old.Version
+			diff.Changed = append(diff.Changed, &model.AstronautChange{Key: k, Type: model.ChangeChanged, ID: old.ID, Astronaut: a, Fields: changes})
+		}
+	}
+
+	for _, k := range order {
+		if seen[k] {
+			continue
+		}
+		a := existing[k]
+		diff.Removed = append(diff.Removed, &model.AstronautChange{Key: k, Type: model.ChangeRemoved, ID: a.ID, Astronaut: a})
+	}
+
+	return diff
+}
+
+// Select returns a copy of the diff holding only the changes whose key is in
+// keys, an empty key list selects every change
+func Select(diff *model.DatasetDiff, keys []string) *model.DatasetDiff {
+	if len(keys) == 0 {
+		return diff
+	}
+
+	filter := func(changes []*model.AstronautChange) []*model.AstronautChange {
+		selected := make([]*model.AstronautChange, 0)
+		for _, c := range changes {
+			if slices.Contains(keys, c.Key) {
+				selected = append(selected, c)
+			}
+		}
+		return selected
+	}
+
+	return &model.DatasetDiff{
+		Added:   filter(diff.Added),
+		Removed: filter(diff.Removed),
+		Changed: filter(diff.Changed),
+	}
+}
+
+func compare(old, new *model.Astronaut) []model.FieldChange {
+	var changes []model.FieldChange
+
+	for _, f := range fields {
+		o, n := f.value(old), f.value(new)
+		if equal(o, n) {
+			continue
+		}
+		changes = append(changes, model.FieldChange{Field: f.name, Old: o, New: n})
+	}
+
+	return changes
+}
+
+func equal(a, b any) bool {
+	as, ok := a.([]string)
+	if !ok {
+		return a == b
+	}
+	return slices.Equal(as, b.([]string))
+}
+
+func formatStrsToLower(a *model.Astronaut) {
+	a.Name = strings.ToLower(a.Name)
+	a.Status = strings.ToLower(a.Status)
+	a.BirthPlace = strings.ToLower(a.BirthPlace)
+	a.Gender = strings.ToLower(a.Gender)
+	a.AlmaMaterStr = strings.ToLower(a.AlmaMaterStr)
+	a.UndergraduateMajorStr = strings.ToLower(a.UndergraduateMajorStr)
+	a.GraduateMajorStr = strings.ToLower(a.GraduateMajorStr)
+	a.MilitaryBranch = strings.ToLower(a.MilitaryBranch)
+	a.MilitaryRank = strings.ToLower(a.MilitaryRank)
+	a.DeathMission = strings.ToLower(a.DeathMission)
+	a.MissionStr = strings.ToLower(a.MissionStr)
+}
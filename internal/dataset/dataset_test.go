@@ -0,0 +1,59 @@
+package dataset
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+)
+
+const csvData = `Name,Year,Group,Status,Birth Date,Birth Place,Gender,Alma Mater,Undergraduate Major,Graduate Major,Military Rank,Military Branch,Space Flights,Space Flight (hr),Space Walks,Space Walk (hr),Missions,Death Date,Death Mission
+Joseph M. Acaba,2004,19,Active,5/17/1967,"Inglewood, CA",Male,University of California-Santa Barbara; University of Arizona,Geology,Geology,,,2,3307,2,13,"STS-119 (Discovery), ISS-31/32 (Soyuz)",,
+Loren W. Acton,,,Retired,3/7/1936,"Lewiston, MT",Male,Montana State University; University of Colorado,Engineering Physics,Solar Physics,,,1,190,0,0,STS 51-F (Challenger),,
+`
+
+func TestDiff(t *testing.T) {
+	incoming, err := Load(strings.NewReader(csvData))
+	if err != nil {
+		t.Fatalf("unexpected error loading csv: %v", err)
+	}
+
+	current := []*model.Astronaut{
+		{ID: 1, Name: "joseph m. acaba", Year: 2004, Group: 19, Status: "retired", BirthDate: "5/17/1967", BirthPlace: "inglewood, ca", Gender: "male",
+			AlmaMater: []string{"university of california-santa barbara", " university of arizona"}, UndergraduateMajor: []string{"geology"},
+			GraduateMajor: []string{"geology"}, SpaceFlights: 2, SpaceFlightHours: 3307, SpaceWalks: 2, SpaceWalkHours: 13,
+			Missions: []string{"sts-119 (discovery)", " iss-31/32 (soyuz)"}},
+		{ID: 2, Name: "john doe", BirthDate: "1/1/1950"},
+	}
+
+	diff := Diff(current, incoming)
+
+	if len(diff.Added) != 1 || diff.Added[0].Key != "loren w. acton|3/7/1936" {
+		t.Fatalf("expected acton to be added, got %+v", diff.Added)
+	}
+
+	if len(diff.Removed) != 1 || diff.Removed[0].ID != 2 {
+		t.Fatalf("expected astronaut 2 to be removed, got %+v", diff.Removed)
+	}
+
+	if len(diff.Changed) != 1 {
+		t.Fatalf("expected 1 changed astronaut, got %d", len(diff.Changed))
+	}
+
+	c := diff.Changed[0]
+	if c.ID != 1 || c.Astronaut.ID != 1 {
+		t.Fatalf("expected changed astronaut to keep ID 1, got %d", c.ID)
+	}
+
+	if len(c.Fields) != 1 || c.Fields[0].Field != "status" || c.Fields[0].New != "active" {
+		t.Fatalf("expected a single status change, got %+v", c.Fields)
+	}
+
+	t.Run("selects changes by key", func(t *testing.T) {
+		selected := Select(diff, []string{"john doe|1/1/1950"})
+
+		if len(selected.Added) != 0 || len(selected.Changed) != 0 || len(selected.Removed) != 1 {
+			t.Fatalf("expected only the removal to be selected, got %+v", selected)
+		}
+	})
+}
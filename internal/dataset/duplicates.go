@@ -0,0 +1,186 @@
+package dataset
+
+import (
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+)
+
+// weights of each signal in a duplicate score, they add up to 1
+const (
+	nameWeight       = 0.6
+	birthDateWeight  = 0.25
+	birthPlaceWeight = 0.15
+)
+
+// Duplicates scores every pair of astronauts and returns the pairs scoring at
+// least threshold, best matches first
+func Duplicates(astronauts []*model.Astronaut, threshold float64) []*model.DuplicateCandidate {
+	candidates := make([]*model.DuplicateCandidate, 0)
+
+	names := make([]string, len(astronauts))
+	for i, a := range astronauts {
+		names[i] = normalizeName(a.Name)
+	}
+
+	for i := 0; i < len(astronauts); i++ {
+		for j := i + 1; j < len(astronauts); j++ {
+			a, b := astronauts[i], astronauts[j]
+
+			c := &model.DuplicateCandidate{
+				Astronaut:      a,
+				Duplicate:      b,
+				NameScore:      jaroWinkler(names[i], names[j]),
+				SameBirthDate:  a.BirthDate != "" && a.BirthDate == b.BirthDate,
+				SameBirthPlace: a.BirthPlace != "" && strings.EqualFold(a.BirthPlace, b.BirthPlace),
+			}
+
+			c.Score = nameWeight * c.NameScore
+			if c.SameBirthDate {
+				c.Score += birthDateWeight
+			}
+			if c.SameBirthPlace {
+				c.Score += birthPlaceWeight
+			}
+
+			if c.Score >= threshold {
+				candidates = append(candidates, c)
+			}
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Score > candidates[j].Score
+	})
+
+	return candidates
+}
+
+// Merge folds the loser into the winner, the winner's values win and empty
+// ones are filled from the loser, flight stats keep the larger value and
+// lists are combined
+func Merge(winner, loser *model.Astronaut) *model.Astronaut {
+	m := *winner
+
+	fill := func(dst *string, src string) {
+		if *dst == "" {
+			*dst = src
+		}
+	}
+
+	fill(&m.Name, loser.Name)
+	fill(&m.Status, loser.Status)
+	fill(&m.BirthDate, loser.BirthDate)
+	fill(&m.BirthPlace, loser.BirthPlace)
+	fill(&m.Gender, loser.Gender)
+	fill(&m.MilitaryRank, loser.MilitaryRank)
+	fill(&m.MilitaryBranch, loser.MilitaryBranch)
+	fill(&m.DeathDate, loser.DeathDate)
+	fill(&m.DeathMission, loser.DeathMission)
+
+	if m.Year == 0 {
+		m.Year = loser.Year
+	}
+	if m.Group == 0 {
+		m.Group = loser.Group
+	}
+
+	m.SpaceFlights = max(m.SpaceFlights, loser.SpaceFlights)
+	m.SpaceFlightHours = max(m.SpaceFlightHours, loser.SpaceFlightHours)
+	m.SpaceWalks = max(m.SpaceWalks, loser.SpaceWalks)
+	m.SpaceWalkHours = max(m.SpaceWalkHours, loser.SpaceWalkHours)
+
+	m.Missions = union(m.Missions, loser.Missions)
+	m.AlmaMater = union(m.AlmaMater, loser.AlmaMater)
+	m.UndergraduateMajor = union(m.UndergraduateMajor, loser.UndergraduateMajor)
+	m.GraduateMajor = union(m.GraduateMajor, loser.GraduateMajor)
+
+	return &m
+}
+
+func union(a, b []string) []string {
+	combined := make([]string, 0, len(a)+len(b))
+	for _, v := range append(slices.Clone(a), b...) {
+		v = strings.TrimSpace(v)
+		if v == "" || slices.Contains(combined, v) {
+			continue
+		}
+		combined = append(combined, v)
+	}
+	return combined
+}
+
+// normalizeName drops punctuation and repeated spaces so "joseph m. acaba"
+// and "joseph m acaba" compare equal
+func normalizeName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsSpace(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, name)
+	return strings.Join(strings.Fields(name), " ")
+}
+
+func jaroWinkler(a, b string) float64 {
+	s1, s2 := []rune(a), []rune(b)
+	if len(s1) == 0 && len(s2) == 0 {
+		return 1
+	}
+	if len(s1) == 0 || len(s2) == 0 {
+		return 0
+	}
+
+	window := max(len(s1), len(s2))/2 - 1
+	window = max(window, 0)
+
+	m1 := make([]bool, len(s1))
+	m2 := make([]bool, len(s2))
+
+	matches := 0
+	for i := range s1 {
+		lo, hi := max(0, i-window), min(len(s2), i+window+1)
+		for j := lo; j < hi; j++ {
+			if m2[j] || s1[i] != s2[j] {
+				continue
+			}
+			m1[i], m2[j] = true, true
+			matches++
+			break
+		}
+	}
+
+	if matches == 0 {
+		return 0
+	}
+
+	transpositions, k := 0, 0
+	for i := range s1 {
+		if !m1[i] {
+			continue
+		}
+		for !m2[k] {
+			k++
+		}
+		if s1[i] != s2[k] {
+			transpositions++
+		}
+		k++
+	}
+
+	m := float64(matches)
+	jaro := (m/float64(len(s1)) + m/float64(len(s2)) + (m-float64(transpositions)/2)/m) / 3
+
+	prefix := 0
+	for i := 0; i < min(4, len(s1), len(s2)); i++ {
+		if s1[i] != s2[i] {
+			break
+		}
+		prefix++
+	}
+
+	return jaro + float64(prefix)*0.1*(1-jaro)
+}
@@ -0,0 +1,88 @@
+package dataset
+
+import (
+	"math"
+	"testing"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+)
+
+func TestJaroWinkler(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"martha", "marhta", 0.9611},
+		{"dwayne", "duane", 0.84},
+		{"dixon", "dicksonx", 0.8133},
+		{"john glenn", "john glenn", 1},
+		{"", "", 1},
+		{"john glenn", "", 0},
+		{"abc", "xyz", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.a+"/"+tt.b, func(t *testing.T) {
+			if got := jaroWinkler(tt.a, tt.b); math.Abs(got-tt.want) > 0.0001 {
+				t.Fatalf("jaroWinkler(%q, %q) = %.4f, want %.4f", tt.a, tt.b, got, tt.want)
+			}
+			if got := jaroWinkler(tt.b, tt.a); math.Abs(got-tt.want) > 0.0001 {
+				t.Fatalf("jaroWinkler is not symmetric for %q and %q, got %.4f", tt.a, tt.b, got)
+			}
+		})
+	}
+}
+
+func TestDuplicates(t *testing.T) {
+	acaba := &model.Astronaut{ID: 1, Name: "joseph m. acaba", BirthDate: "5/17/1967", BirthPlace: "inglewood, ca"}
+
+	tests := []struct {
+		name      string
+		other     *model.Astronaut
+		threshold float64
+		score     float64
+		found     bool
+	}{
+		{"same name, date and place", &model.Astronaut{ID: 2, Name: "Joseph M Acaba", BirthDate: "5/17/1967", BirthPlace: "Inglewood, CA"}, 0.9, 1, true},
+		{"same name and date", &model.Astronaut{ID: 2, Name: "joseph m acaba", BirthDate: "5/17/1967"}, 0.85, nameWeight + birthDateWeight, true},
+		{"same name and date below threshold", &model.Astronaut{ID: 2, Name: "joseph m acaba", BirthDate: "5/17/1967"}, 0.9, nameWeight + birthDateWeight, false},
+		{"same name only", &model.Astronaut{ID: 2, Name: "joseph m. acaba"}, 0.6, nameWeight, true},
+		{"empty dates never match", &model.Astronaut{ID: 2, Name: "joseph m. acaba"}, 0.61, nameWeight, false},
+		{"different person", &model.Astronaut{ID: 2, Name: "loren w. acton", BirthDate: "3/7/1936", BirthPlace: "lewiston, mt"}, 0.5, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			candidates := Duplicates([]*model.Astronaut{acaba, tt.other}, tt.threshold)
+
+			if !tt.found {
+				if len(candidates) != 0 {
+					t.Fatalf("expected no candidates at %.2f, got score %.4f", tt.threshold, candidates[0].Score)
+				}
+				return
+			}
+
+			if len(candidates) != 1 {
+				t.Fatalf("expected 1 candidate at %.2f, got %d", tt.threshold, len(candidates))
+			}
+			if c := candidates[0]; math.Abs(c.Score-tt.score) > 0.0001 || c.Astronaut != acaba || c.Duplicate != tt.other {
+				t.Fatalf("expected score %.4f for the pair, got %.4f", tt.score, c.Score)
+			}
+		})
+	}
+
+	t.Run("best matches first", func(t *testing.T) {
+		near := &model.Astronaut{ID: 2, Name: "joseph acaba", BirthDate: "5/17/1967"}
+		same := &model.Astronaut{ID: 3, Name: "joseph m acaba", BirthDate: "5/17/1967", BirthPlace: "inglewood, ca"}
+
+		candidates := Duplicates([]*model.Astronaut{acaba, near, same}, 0.8)
+		if len(candidates) < 2 || candidates[0].Duplicate != same {
+			t.Fatalf("expected the exact match first, got %d candidates", len(candidates))
+		}
+		for i := 1; i < len(candidates); i++ {
+			if candidates[i].Score > candidates[i-1].Score {
+				t.Fatalf("candidates are not sorted by score")
+			}
+		}
+	})
+}
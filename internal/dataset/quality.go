@@ -0,0 +1,137 @@
+package dataset
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+)
+
+// rule codes reported by Check, clients may rely on them staying stable
+const (
+	RuleDeceasedWithoutDeathDate  = "deceased_without_death_date"
+	RuleDeathDateNotDeceased      = "death_date_not_deceased"
+	RuleSpaceFlightsMismatch      = "space_flights_mission_mismatch"
+	RuleSpaceWalkHoursWithoutWalk = "space_walk_hours_without_space_walks"
+	RuleMissingGroup              = "missing_group"
+	RuleMissingYear               = "missing_year"
+	RuleEmptyListElement          = "empty_list_element"
+	RuleUntrimmedListElement      = "untrimmed_list_element"
+)
+
+type qualityRule func(a *model.Astronaut) []*model.QualityFinding
+
+var qualityRules = []qualityRule{
+	checkDeath,
+	checkSpaceFlights,
+	checkSpaceWalks,
+	checkGroupAndYear,
+	checkListElements,
+}
+
+// Check runs every data quality rule against an astronaut record
+func Check(a *model.Astronaut) []*model.QualityFinding {
+	findings := make([]*model.QualityFinding, 0)
+
+	for _, rule := range qualityRules {
+		findings = append(findings, rule(a)...)
+	}
+
+	return findings
+}
+
+func finding(a *model.Astronaut, rule, severity, msg string) *model.QualityFinding {
+	return &model.QualityFinding{AstronautID: a.ID, Rule: rule, Severity: severity, Message: msg}
+}
+
+func checkDeath(a *model.Astronaut) []*model.QualityFinding {
+	if a.Status == "deceased" && a.DeathDate == "" {
+		return []*model.QualityFinding{finding(a, RuleDeceasedWithoutDeathDate, model.SeverityError,
+			"status is deceased but no death date is recorded")}
+	}
+
+	if a.Status != "deceased" && a.DeathDate != "" {
+		return []*model.QualityFinding{finding(a, RuleDeathDateNotDeceased, model.SeverityWarning,
+			fmt.Sprintf("death date is recorded but status is %q", a.Status))}
+	}
+
+	return nil
+}
+
+func checkSpaceFlights(a *model.Astronaut) []*model.QualityFinding {
+	missions := 0
+	for _, m := range a.Missions {
+		if strings.TrimSpace(m) != "" {
+			missions++
+		}
+	}
+
+	if a.SpaceFlights != missions {
+		return []*model.QualityFinding{finding(a, RuleSpaceFlightsMismatch, model.SeverityWarning,
+			fmt.Sprintf("%d space flights recorded but %d missions listed", a.SpaceFlights, missions))}
+	}
+
+	return nil
+}
+
+func checkSpaceWalks(a *model.Astronaut) []*model.QualityFinding {
+	if a.SpaceWalks == 0 && a.SpaceWalkHours > 0 {
+		return []*model.QualityFinding{finding(a, RuleSpaceWalkHoursWithoutWalk, model.SeverityError,
+			fmt.Sprintf("%d space walk hours recorded without any space walks", a.SpaceWalkHours))}
+	}
+
+	return nil
+}
+
+func checkGroupAndYear(a *model.Astronaut) []*model.QualityFinding {
+	var findings []*model.QualityFinding
+
+	if a.Group == 0 {
+		findings = append(findings, finding(a, RuleMissingGroup, model.SeverityWarning, "astronaut group is missing"))
+	}
+
+	if a.Year == 0 {
+		findings = append(findings, finding(a, RuleMissingYear, model.SeverityWarning, "selection year is missing"))
+	}
+
+	return findings
+}
+
+// checkListElements flags the artifacts left by splitting csv columns, empty
+// columns become [""] and "a; b" keeps the space before b
+func checkListElements(a *model.Astronaut) []*model.QualityFinding {
+	var findings []*model.QualityFinding
+
+	lists := []struct {
+		name   string
+		values []string
+	}{
+		{"almaMater", a.AlmaMater},
+		{"undergraduateMajor", a.UndergraduateMajor},
+		{"graduateMajor", a.GraduateMajor},
+		{"missions", a.Missions},
+	}
+
+	for _, l := range lists {
+		var empty, untrimmed bool
+		for _, v := range l.values {
+			if strings.TrimSpace(v) == "" {
+				empty = true
+			} else if strings.TrimSpace(v) != v {
+				untrimmed = true
+			}
+		}
+
+		if empty {
+			findings = append(findings, finding(a, RuleEmptyListElement, model.SeverityWarning,
+				fmt.Sprintf("%s contains an empty element", l.name)))
+		}
+
+		if untrimmed {
+			findings = append(findings, finding(a, RuleUntrimmedListElement, model.SeverityInfo,
+				fmt.Sprintf("%s contains an element with surrounding whitespace", l.name)))
+		}
+	}
+
+	return findings
+}
@@ -0,0 +1,40 @@
+package dataset
+
+import (
+	"testing"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+)
+
+func TestCheck(t *testing.T) {
+	t.Run("returns no findings for a consistent record", func(t *testing.T) {
+		a := &model.Astronaut{ID: 1, Year: 1996, Group: 16, Status: "active", SpaceFlights: 1, Missions: []string{"sts-107"},
+			AlmaMater: []string{"purdue university"}, UndergraduateMajor: []string{"physics"}, GraduateMajor: []string{"physics"}}
+
+		if findings := Check(a); len(findings) != 0 {
+			t.Fatalf("expected no findings, got %+v", findings)
+		}
+	})
+
+	t.Run("flags every inconsistency", func(t *testing.T) {
+		a := &model.Astronaut{ID: 2, Status: "deceased", SpaceFlights: 2, SpaceWalkHours: 4, Missions: []string{"sts-51-l"},
+			AlmaMater: []string{"a", " b"}, UndergraduateMajor: []string{""}, GraduateMajor: []string{""}}
+
+		rules := make(map[string]bool)
+		for _, f := range Check(a) {
+			if f.AstronautID != 2 {
+				t.Fatalf("expected finding for astronaut 2, got %d", f.AstronautID)
+			}
+			rules[f.Rule] = true
+		}
+
+		expected := []string{RuleDeceasedWithoutDeathDate, RuleSpaceFlightsMismatch, RuleSpaceWalkHoursWithoutWalk,
+			RuleMissingGroup, RuleMissingYear, RuleEmptyListElement, RuleUntrimmedListElement}
+
+		for _, r := range expected {
+			if !rules[r] {
+				t.Errorf("expected rule %s to be flagged", r)
+			}
+		}
+	})
+}
@@ -0,0 +1,116 @@
+// Package mail sends the account emails through SMTP, or writes them to a
+// file or the log for local development and tests.
+package mail
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/smtp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+)
+
+// SMTPMailer sends mail through an SMTP server, authenticating with PLAIN
+// when a username is set
+type SMTPMailer struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+func NewSMTPMailer(host, port, username, password, from string) *SMTPMailer {
+	m := &SMTPMailer{
+		addr: net.JoinHostPort(host, port),
+		from: from,
+	}
+	if username != "" {
+		m.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return m
+}
+
+// Send ignores ctx once the message is handed to the server, net/smtp has no
+// cancellation
+func (m *SMTPMailer) Send(ctx context.Context, msg *model.Mail) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	buf := new(bytes.Buffer)
+	writeMessage(buf, m.from, msg, time.Now())
+
+	if err := smtp.SendMail(m.addr, m.auth, m.from, []string{msg.To}, buf.Bytes()); err != nil {
+		return fmt.Errorf("error sending mail: %w", err)
+	}
+	return nil
+}
+
+// FileMailer appends each message to w, separated by a blank line
+type FileMailer struct {
+	mu   sync.Mutex
+	w    io.Writer
+	from string
+}
+
+func NewFileMailer(w io.Writer, from string) *FileMailer {
+	return &FileMailer{
+		w:    w,
+		from: from,
+	}
+}
+
+func (m *FileMailer) Send(ctx context.Context, msg *model.Mail) error {
+	buf := new(bytes.Buffer)
+	writeMessage(buf, m.from, msg, time.Now())
+	buf.WriteString("\r\n")
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, err := m.w.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("error writing mail: %w", err)
+	}
+	return nil
+}
+
+// LogMailer logs each message instead of sending it
+type LogMailer struct {
+	log *slog.Logger
+}
+
+func NewLogMailer(l *slog.Logger) *LogMailer {
+	return &LogMailer{
+		log: l,
+	}
+}
+
+func (m *LogMailer) Send(ctx context.Context, msg *model.Mail) error {
+	m.log.InfoContext(ctx, "mail not sent, logged instead", slog.String("to", msg.To), slog.String("subject", msg.Subject), slog.String("body", msg.Body))
+	return nil
+}
+
+// writeMessage formats msg as a plain text RFC 5322 message, header values
+// lose line breaks so they can't inject headers
+func writeMessage(w *bytes.Buffer, from string, msg *model.Mail, date time.Time) {
+	header := strings.NewReplacer("\r", "", "\n", "")
+
+	fmt.Fprintf(w, "From: %s\r\n", header.Replace(from))
+	fmt.Fprintf(w, "To: %s\r\n", header.Replace(msg.To))
+	fmt.Fprintf(w, "Subject: %s\r\n", header.Replace(msg.Subject))
+	fmt.Fprintf(w, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	w.WriteString("MIME-Version: 1.0\r\n")
+	w.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	w.WriteString("\r\n")
+
+	for _, line := range strings.Split(msg.Body, "\n") {
+		w.WriteString(strings.TrimSuffix(line, "\r"))
+		w.WriteString("\r\n")
+	}
+}
@@ -0,0 +1,36 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+)
+
+func TestFileMailer(t *testing.T) {
+	buf := new(bytes.Buffer)
+	m := NewFileMailer(buf, "no-reply@example.com")
+
+	msg := &model.Mail{
+		To:      "ada@example.com\r\nBcc: eve@example.com",
+		Subject: "Verify your email",
+		Body:    "first line\nsecond line",
+	}
+	if err := m.Send(context.Background(), msg); err != nil {
+		t.Fatalf("unexpected error sending mail: %v", err)
+	}
+
+	got := buf.String()
+	for _, want := range []string{
+		"From: no-reply@example.com\r\n",
+		"To: ada@example.comBcc: eve@example.com\r\n",
+		"Subject: Verify your email\r\n",
+		"\r\n\r\nfirst line\r\nsecond line\r\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("message does not contain %q:\n%s", want, got)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package model
+
+import (
+	"context"
+	"time"
+)
+
+// user token purposes
+const (
+	TokenPasswordReset     = "password_reset"
+	TokenEmailVerification = "email_verification"
+)
+
+type (
+	// UserToken is a single use token mailed to a user, stored by the hash of
+	// the token in the mail
+	UserToken struct {
+		ID     int
+		UserID int
+		// Email is the address the token was mailed to
+		Email     string
+		Purpose   string
+		TokenHash string
+		ExpiresAt time.Time
+		UsedAt    *time.Time
+		CreatedAt time.Time
+	}
+
+	// Mail is a plain text message to a single recipient
+	Mail struct {
+		To      string
+		Subject string
+		Body    string
+	}
+
+	Mailer interface {
+		Send(ctx context.Context, m *Mail) error
+	}
+
+	UserTokenStore interface {
+		// Create stores t, unused tokens of the user for the same purpose
+		// stop working
+		Create(ctx context.Context, t *UserToken) error
+		// ResetPassword uses the password reset token with the hash, sets
+		// the user's password hash and ends their sessions. The email is
+		// verified too if it is still the one the token was mailed to.
+		// Unknown, used or expired tokens fail with ErrNotFound.
+		ResetPassword(ctx context.Context, hash, password string, now time.Time) (int, error)
+		// VerifyEmail uses the email verification token with the hash and
+		// marks the user's email verified if it is still the one the token
+		// was mailed to
+		VerifyEmail(ctx context.Context, hash string, now time.Time) (int, error)
+		DeleteExpired(ctx context.Context, before time.Time) (int64, error)
+	}
+
+	// AccountUsecase recovers and verifies accounts through tokens mailed to
+	// their email, requests for unknown emails succeed without sending mail
+	AccountUsecase interface {
+		ForgotPassword(ctx context.Context, email string) error
+		ResetPassword(ctx context.Context, token, password string) error
+		SendVerification(ctx context.Context, u *User) error
+		ResendVerification(ctx context.Context, email string) error
+		VerifyEmail(ctx context.Context, token string) error
+		Purge(ctx context.Context) (int64, error)
+	}
+)
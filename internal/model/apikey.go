@@ -0,0 +1,26 @@
+package model
+
+import "time"
+
+// APIKey is one of a user's keys, only the digest of the key is stored and
+// Key holds the plaintext just after it is issued. Scopes are permissions of
+// the user's role the key is limited to.
+type APIKey struct {
+	ID        int        `json:"id"`
+	UserID    int        `json:"userId"`
+	Name      string     `json:"name"`
+	Key       string     `json:"key,omitempty"`
+	Hash      string     `json:"-"`
+	Prefix    string     `json:"prefix"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	RevokedAt *time.Time `json:"revokedAt,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+	// User owns the key, it is only loaded when authenticating
+	User *User `json:"-"`
+}
+
+// Active reports whether the key may still authenticate at t
+func (k *APIKey) Active(t time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || t.Before(*k.ExpiresAt))
+}
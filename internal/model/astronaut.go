@@ -1,36 +1,89 @@
 package model
 
-import "context"
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
 
 type (
 
 	// properties ending in 'Str' are list in string form in csv file
 	// (seperated by) -- missions (,) gradute major, undergrad, almamater (;)
 	Astronaut struct {
-		ID                    int      `json:"id"`
-		Name                  string   `json:"name" csv:"Name"`
-		Year                  int      `json:"year" csv:"Year"`
-		Group                 int      `json:"group" csv:"Group"`
-		Status                string   `json:"status" csv:"Status"`
-		BirthDate             string   `json:"birthDate" csv:"Birth Date"`
-		BirthPlace            string   `json:"birthPlace" csv:"Birth Place"`
-		Gender                string   `json:"gender" csv:"Gender"`
-		AlmaMaterStr          string   `json:"omitempty" csv:"Alma Mater"`
-		UndergraduateMajorStr string   `json:"omitempty" csv:"Undergraduate Major"`
-		GraduateMajorStr      string   `json:"omitempty" csv:"Graduate Major"`
-		MilitaryRank          string   `json:"militaryRank" csv:"Military Rank"`
-		MilitaryBranch        string   `json:"militaryBranch" csv:"Military Branch"`
-		SpaceFlights          int      `json:"spaceFlights" csv:"Space Flights"`
-		SpaceFlightHours      int      `json:"spaceFlightHours" csv:"Space Flight (hr)"`
-		SpaceWalks            int      `json:"spaceWalks" csv:"Space Walks"`
-		SpaceWalkHours        int      `json:"spaceWalkHours" csv:"Space Walk (hr)"`
-		MissionStr            string   `json:"omitempty" csv:"Missions"`
-		DeathDate             string   `json:"deathDate" csv:"Death Date"`
-		DeathMission          string   `json:"deathMission" csv:"Death Mission"`
-		Missions              []string `json:"missions"`
-		UndergraduateMajor    []string `json:"undergraduateMajor"`
-		GraduateMajor         []string `json:"graduateMajor"`
-		AlmaMater             []string `json:"almaMater"`
+		ID                    int       `json:"id"`
+		Name                  string    `json:"name" csv:"Name"`
+		Year                  int       `json:"year" csv:"Year"`
+		Group                 int       `json:"group" csv:"Group"`
+		Status                string    `json:"status" csv:"Status"`
+		BirthDate             string    `json:"birthDate" csv:"Birth Date"`
+		BirthPlace            string    `json:"birthPlace" csv:"Birth Place"`
+		Gender                string    `json:"gender" csv:"Gender"`
+		AlmaMaterStr          string    `json:"-" csv:"Alma Mater"`
+		UndergraduateMajorStr string    `json:"-" csv:"Undergraduate Major"`
+		GraduateMajorStr      string    `json:"-" csv:"Graduate Major"`
+		MilitaryRank          string    `json:"militaryRank" csv:"Military Rank"`
+		MilitaryBranch        string    `json:"militaryBranch" csv:"Military Branch"`
+		SpaceFlights          int       `json:"spaceFlights" csv:"Space Flights"`
+		SpaceFlightHours      int       `json:"spaceFlightHours" csv:"Space Flight (hr)"`
+		SpaceWalks            int       `json:"spaceWalks" csv:"Space Walks"`
+		SpaceWalkHours        int       `json:"spaceWalkHours" csv:"Space Walk (hr)"`
+		MissionStr            string    `json:"-" csv:"Missions"`
+		DeathDate             string    `json:"deathDate" csv:"Death Date"`
+		DeathMission          string    `json:"deathMission" csv:"Death Mission"`
+		Missions              []string  `json:"missions"`
+		UndergraduateMajor    []string  `json:"undergraduateMajor"`
+		GraduateMajor         []string  `json:"graduateMajor"`
+		AlmaMater             []string  `json:"almaMater"`
+		Version               int       `json:"version"`
+		UpdatedAt             time.Time `json:"updatedAt"`
+		Links                 Links     `json:"_links,omitempty"`
+	}
+
+	HistoryEntry struct {
+		ID          int             `json:"id"`
+		AstronautID int             `json:"astronautId"`
+		Event       string          `json:"event"`
+		Snapshot    json.RawMessage `json:"snapshot"`
+		RecordedAt  time.Time       `json:"recordedAt"`
+	}
+
+	// Score weighs name similarity, birth date and birth place between 0 and 1
+	DuplicateCandidate struct {
+		Astronaut      *Astronaut `json:"astronaut"`
+		Duplicate      *Astronaut `json:"duplicate"`
+		Score          float64    `json:"score"`
+		NameScore      float64    `json:"nameScore"`
+		SameBirthDate  bool       `json:"sameBirthDate"`
+		SameBirthPlace bool       `json:"sameBirthPlace"`
+	}
+
+	// PatchFunc applies a patch document to the json form of an astronaut
+	PatchFunc func(doc []byte) ([]byte, error)
+
+	// MovedError is returned when an astronaut was merged into another record
+	MovedError struct {
+		ID int
+	}
+
+	// BatchOperation is one write of a batch, Version 0 skips the version
+	// check like If-Match: *
+	BatchOperation struct {
+		Op        string     `json:"op"`
+		ID        int        `json:"id,omitempty"`
+		Version   *int       `json:"version,omitempty"`
+		Astronaut *Astronaut `json:"astronaut,omitempty"`
+	}
+
+	// BatchResult reports the outcome of the operation at Index, Err is nil
+	// when it was applied
+	BatchResult struct {
+		Index     int
+		Op        string
+		ID        int
+		Astronaut *Astronaut
+		Err       error
 	}
 
 	// need to add Search methods for popular search categories
@@ -39,15 +92,51 @@ type (
 		List(ctx context.Context, limit, offset int) ([]*Astronaut, error)
 		Get(ctx context.Context, id int) (*Astronaut, error)
 		Update(ctx context.Context, a *Astronaut) error
-		Delete(ctx context.Context, id int) error
+		Delete(ctx context.Context, id, version int) error
 		SearchByName(ctx context.Context, name string, limit, offset int) ([]*Astronaut, error)
+		All(ctx context.Context) ([]*Astronaut, error)
+		Merge(ctx context.Context, winner *Astronaut, loserID int) error
+		Redirect(ctx context.Context, id int) (int, error)
+		History(ctx context.Context, id int) ([]*HistoryEntry, error)
+		LastModified(ctx context.Context) (time.Time, error)
+		Count(ctx context.Context) (int, error)
+		Crewmates(ctx context.Context, id int) ([]*Astronaut, error)
+		InTx(ctx context.Context, fn func(AstronautStore) error) error
 	}
 
 	AstronautUsecase interface {
-		Create(ctx context.Context, a *Astronaut) (*Astronaut, []error)
+		Create(ctx context.Context, a *Astronaut) (*Astronaut, error)
 		List(ctx context.Context, limit, offset int) ([]*Astronaut, error)
+		Count(ctx context.Context) (int, error)
+		LastModified(ctx context.Context) (time.Time, error)
 		Get(ctx context.Context, id int) (*Astronaut, error)
 		Update(ctx context.Context, a *Astronaut) (*Astronaut, error)
-		Delete(ctx context.Context, id int) error
+		Patch(ctx context.Context, id, version int, patch PatchFunc) (*Astronaut, error)
+		Delete(ctx context.Context, id, version int) error
+		History(ctx context.Context, id int) ([]*HistoryEntry, error)
+		Crewmates(ctx context.Context, id int) ([]*Astronaut, error)
+		Duplicates(ctx context.Context, threshold float64) ([]*DuplicateCandidate, error)
+		Merge(ctx context.Context, winnerID, loserID int) (*Astronaut, error)
+		Batch(ctx context.Context, mode string, ops []*BatchOperation) ([]*BatchResult, error)
 	}
 )
+
+// batch operations and modes
+const (
+	BatchCreate = "create"
+	BatchUpdate = "update"
+	BatchDelete = "delete"
+
+	// BatchAtomic applies every operation or none, BatchBestEffort applies
+	// each operation that succeeds on its own
+	BatchAtomic     = "atomic"
+	BatchBestEffort = "best-effort"
+)
+
+// ErrBatchRolledBack marks operations of an atomic batch that were undone,
+// or never run, because another operation failed
+var ErrBatchRolledBack = NewError(KindConflict, "batch_rolled_back", "operation was rolled back because another operation in the batch failed")
+
+func (e *MovedError) Error() string {
+	return fmt.Sprintf("astronaut was merged into %d", e.ID)
+}
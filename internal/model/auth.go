@@ -0,0 +1,53 @@
+package model
+
+import (
+	"context"
+	"time"
+)
+
+type (
+	// TokenPair is issued on login and on every refresh, ExpiresIn is the
+	// lifetime of the access token in seconds
+	TokenPair struct {
+		AccessToken  string `json:"accessToken"`
+		TokenType    string `json:"tokenType"`
+		ExpiresIn    int    `json:"expiresIn"`
+		RefreshToken string `json:"refreshToken"`
+	}
+
+	Credentials struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+
+	// RefreshToken is stored by the hash of the token handed to the client,
+	// Family is shared by every token rotated from the same login
+	RefreshToken struct {
+		ID        int
+		UserID    int
+		TokenHash string
+		Family    string
+		ExpiresAt time.Time
+		RevokedAt *time.Time
+		CreatedAt time.Time
+	}
+
+	RefreshTokenStore interface {
+		Create(ctx context.Context, t *RefreshToken) error
+		GetByHash(ctx context.Context, hash string) (*RefreshToken, error)
+		// Rotate revokes old and stores next, it fails with ErrConflict when
+		// old was revoked concurrently
+		Rotate(ctx context.Context, old, next *RefreshToken) error
+		RevokeFamily(ctx context.Context, family string) error
+		DeleteExpired(ctx context.Context, before time.Time) (int64, error)
+	}
+
+	AuthUsecase interface {
+		Login(ctx context.Context, c *Credentials) (*TokenPair, error)
+		Refresh(ctx context.Context, token string) (*TokenPair, error)
+		Logout(ctx context.Context, token string) error
+		// Authenticate returns the user an access token was issued to
+		Authenticate(ctx context.Context, accessToken string) (*User, error)
+		Purge(ctx context.Context) (int64, error)
+	}
+)
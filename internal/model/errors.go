@@ -0,0 +1,115 @@
+package model
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// ErrorKind classifies an ApiError, the transport layer maps each kind to a
+// status code
+type ErrorKind string
+
+const (
+	KindInvalid              ErrorKind = "invalid"
+	KindValidation           ErrorKind = "validation"
+	KindUnauthenticated      ErrorKind = "unauthenticated"
+	KindForbidden            ErrorKind = "forbidden"
+	KindNotFound             ErrorKind = "not_found"
+	KindMethodNotAllowed     ErrorKind = "method_not_allowed"
+	KindNotAcceptable        ErrorKind = "not_acceptable"
+	KindConflict             ErrorKind = "conflict"
+	KindPreconditionFailed   ErrorKind = "precondition_failed"
+	KindPreconditionRequired ErrorKind = "precondition_required"
+	KindUnsupported          ErrorKind = "unsupported"
+	KindTooManyRequests      ErrorKind = "too_many_requests"
+	KindTimeout              ErrorKind = "timeout"
+	KindUnavailable          ErrorKind = "unavailable"
+	KindInternal             ErrorKind = "internal"
+)
+
+// errors.Is(err, ErrNotFound) matches any ApiError of the same kind
+var (
+	ErrInvalid         = &ApiError{Kind: KindInvalid}
+	ErrUnauthenticated = &ApiError{Kind: KindUnauthenticated}
+	ErrForbidden       = &ApiError{Kind: KindForbidden}
+	ErrNotFound        = &ApiError{Kind: KindNotFound}
+	ErrConflict        = &ApiError{Kind: KindConflict}
+)
+
+// ErrVersionConflict is returned when a write expected a version of the
+// resource that is no longer current
+var ErrVersionConflict = NewError(KindPreconditionFailed, "version_mismatch", "resource version does not match")
+
+type (
+	// Code is stable for clients to branch on, Message is safe to show them
+	ApiError struct {
+		Kind    ErrorKind
+		Code    string
+		Message string
+		Fields  []*FieldError
+		Err     error
+		// RetryAfter tells clients when the request may succeed again
+		RetryAfter time.Duration
+	}
+
+	FieldError struct {
+		Field   string `json:"field"`
+		Message string `json:"message"`
+	}
+)
+
+func NewError(kind ErrorKind, code, msg string) *ApiError {
+	return &ApiError{Kind: kind, Code: code, Message: msg}
+}
+
+func WrapError(kind ErrorKind, code, msg string, err error) *ApiError {
+	return &ApiError{Kind: kind, Code: code, Message: msg, Err: err}
+}
+
+// NewValidationError collects the errors returned by a validator
+func NewValidationError(errs []error) *ApiError {
+	e := NewError(KindValidation, "validation_failed", "request failed validation")
+
+	for _, err := range errs {
+		fe := new(FieldError)
+		if !errors.As(err, &fe) {
+			fe = &FieldError{Message: err.Error()}
+		}
+		e.Fields = append(e.Fields, fe)
+	}
+
+	return e
+}
+
+func (e *ApiError) Error() string {
+	msg := e.Message
+	if len(e.Fields) > 0 {
+		fields := make([]string, 0, len(e.Fields))
+		for _, f := range e.Fields {
+			fields = append(fields, f.Message)
+		}
+		msg += ": " + strings.Join(fields, ", ")
+	}
+
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *ApiError) Unwrap() error {
+	return e.Err
+}
+
+func (e *ApiError) Is(target error) bool {
+	t, ok := target.(*ApiError)
+	if !ok || t.Kind != e.Kind {
+		return false
+	}
+	return t.Code == "" || t.Code == e.Code
+}
+
+func (e *FieldError) Error() string {
+	return e.Message
+}
@@ -0,0 +1,37 @@
+package model
+
+import (
+	"context"
+	"time"
+)
+
+type (
+	// IdempotencyRecord is the first response to a request sent with an
+	// Idempotency-Key, Status is 0 while that request is still running
+	IdempotencyRecord struct {
+		UserID      int
+		Key         string
+		RequestHash string
+		Status      int
+		Headers     map[string]string
+		Body        []byte
+		CreatedAt   time.Time
+	}
+
+	IdempotencyStore interface {
+		// Claim stores a pending record unless a live one exists for the
+		// key, which is returned instead. Records created before expiry are
+		// replaced.
+		Claim(ctx context.Context, rec *IdempotencyRecord, expiry time.Time) (*IdempotencyRecord, error)
+		Complete(ctx context.Context, rec *IdempotencyRecord) error
+		Release(ctx context.Context, userID int, key string) error
+		DeleteExpired(ctx context.Context, expiry time.Time) (int64, error)
+	}
+
+	IdempotencyUsecase interface {
+		Begin(ctx context.Context, rec *IdempotencyRecord) (*IdempotencyRecord, error)
+		Complete(ctx context.Context, rec *IdempotencyRecord) error
+		Release(ctx context.Context, userID int, key string) error
+		Purge(ctx context.Context) (int64, error)
+	}
+)
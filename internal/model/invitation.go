@@ -0,0 +1,40 @@
+package model
+
+import (
+	"context"
+	"time"
+)
+
+type (
+	// Invitation asks the owner of Email to become an admin, it is stored by
+	// the hash of the token mailed to them
+	Invitation struct {
+		ID         int        `json:"id"`
+		Email      string     `json:"email"`
+		TokenHash  string     `json:"-"`
+		InvitedBy  *int       `json:"invitedBy"`
+		ExpiresAt  time.Time  `json:"expiresAt"`
+		AcceptedAt *time.Time `json:"acceptedAt,omitempty"`
+		CreatedAt  time.Time  `json:"createdAt"`
+	}
+
+	InvitationStore interface {
+		// Create stores inv, pending invitations to the same email stop
+		// working
+		Create(ctx context.Context, inv *Invitation) error
+		GetByHash(ctx context.Context, hash string) (*Invitation, error)
+		// Accept marks the invitation accepted and stores u with its first
+		// key, an invitation accepted concurrently or expired by now fails
+		// with ErrNotFound
+		Accept(ctx context.Context, id int, u *User, k *APIKey, now time.Time) (int, error)
+		DeleteExpired(ctx context.Context, before time.Time) (int64, error)
+	}
+
+	InvitationUsecase interface {
+		// Invite mails an admin invitation to email, it takes users:admin
+		Invite(ctx context.Context, email string) (*Invitation, error)
+		// Accept creates the invited admin from the name and password of u
+		Accept(ctx context.Context, token string, u *User) (*User, error)
+		Purge(ctx context.Context) (int64, error)
+	}
+)
@@ -0,0 +1,50 @@
+package model
+
+import (
+	"context"
+	"time"
+)
+
+// security event types
+const (
+	EventAccountLocked   = "account_locked"
+	EventIPLocked        = "ip_locked"
+	EventAccountUnlocked = "account_unlocked"
+)
+
+type (
+	// AuthFailure counts the failed attempts of an account or client IP,
+	// Key is prefixed with what it tracks such as account: or ip:
+	AuthFailure struct {
+		Key           string
+		Failures      int
+		LastFailureAt time.Time
+	}
+
+	// SecurityEvent records a lockout or an admin acting on one
+	SecurityEvent struct {
+		ID        int       `json:"id"`
+		Type      string    `json:"type"`
+		UserID    *int      `json:"userId"`
+		IP        string    `json:"ip,omitempty"`
+		Detail    string    `json:"detail"`
+		CreatedAt time.Time `json:"createdAt"`
+	}
+
+	LockoutStore interface {
+		Get(ctx context.Context, key string) (*AuthFailure, error)
+		// Fail counts a failure at now, failures before since are forgotten,
+		// and returns the new count
+		Fail(ctx context.Context, key string, now, since time.Time) (int, error)
+		Delete(ctx context.Context, key string) error
+		DeleteStale(ctx context.Context, before time.Time) (int64, error)
+		CreateEvent(ctx context.Context, e *SecurityEvent) error
+		ListEvents(ctx context.Context, limit, offset int) ([]*SecurityEvent, error)
+	}
+
+	LockoutUsecase interface {
+		Unlock(ctx context.Context, userID int) error
+		Events(ctx context.Context, limit, offset int) ([]*SecurityEvent, error)
+		Purge(ctx context.Context) (int64, error)
+	}
+)
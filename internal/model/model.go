@@ -1,17 +1,33 @@
 package model
 
-type JSONResponse struct {
-	Astronaut  *Astronaut   `json:"astronaut,omitempty"`
-	Astronauts []*Astronaut `json:"astronauts,omitempty"`
-	User       *User        `json:"user,omitempty"`
-	Users      []*User      `json:"users,omitempty"`
-	Message    string       `json:"message,omitempty"`
-	Error      string       `json:"error,omitempty"`
-	Errors     []string     `json:"errors,omitempty"`
-}
+type (
+	// Link is a HAL style link to a related resource
+	Link struct {
+		Href string `json:"href"`
+	}
 
-type ApiError struct{}
+	// Links are keyed by their relation, such as self or next
+	Links map[string]*Link
+)
 
-func (e *ApiError) Error() string {
-	return ""
+type JSONResponse struct {
+	Astronaut      *Astronaut            `json:"astronaut,omitempty"`
+	Astronauts     []*Astronaut          `json:"astronauts,omitempty"`
+	User           *User                 `json:"user,omitempty"`
+	Users          []*User               `json:"users,omitempty"`
+	APIKey         *APIKey               `json:"apiKey,omitempty"`
+	APIKeys        []*APIKey             `json:"apiKeys,omitempty"`
+	Role           *Role                 `json:"role,omitempty"`
+	Roles          []*Role               `json:"roles,omitempty"`
+	Diff           *DatasetDiff          `json:"diff,omitempty"`
+	Findings       []*QualityFinding     `json:"findings,omitempty"`
+	History        []*HistoryEntry       `json:"history,omitempty"`
+	Duplicates     []*DuplicateCandidate `json:"duplicates,omitempty"`
+	Missions       []string              `json:"missions,omitempty"`
+	Usage          *Usage                `json:"usage,omitempty"`
+	Report         []*UsageTotal         `json:"report,omitempty"`
+	SecurityEvents []*SecurityEvent      `json:"securityEvents,omitempty"`
+	Invitation     *Invitation           `json:"invitation,omitempty"`
+	Message        string                `json:"message,omitempty"`
+	Links          Links                 `json:"_links,omitempty"`
 }
@@ -0,0 +1,22 @@
+package model
+
+import "context"
+
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+	SeverityInfo    = "info"
+)
+
+type (
+	QualityFinding struct {
+		AstronautID int    `json:"astronautId"`
+		Rule        string `json:"rule"`
+		Severity    string `json:"severity"`
+		Message     string `json:"message"`
+	}
+
+	DataQualityUsecase interface {
+		Report(ctx context.Context) ([]*QualityFinding, error)
+	}
+)
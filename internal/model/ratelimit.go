@@ -0,0 +1,81 @@
+package model
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+type (
+	// RateLimit allows bursts of Requests, refilled evenly over Per
+	RateLimit struct {
+		Requests int
+		Per      time.Duration
+	}
+
+	// RateLimits picks the limit of a request, Roles falls back on Default
+	// and Anonymous limits requests without credentials by client IP
+	RateLimits struct {
+		Default   RateLimit
+		Anonymous RateLimit
+		Roles     map[string]RateLimit
+	}
+
+	// RateLimitBucket is a token bucket holding Tokens at UpdatedAt
+	RateLimitBucket struct {
+		Tokens    float64
+		UpdatedAt time.Time
+	}
+
+	// RateLimitResult describes the bucket of a request after it was counted
+	RateLimitResult struct {
+		Allowed    bool
+		Limit      int
+		Remaining  int
+		RetryAfter time.Duration
+	}
+
+	RateLimitStore interface {
+		// Take refills the bucket of key, taking a token if one is left, and
+		// returns it with whether a token was taken
+		Take(ctx context.Context, key string, l RateLimit, now time.Time) (*RateLimitBucket, bool, error)
+		DeleteIdle(ctx context.Context, before time.Time) (int64, error)
+	}
+
+	RateLimitUsecase interface {
+		// Allow counts a request against the bucket of key, an empty role is
+		// an anonymous client
+		Allow(ctx context.Context, key, role string) (*RateLimitResult, error)
+		Purge(ctx context.Context) (int64, error)
+	}
+)
+
+// rate is the number of tokens added per second
+func (l RateLimit) rate() float64 {
+	return float64(l.Requests) / l.Per.Seconds()
+}
+
+// Take refills b for the time since it was last updated and takes a token
+// when one is left. A zero bucket is full.
+func (b *RateLimitBucket) Take(l RateLimit, now time.Time) bool {
+	if b.UpdatedAt.IsZero() {
+		b.Tokens = float64(l.Requests)
+	} else if elapsed := now.Sub(b.UpdatedAt); elapsed > 0 {
+		b.Tokens = math.Min(float64(l.Requests), b.Tokens+elapsed.Seconds()*l.rate())
+	}
+	b.UpdatedAt = now
+
+	if b.Tokens < 1 {
+		return false
+	}
+	b.Tokens--
+	return true
+}
+
+// Wait is how long until the bucket holds a token again
+func (b *RateLimitBucket) Wait(l RateLimit) time.Duration {
+	if b.Tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.Tokens) / l.rate() * float64(time.Second))
+}
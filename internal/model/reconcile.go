@@ -0,0 +1,41 @@
+package model
+
+import (
+	"context"
+	"io"
+)
+
+const (
+	ChangeAdded   = "added"
+	ChangeRemoved = "removed"
+	ChangeChanged = "changed"
+)
+
+type (
+	FieldChange struct {
+		Field string `json:"field"`
+		Old   any    `json:"old"`
+		New   any    `json:"new"`
+	}
+
+	// Key is the natural key (name and birth date) used to match csv rows
+	// against astronaut records
+	AstronautChange struct {
+		Key       string        `json:"key"`
+		Type      string        `json:"type"`
+		ID        int           `json:"id,omitempty"`
+		Astronaut *Astronaut    `json:"astronaut,omitempty"`
+		Fields    []FieldChange `json:"fields,omitempty"`
+	}
+
+	DatasetDiff struct {
+		Added   []*AstronautChange `json:"added"`
+		Removed []*AstronautChange `json:"removed"`
+		Changed []*AstronautChange `json:"changed"`
+	}
+
+	ReconcileUsecase interface {
+		Diff(ctx context.Context, r io.Reader) (*DatasetDiff, error)
+		Apply(ctx context.Context, r io.Reader, keys []string) (*DatasetDiff, error)
+	}
+)
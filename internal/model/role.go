@@ -0,0 +1,58 @@
+package model
+
+import (
+	"context"
+	"time"
+)
+
+// permissions name what a role may do, API key scopes use the same names to
+// narrow them further
+const (
+	PermissionAstronautsRead  = "astronauts:read"
+	PermissionAstronautsWrite = "astronauts:write"
+	PermissionAstronautsAudit = "astronauts:audit"
+	PermissionUsersRead       = "users:read"
+	PermissionUsersWrite      = "users:write"
+	PermissionUsersAdmin      = "users:admin"
+	PermissionRolesAdmin      = "roles:admin"
+)
+
+var Permissions = []string{
+	PermissionAstronautsRead,
+	PermissionAstronautsWrite,
+	PermissionAstronautsAudit,
+	PermissionUsersRead,
+	PermissionUsersWrite,
+	PermissionUsersAdmin,
+	PermissionRolesAdmin,
+}
+
+type (
+	// Role is a named set of permissions, builtin roles can not be deleted
+	// and the admin role can not be changed
+	Role struct {
+		Name        string    `json:"name"`
+		Description string    `json:"description"`
+		Permissions []string  `json:"permissions"`
+		Builtin     bool      `json:"builtin"`
+		CreatedAt   time.Time `json:"createdAt"`
+		UpdatedAt   time.Time `json:"updatedAt"`
+		Version     int       `json:"version"`
+	}
+
+	RoleStore interface {
+		List(ctx context.Context) ([]*Role, error)
+		Get(ctx context.Context, name string) (*Role, error)
+		Create(ctx context.Context, r *Role) error
+		Update(ctx context.Context, r *Role) error
+		Delete(ctx context.Context, name string, version int) error
+	}
+
+	RoleUsecase interface {
+		List(ctx context.Context) ([]*Role, error)
+		Get(ctx context.Context, name string) (*Role, error)
+		Create(ctx context.Context, r *Role) (*Role, error)
+		Update(ctx context.Context, r *Role) (*Role, error)
+		Delete(ctx context.Context, name string, version int) error
+	}
+)
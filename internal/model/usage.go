@@ -0,0 +1,76 @@
+package model
+
+import (
+	"context"
+	"time"
+)
+
+type (
+	// UsageRecord counts the requests a user made to an endpoint on one UTC
+	// day and the response bytes they were served
+	UsageRecord struct {
+		UserID   int       `json:"-"`
+		Day      time.Time `json:"day"`
+		Endpoint string    `json:"endpoint"`
+		Requests int64     `json:"requests"`
+		Bytes    int64     `json:"bytes"`
+	}
+
+	// Usage is a user's usage between From and To, both inclusive days
+	Usage struct {
+		UserID   int            `json:"userId"`
+		From     time.Time      `json:"from"`
+		To       time.Time      `json:"to"`
+		Requests int64          `json:"requests"`
+		Bytes    int64          `json:"bytes"`
+		Days     []*UsageRecord `json:"days"`
+		Quota    *Quota         `json:"quota"`
+	}
+
+	// UsageTotal sums the usage of one user over a report's days
+	UsageTotal struct {
+		UserID   int   `json:"userId"`
+		Requests int64 `json:"requests"`
+		Bytes    int64 `json:"bytes"`
+	}
+
+	// Quotas are monthly request limits per role, Roles falls back on
+	// Default and 0 is unlimited
+	Quotas struct {
+		Default int64
+		Roles   map[string]int64
+	}
+
+	// Quota is a user's monthly request quota, Limit and Remaining are nil
+	// when it is unlimited
+	Quota struct {
+		Limit     *int64    `json:"limit"`
+		Used      int64     `json:"used"`
+		Remaining *int64    `json:"remaining"`
+		ResetsAt  time.Time `json:"resetsAt"`
+	}
+
+	UsageStore interface {
+		// Add adds the counts of records to the stored aggregates, records of
+		// deleted users are dropped
+		Add(ctx context.Context, records []*UsageRecord) error
+		List(ctx context.Context, userID int, from, to time.Time) ([]*UsageRecord, error)
+		Report(ctx context.Context, from, to time.Time) ([]*UsageTotal, error)
+		// Requests sums the requests of a user since from
+		Requests(ctx context.Context, userID int, from time.Time) (int64, error)
+	}
+
+	UsageUsecase interface {
+		// Record counts a request in memory until the next Flush
+		Record(userID int, endpoint string, bytes int64)
+		Flush(ctx context.Context) error
+		Quota(ctx context.Context, u *User) (*Quota, error)
+		Get(ctx context.Context, userID int, from, to time.Time) (*Usage, error)
+		Report(ctx context.Context, from, to time.Time) ([]*UsageTotal, error)
+	}
+)
+
+// Exhausted reports whether the quota allows no further requests
+func (q *Quota) Exhausted() bool {
+	return q.Remaining != nil && *q.Remaining <= 0
+}
@@ -5,43 +5,72 @@ import (
 	"time"
 )
 
+// builtin roles, sign ups get BaseUser whatever role they ask for
 const (
 	AdminUser = "admin"
 	BaseUser  = "user"
 )
 
 type (
+	// User keys live in the api_key table, ApiKey holds the plaintext of a
+	// key just issued on sign up or rotation so it is shown exactly once
 	User struct {
-		ID        int       `json:"id"`
-		FirstName string    `json:"firstName"`
-		Surename  string    `json:"surename"`
-		Email     string    `json:"email"`
-		Password  string    `json:"password,omitempty"`
-		Role      string    `json:"role"`
-		ApiKey    string    `json:"apiKey"`
-		CreatedAt time.Time `json:"createdAt"`
-		UpdatedAt time.Time `json:"updatedAt"`
+		ID        int    `json:"id"`
+		FirstName string `json:"firstName"`
+		Surename  string `json:"surename"`
+		Email     string `json:"email"`
+		// Password is the plaintext sent by the client until it is hashed,
+		// stores only load the hash when searching by email
+		Password string `json:"password,omitempty"`
+		Role     string `json:"role"`
+		ApiKey   string `json:"apiKey,omitempty"`
+		// Permissions of Role, loaded with the user
+		Permissions []string `json:"-"`
+		// EmailVerifiedAt is nil until the user confirms their email
+		EmailVerifiedAt *time.Time `json:"emailVerifiedAt,omitempty"`
+		CreatedAt       time.Time  `json:"createdAt"`
+		UpdatedAt       time.Time  `json:"updatedAt"`
+		Version         int        `json:"version"`
+		Links           Links      `json:"_links,omitempty"`
 	}
 
 	UserStore interface {
-		Create(ctx context.Context, u *User) (int, error)
+		// Create stores u together with its first key
+		Create(ctx context.Context, u *User, k *APIKey) (int, error)
 		List(ctx context.Context, limit, offset int) ([]*User, error)
+		Count(ctx context.Context) (int, error)
+		CountRole(ctx context.Context, role string) (int, error)
 		Get(ctx context.Context, id int) (*User, error)
 		Update(ctx context.Context, u *User) error
-		Delete(ctx context.Context, id int) error
-		SearchApiKey(ctx context.Context, key string) (*User, error)
+		Delete(ctx context.Context, id, version int) error
+		SearchEmail(ctx context.Context, email string) (*User, error)
 		UpdatePassword(ctx context.Context, u *User) error
-		UpdateAPIKey(ctx context.Context, u *User) error
+		CreateAPIKey(ctx context.Context, k *APIKey) error
+		ListAPIKeys(ctx context.Context, userID int) ([]*APIKey, error)
+		// SearchAPIKeyHash returns the key with the digest and its user
+		SearchAPIKeyHash(ctx context.Context, hash string) (*APIKey, error)
+		RevokeAPIKey(ctx context.Context, userID, id int) error
+		// RotateAPIKey revokes the key oldID and stores k in its place
+		RotateAPIKey(ctx context.Context, oldID int, k *APIKey) error
 	}
 
 	UserUsecase interface {
-		Create(ctx context.Context, u *User) (*User, []error)
+		Create(ctx context.Context, u *User) (*User, error)
+		// Bootstrap creates the first admin, it fails once any admin exists
+		Bootstrap(ctx context.Context, u *User) (*User, error)
 		List(ctx context.Context, limit, offset int) ([]*User, error)
+		Count(ctx context.Context) (int, error)
 		Get(ctx context.Context, id int) (*User, error)
-		Update(ctx context.Context, u *User) (*User, []error)
-		Delete(ctx context.Context, id int) error
-		SearchAPIKey(ctx context.Context, key string) (*User, error)
-		ResetPassword(ctx context.Context, u *User) []error
+		Update(ctx context.Context, u *User) (*User, error)
+		Delete(ctx context.Context, id, version int) error
+		// SearchAPIKey returns an active key and its user
+		SearchAPIKey(ctx context.Context, key string) (*APIKey, error)
+		ResetPassword(ctx context.Context, u *User) error
+		// GenerateNewAPIKey replaces the key used for the request, keeping
+		// its name, scopes and expiry
 		GenerateNewAPIKey(ctx context.Context, id int) (*User, error)
+		CreateAPIKey(ctx context.Context, userID int, k *APIKey) (*APIKey, error)
+		ListAPIKeys(ctx context.Context, userID int) ([]*APIKey, error)
+		RevokeAPIKey(ctx context.Context, userID, id int) error
 	}
 )
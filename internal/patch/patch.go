@@ -0,0 +1,323 @@
+package patch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const (
+	MergePatchContentType = "application/merge-patch+json"
+	JSONPatchContentType  = "application/json-patch+json"
+)
+
+type operation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+// Merge applies a RFC 7396 merge patch to a json document, null members
+// remove the matching member from the document
+func Merge(doc, patch []byte) ([]byte, error) {
+	var d, p any
+
+	if err := json.Unmarshal(doc, &d); err != nil {
+		return nil, fmt.Errorf("invalid document: %w", err)
+	}
+
+	if err := json.Unmarshal(patch, &p); err != nil {
+		return nil, fmt.Errorf("invalid merge patch: %w", err)
+	}
+
+	return json.Marshal(mergeValue(d, p))
+}
+
+// JSONPatch applies a RFC 6902 patch to a json document, operations run in
+// order and the first failing one aborts the whole patch
+func JSONPatch(doc, patch []byte) ([]byte, error) {
+	var (
+		d   any
+		ops []operation
+	)
+
+	if err := json.Unmarshal(doc, &d); err != nil {
+		return nil, fmt.Errorf("invalid document: %w", err)
+	}
+
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return nil, fmt.Errorf("invalid json patch: %w", err)
+	}
+
+	for i, op := range ops {
+		var err error
+		if d, err = apply(d, op); err != nil {
+			return nil, fmt.Errorf("operation %d (%s %s): %w", i, op.Op, op.Path, err)
+		}
+	}
+
+	return json.Marshal(d)
+}
+
+func mergeValue(target, patch any) any {
+	pm, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	tm, ok := target.(map[string]any)
+	if !ok {
+		tm = make(map[string]any)
+	}
+
+	for k, v := range pm {
+		if v == nil {
+			delete(tm, k)
+			continue
+		}
+		tm[k] = mergeValue(tm[k], v)
+	}
+
+	return tm
+}
+
+func apply(doc any, op operation) (any, error) {
+	path, err := parsePointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.Op {
+	case "add":
+		value, err := opValue(op)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, path, value)
+
+	case "remove":
+		return remove(doc, path)
+
+	case "replace":
+		value, err := opValue(op)
+		if err != nil {
+			return nil, err
+		}
+		if len(path) == 0 {
+			return value, nil
+		}
+		if doc, err = remove(doc, path); err != nil {
+			return nil, err
+		}
+		return add(doc, path, value)
+
+	case "move", "copy":
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := get(doc, from)
+		if err != nil {
+			return nil, err
+		}
+
+		if op.Op == "move" {
+			if strings.HasPrefix(op.Path+"/", op.From+"/") && op.Path != op.From {
+				return nil, errors.New("cannot move a value into one of its children")
+			}
+			if doc, err = remove(doc, from); err != nil {
+				return nil, err
+			}
+		} else if value, err = deepCopy(value); err != nil {
+			return nil, err
+		}
+
+		return add(doc, path, value)
+
+	case "test":
+		expected, err := opValue(op)
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := get(doc, path)
+		if err != nil {
+			return nil, err
+		}
+
+		if !reflect.DeepEqual(value, expected) {
+			return nil, errors.New("test failed")
+		}
+		return doc, nil
+
+	default:
+		return nil, fmt.Errorf("unknown operation %q", op.Op)
+	}
+}
+
+func opValue(op operation) (any, error) {
+	if len(op.Value) == 0 {
+		return nil, errors.New("missing value")
+	}
+
+	var v any
+	if err := json.Unmarshal(op.Value, &v); err != nil {
+		return nil, fmt.Errorf("invalid value: %w", err)
+	}
+	return v, nil
+}
+
+func add(doc any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	last := path[len(path)-1]
+
+	return update(doc, path[:len(path)-1], func(parent any) (any, error) {
+		switch p := parent.(type) {
+		case map[string]any:
+			p[last] = value
+			return p, nil
+
+		case []any:
+			if last == "-" {
+				return append(p, value), nil
+			}
+
+			i, err := index(last, len(p)+1)
+			if err != nil {
+				return nil, err
+			}
+
+			p = append(p, nil)
+			copy(p[i+1:], p[i:])
+			p[i] = value
+			return p, nil
+
+		default:
+			return nil, errors.New("path parent is not a container")
+		}
+	})
+}
+
+func remove(doc any, path []string) (any, error) {
+	if len(path) == 0 {
+		return nil, errors.New("cannot remove the whole document")
+	}
+
+	last := path[len(path)-1]
+
+	return update(doc, path[:len(path)-1], func(parent any) (any, error) {
+		switch p := parent.(type) {
+		case map[string]any:
+			if _, ok := p[last]; !ok {
+				return nil, fmt.Errorf("member %q does not exist", last)
+			}
+			delete(p, last)
+			return p, nil
+
+		case []any:
+			i, err := index(last, len(p))
+			if err != nil {
+				return nil, err
+			}
+			return append(p[:i], p[i+1:]...), nil
+
+		default:
+			return nil, errors.New("path parent is not a container")
+		}
+	})
+}
+
+// update walks to the value at path and replaces it with fn's result, arrays
+// are rebuilt on the way back up since fn may resize them
+func update(doc any, path []string, fn func(v any) (any, error)) (any, error) {
+	if len(path) == 0 {
+		return fn(doc)
+	}
+
+	switch d := doc.(type) {
+	case map[string]any:
+		child, ok := d[path[0]]
+		if !ok {
+			return nil, fmt.Errorf("member %q does not exist", path[0])
+		}
+
+		v, err := update(child, path[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		d[path[0]] = v
+		return d, nil
+
+	case []any:
+		i, err := index(path[0], len(d))
+		if err != nil {
+			return nil, err
+		}
+
+		v, err := update(d[i], path[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		d[i] = v
+		return d, nil
+
+	default:
+		return nil, fmt.Errorf("cannot traverse into %q", path[0])
+	}
+}
+
+func get(doc any, path []string) (any, error) {
+	var found any
+
+	_, err := update(doc, path, func(v any) (any, error) {
+		found = v
+		return v, nil
+	})
+
+	return found, err
+}
+
+func index(token string, length int) (int, error) {
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || i >= length || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	return i, nil
+}
+
+// parsePointer splits a RFC 6901 json pointer into its unescaped tokens
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+
+	if pointer[0] != '/' {
+		return nil, fmt.Errorf("invalid json pointer %q", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(t, "~1", "/"), "~0", "~")
+	}
+
+	return tokens, nil
+}
+
+func deepCopy(v any) (any, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var c any
+	err = json.Unmarshal(b, &c)
+	return c, err
+}
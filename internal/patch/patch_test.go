@@ -0,0 +1,70 @@
+package patch
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+const doc = `{"name":"loren w. acton","year":0,"missions":["sts 51-f (challenger)"],"deathDate":"","militaryRank":"colonel"}`
+
+func assertJSON(t *testing.T, got []byte, want string) {
+	t.Helper()
+
+	var g, w any
+	if err := json.Unmarshal(got, &g); err != nil {
+		t.Fatalf("invalid json result: %v", err)
+	}
+	json.Unmarshal([]byte(want), &w)
+
+	if !reflect.DeepEqual(g, w) {
+		t.Fatalf("expected %s got %s", want, got)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	got, err := Merge([]byte(doc), []byte(`{"year":1978,"militaryRank":null,"missions":["sts-1"]}`))
+	if err != nil {
+		t.Fatalf("unexpected error applying merge patch: %v", err)
+	}
+
+	assertJSON(t, got, `{"name":"loren w. acton","year":1978,"missions":["sts-1"],"deathDate":""}`)
+}
+
+func TestJSONPatch(t *testing.T) {
+	t.Run("applies operations in order", func(t *testing.T) {
+		p := `[
+			{"op":"test","path":"/name","value":"loren w. acton"},
+			{"op":"add","path":"/missions/-","value":"sts-2"},
+			{"op":"add","path":"/missions/0","value":"sts-0"},
+			{"op":"replace","path":"/year","value":1978},
+			{"op":"remove","path":"/militaryRank"},
+			{"op":"copy","from":"/name","path":"/alias"},
+			{"op":"move","from":"/deathDate","path":"/died"}
+		]`
+
+		got, err := JSONPatch([]byte(doc), []byte(p))
+		if err != nil {
+			t.Fatalf("unexpected error applying json patch: %v", err)
+		}
+
+		assertJSON(t, got, `{"name":"loren w. acton","alias":"loren w. acton","year":1978,"died":"",
+			"missions":["sts-0","sts 51-f (challenger)","sts-2"]}`)
+	})
+
+	t.Run("rejects failing operations", func(t *testing.T) {
+		patches := []string{
+			`[{"op":"test","path":"/year","value":1}]`,
+			`[{"op":"remove","path":"/unknown"}]`,
+			`[{"op":"replace","path":"/missions/3","value":"x"}]`,
+			`[{"op":"add","path":"/year"}]`,
+			`[{"op":"launch","path":"/year"}]`,
+		}
+
+		for _, p := range patches {
+			if _, err := JSONPatch([]byte(doc), []byte(p)); err == nil {
+				t.Errorf("expected error applying %s", p)
+			}
+		}
+	})
+}
@@ -0,0 +1,209 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+	"github.com/LaQuannT/astronaut-data-api/internal/validation"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var errInvalidUserToken = model.NewError(model.KindInvalid, "token_invalid", "token is invalid, expired or already used")
+
+type accountUsecase struct {
+	userStore  model.UserStore
+	tokenStore model.UserTokenStore
+	mailer     model.Mailer
+	authURL    string
+	resetTTL   time.Duration
+	verifyTTL  time.Duration
+}
+
+// NewAccountUsecase mails tokens through m, password reset tokens last
+// resetTTL and email verification tokens verifyTTL. authURL is where the
+// auth endpoints are served, the mails point at it.
+func NewAccountUsecase(us model.UserStore, ts model.UserTokenStore, m model.Mailer, authURL string, resetTTL, verifyTTL time.Duration) *accountUsecase {
+	return &accountUsecase{
+		userStore:  us,
+		tokenStore: ts,
+		mailer:     m,
+		authURL:    authURL,
+		resetTTL:   resetTTL,
+		verifyTTL:  verifyTTL,
+	}
+}
+
+func (uc *accountUsecase) ForgotPassword(ctx context.Context, email string) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	u, err := uc.userStore.SearchEmail(ctx, email)
+	if errors.Is(err, model.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error searching for user by email: %w", err)
+	}
+
+	token, err := uc.issue(ctx, u, model.TokenPasswordReset, uc.resetTTL)
+	if err != nil {
+		return err
+	}
+
+	return uc.send(ctx, &model.Mail{
+		To:      u.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf(`Hi %s,
+
+someone asked to reset the password of your astronaut-data-api account. If it
+was you, send the token below with your new password to
+
+  POST %s/password/reset
+  {"token": "%s", "password": "..."}
+
+The token works once and expires in %s. If you didn't ask for a reset, ignore
+this email and your password stays the same.
+`, u.FirstName, uc.authURL, token, uc.resetTTL),
+	})
+}
+
+func (uc *accountUsecase) ResetPassword(ctx context.Context, token, password string) error {
+	v := validation.New(userValidatorRules)
+
+	checks := map[string]validation.Check{
+		"token":    {Value: token, RuleKey: []string{"require"}},
+		"password": {Value: password, RuleKey: []string{"require", "password"}},
+	}
+
+	if errs := v.Validate(checks); errs != nil {
+		return model.NewValidationError(errs)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+	if err != nil {
+		return fmt.Errorf("error generating password hash: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	_, err = uc.tokenStore.ResetPassword(ctx, hashToken(token), string(hash), time.Now().UTC())
+	if errors.Is(err, model.ErrNotFound) {
+		return errInvalidUserToken
+	}
+	if err != nil {
+		return fmt.Errorf("error resetting user password: %w", err)
+	}
+
+	return nil
+}
+
+// SendVerification mails u a token confirming their email, users already
+// verified are skipped
+func (uc *accountUsecase) SendVerification(ctx context.Context, u *model.User) error {
+	if u.EmailVerifiedAt != nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	token, err := uc.issue(ctx, u, model.TokenEmailVerification, uc.verifyTTL)
+	if err != nil {
+		return err
+	}
+
+	return uc.send(ctx, &model.Mail{
+		To:      u.Email,
+		Subject: "Verify your email",
+		Body: fmt.Sprintf(`Hi %s,
+
+confirm this is your email address by sending the token below to
+
+  POST %s/email/verify
+  {"token": "%s"}
+
+The token works once and expires in %s. Until then you can use your API key,
+but not log in with your password.
+`, u.FirstName, uc.authURL, token, uc.verifyTTL),
+	})
+}
+
+func (uc *accountUsecase) ResendVerification(ctx context.Context, email string) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	u, err := uc.userStore.SearchEmail(ctx, email)
+	if errors.Is(err, model.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error searching for user by email: %w", err)
+	}
+
+	return uc.SendVerification(ctx, u)
+}
+
+func (uc *accountUsecase) VerifyEmail(ctx context.Context, token string) error {
+	if token == "" {
+		return errInvalidUserToken
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	_, err := uc.tokenStore.VerifyEmail(ctx, hashToken(token), time.Now().UTC())
+	if errors.Is(err, model.ErrNotFound) {
+		return errInvalidUserToken
+	}
+	if err != nil {
+		return fmt.Errorf("error verifying email: %w", err)
+	}
+
+	return nil
+}
+
+// Purge deletes expired tokens, returning how many were removed
+func (uc *accountUsecase) Purge(ctx context.Context) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, bulkTimeout)
+	defer cancel()
+
+	n, err := uc.tokenStore.DeleteExpired(ctx, time.Now().UTC())
+	if err != nil {
+		return 0, fmt.Errorf("error purging user tokens: %w", err)
+	}
+	return n, nil
+}
+
+// issue stores a new token for u and its current email and returns it, only
+// its hash is kept
+func (uc *accountUsecase) issue(ctx context.Context, u *model.User, purpose string, ttl time.Duration) (string, error) {
+	token, err := randomToken()
+	if err != nil {
+		return "", fmt.Errorf("error generating %s token: %w", purpose, err)
+	}
+
+	t := &model.UserToken{
+		UserID:    u.ID,
+		Email:     u.Email,
+		Purpose:   purpose,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().UTC().Add(ttl),
+	}
+
+	if err := uc.tokenStore.Create(ctx, t); err != nil {
+		return "", fmt.Errorf("error storing %s token: %w", purpose, err)
+	}
+
+	return token, nil
+}
+
+func (uc *accountUsecase) send(ctx context.Context, m *model.Mail) error {
+	if err := uc.mailer.Send(ctx, m); err != nil {
+		return fmt.Errorf("error sending %q mail: %w", m.Subject, err)
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+)
+
+func TestNewAPIKey(t *testing.T) {
+	k := newAPIKey(defaultAPIKeyName, []string{model.PermissionAstronautsRead}, nil)
+
+	if k.Hash != hashToken(k.Key) || len(k.Hash) != 64 || strings.Contains(k.Hash, k.Key) {
+		t.Fatalf("expected the hash to be the sha-256 digest of the key, got %q", k.Hash)
+	}
+	if k.Prefix != k.Key[:apiKeyPrefixLength] {
+		t.Fatalf("expected prefix %q, got %q", k.Key[:apiKeyPrefixLength], k.Prefix)
+	}
+
+	// once issued only the digest and prefix are kept, the hash is never served
+	k.Key = ""
+	b, err := json.Marshal(k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), k.Hash) {
+		t.Fatalf("expected the hash to be left out of %s", b)
+	}
+}
+
+func TestSearchAPIKey(t *testing.T) {
+	s := newFakeUserStore()
+	uc := NewUserUsecase(s, newFakeRoleStore(), nil, nil)
+
+	u, err := uc.Create(context.Background(), newTestUser("jane@example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext := u.ApiKey
+
+	stored, ok := s.keys[hashToken(plaintext)]
+	if !ok || stored.Key != "" {
+		t.Fatalf("expected the key to be stored by its digest only, got %+v", s.keys)
+	}
+
+	t.Run("finds the key by its digest", func(t *testing.T) {
+		k, err := uc.SearchAPIKey(context.Background(), plaintext)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if k.ID != stored.ID || k.User == nil || k.User.Email != "jane@example.com" {
+			t.Fatalf("expected key %d of jane@example.com, got %+v", stored.ID, k)
+		}
+	})
+
+	t.Run("unknown key", func(t *testing.T) {
+		if _, err := uc.SearchAPIKey(context.Background(), stored.Hash); !errors.Is(err, model.ErrNotFound) {
+			t.Fatalf("expected the digest itself not to authenticate, got %v", err)
+		}
+	})
+
+	t.Run("revoked key", func(t *testing.T) {
+		revoked := time.Now().UTC().Add(-time.Minute)
+		stored.RevokedAt = &revoked
+		defer func() { stored.RevokedAt = nil }()
+
+		if _, err := uc.SearchAPIKey(context.Background(), plaintext); !errors.Is(err, errInactiveAPIKey) {
+			t.Fatalf("expected %v, got %v", errInactiveAPIKey, err)
+		}
+	})
+
+	t.Run("expired key", func(t *testing.T) {
+		expired := time.Now().UTC().Add(-time.Second)
+		stored.ExpiresAt = &expired
+		defer func() { stored.ExpiresAt = nil }()
+
+		if _, err := uc.SearchAPIKey(context.Background(), plaintext); !errors.Is(err, errInactiveAPIKey) {
+			t.Fatalf("expected %v, got %v", errInactiveAPIKey, err)
+		}
+	})
+}
+
+func TestValidateAPIKey(t *testing.T) {
+	future := time.Now().Add(time.Hour)
+	past := time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name      string
+		role      string
+		granted   []string
+		scopes    []string
+		expiresAt *time.Time
+		fields    []string
+	}{
+		{"narrower than the role", "editor", nil, []string{model.PermissionAstronautsRead}, &future, nil},
+		{"every permission of the role", "auditor", nil, []string{model.PermissionAstronautsRead, model.PermissionAstronautsAudit, model.PermissionUsersRead}, nil, nil},
+		{"beyond the role", "auditor", nil, []string{model.PermissionAstronautsRead, model.PermissionAstronautsWrite}, nil, []string{"scopes[1]"}},
+		{"beyond the creating credential", "editor", []string{model.PermissionAstronautsRead}, []string{model.PermissionAstronautsWrite}, nil, []string{"scopes[0]"}},
+		{"within the creating credential", "editor", []string{model.PermissionAstronautsRead, model.PermissionAstronautsWrite}, []string{model.PermissionAstronautsWrite}, nil, nil},
+		{"unknown permission", "editor", nil, []string{"astronauts:launch"}, nil, []string{"scopes[0]"}},
+		{"no scopes", "editor", nil, []string{}, nil, []string{"scopes"}},
+		{"expired", "editor", nil, []string{model.PermissionAstronautsRead}, &past, []string{"expiresAt"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := roleUser(t, tt.role)
+			k := &model.APIKey{Name: "ci", Scopes: tt.scopes, ExpiresAt: tt.expiresAt}
+
+			errs := validateAPIKey(requestContext(u, tt.granted), u, k)
+
+			var fields []string
+			for _, err := range errs {
+				var fe *model.FieldError
+				if !errors.As(err, &fe) {
+					t.Fatalf("expected field errors, got %v", err)
+				}
+				fields = append(fields, fe.Field)
+			}
+			if strings.Join(fields, ",") != strings.Join(tt.fields, ",") {
+				t.Fatalf("fields = %v, want %v", fields, tt.fields)
+			}
+		})
+	}
+}
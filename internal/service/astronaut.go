@@ -1,12 +1,15 @@
 package usecase
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"time"
 
+	"github.com/LaQuannT/astronaut-data-api/internal/dataset"
 	"github.com/LaQuannT/astronaut-data-api/internal/model"
-	"github.com/LaQuannT/astronaut-data-api/internal/transport/middleware"
 	"github.com/LaQuannT/astronaut-data-api/internal/validation"
 )
 
@@ -29,34 +32,13 @@ var astronautValidatorRules = validation.Rules{
 	"gender":  validation.Gender,
 }
 
-func (uc *astronautUsecase) Create(ctx context.Context, a *model.Astronaut) (*model.Astronaut, []error) {
-	errs := make([]error, 0)
-
-	requestUser, ok := ctx.Value(middleware.RequestUser).(*model.User)
-	if !ok {
-		err := errors.New("invalid request-user")
-		errs := append(errs, err)
-		return nil, errs
-	}
-
-	if requestUser.Role != model.AdminUser {
-		err := errors.New("user is not authorised")
-		errs := append(errs, err)
-		return nil, errs
+func (uc *astronautUsecase) Create(ctx context.Context, a *model.Astronaut) (*model.Astronaut, error) {
+	if err := authorize(ctx, model.PermissionAstronautsWrite); err != nil {
+		return nil, err
 	}
 
-	v := validation.New(astronautValidatorRules)
-
-	checks := map[string]validation.Check{
-		"name":        {Value: a.Name, RuleKey: []string{"require"}},
-		"status":      {Value: a.Status, RuleKey: []string{"status"}},
-		"birth date":  {Value: a.BirthDate, RuleKey: []string{"date"}},
-		"birth place": {Value: a.BirthPlace, RuleKey: []string{"require"}},
-		"gender":      {Value: a.Gender, RuleKey: []string{"gender"}},
-	}
-
-	if errs := v.Validate(checks); errs != nil {
-		return nil, errs
+	if err := validateAstronaut(a); err != nil {
+		return nil, err
 	}
 
 	ctx, cancel := context.WithTimeout(ctx, timeout)
@@ -64,15 +46,17 @@ func (uc *astronautUsecase) Create(ctx context.Context, a *model.Astronaut) (*mo
 
 	a, err := uc.astronautStore.Create(ctx, a)
 	if err != nil {
-		err := fmt.Errorf("error creating new astronaut: %w", err)
-		errs := append(errs, err)
-		return nil, errs
+		return nil, fmt.Errorf("error creating new astronaut: %w", err)
 	}
 
 	return a, nil
 }
 
 func (uc *astronautUsecase) List(ctx context.Context, limit, offset int) ([]*model.Astronaut, error) {
+	if err := authorize(ctx, model.PermissionAstronautsRead); err != nil {
+		return nil, err
+	}
+
 	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
@@ -84,11 +68,58 @@ func (uc *astronautUsecase) List(ctx context.Context, limit, offset int) ([]*mod
 	return astronauts, nil
 }
 
+func (uc *astronautUsecase) Count(ctx context.Context) (int, error) {
+	if err := authorize(ctx, model.PermissionAstronautsRead); err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	count, err := uc.astronautStore.Count(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error counting astronauts: %w", err)
+	}
+
+	return count, nil
+}
+
+func (uc *astronautUsecase) LastModified(ctx context.Context) (time.Time, error) {
+	if err := authorize(ctx, model.PermissionAstronautsRead); err != nil {
+		return time.Time{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	t, err := uc.astronautStore.LastModified(ctx)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("error fetching astronaut modification time: %w", err)
+	}
+
+	return t, nil
+}
+
 func (uc *astronautUsecase) Get(ctx context.Context, id int) (*model.Astronaut, error) {
+	if err := authorize(ctx, model.PermissionAstronautsRead); err != nil {
+		return nil, err
+	}
+
 	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
+	return uc.find(ctx, id)
+}
+
+// find fetches the astronaut with id, an id merged into another astronaut
+// fails with a MovedError
+func (uc *astronautUsecase) find(ctx context.Context, id int) (*model.Astronaut, error) {
 	a, err := uc.astronautStore.Get(ctx, id)
+	if errors.Is(err, model.ErrNotFound) {
+		if merr := uc.moved(ctx, id); merr != nil {
+			return nil, merr
+		}
+	}
 	if err != nil {
 		return nil, fmt.Errorf("error fetching astronaut data: %w", err)
 	}
@@ -96,22 +127,83 @@ func (uc *astronautUsecase) Get(ctx context.Context, id int) (*model.Astronaut,
 	return a, nil
 }
 
+// moved returns a MovedError if id was merged into another astronaut
+func (uc *astronautUsecase) moved(ctx context.Context, id int) error {
+	newID, err := uc.astronautStore.Redirect(ctx, id)
+	if err != nil {
+		return fmt.Errorf("error fetching astronaut redirect: %w", err)
+	}
+	if newID != 0 {
+		return &model.MovedError{ID: newID}
+	}
+	return nil
+}
+
+// Update replaces every field of an existing astronaut with the given values
 func (uc *astronautUsecase) Update(ctx context.Context, a *model.Astronaut) (*model.Astronaut, error) {
-	requestUser, ok := ctx.Value(middleware.RequestUser).(*model.User)
-	if !ok {
-		return nil, errors.New("invalid request-user")
+	if err := authorize(ctx, model.PermissionAstronautsWrite); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if _, err := uc.astronautStore.Get(ctx, a.ID); err != nil {
+		return nil, fmt.Errorf("error fetching original astronaut data: %w", err)
 	}
 
-	if requestUser.Role != model.AdminUser {
-		return nil, errors.New("user is not authorised")
+	if err := validateAstronaut(a); err != nil {
+		return nil, err
 	}
 
-	original, err := uc.astronautStore.Get(ctx, a.ID)
+	if err := uc.astronautStore.Update(ctx, a); err != nil {
+		return nil, fmt.Errorf("error updating astronaut data: %w", err)
+	}
+
+	return a, nil
+}
+
+// Patch applies a patch to the json form of an astronaut, members the patch
+// removes are reset to their zero value
+func (uc *astronautUsecase) Patch(ctx context.Context, id, version int, patch model.PatchFunc) (*model.Astronaut, error) {
+	if err := authorize(ctx, model.PermissionAstronautsWrite); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	original, err := uc.astronautStore.Get(ctx, id)
 	if err != nil {
 		return nil, fmt.Errorf("error fetching original astronaut data: %w", err)
 	}
 
-	a = compareAstronautData(original, a)
+	doc, err := json.Marshal(original)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding astronaut data: %w", err)
+	}
+
+	doc, err = patch(doc)
+	if err != nil {
+		return nil, model.WrapError(model.KindInvalid, "invalid_patch", "patch could not be applied", err)
+	}
+
+	a := new(model.Astronaut)
+
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(a); err != nil {
+		return nil, model.WrapError(model.KindInvalid, "invalid_patch", "patched astronaut is not valid", err)
+	}
+
+	if a.ID != id {
+		return nil, model.NewError(model.KindInvalid, "invalid_patch", "astronaut id cannot be patched")
+	}
+	a.Version = version
+
+	if err := validateAstronaut(a); err != nil {
+		return nil, err
+	}
 
 	if err := uc.astronautStore.Update(ctx, a); err != nil {
 		return nil, fmt.Errorf("error updating astronaut data: %w", err)
@@ -120,47 +212,272 @@ func (uc *astronautUsecase) Update(ctx context.Context, a *model.Astronaut) (*mo
 	return a, nil
 }
 
-func (uc *astronautUsecase) Delete(ctx context.Context, id int) error {
-	requestUser, ok := ctx.Value(middleware.RequestUser).(*model.User)
-	if !ok {
-		return errors.New("invalid request-user")
+func (uc *astronautUsecase) Delete(ctx context.Context, id, version int) error {
+	if err := authorize(ctx, model.PermissionAstronautsWrite); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if err := uc.astronautStore.Delete(ctx, id, version); err != nil {
+		return fmt.Errorf("error deleting astronaut: %w", err)
+	}
+
+	return nil
+}
+
+func (uc *astronautUsecase) History(ctx context.Context, id int) ([]*model.HistoryEntry, error) {
+	if err := authorize(ctx, model.PermissionAstronautsRead); err != nil {
+		return nil, err
 	}
 
-	if requestUser.Role != model.AdminUser {
-		return errors.New("user is not authorised")
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	history, err := uc.astronautStore.History(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching astronaut history: %w", err)
+	}
+
+	// merging moves the history to the surviving astronaut
+	if len(history) == 0 {
+		if err := uc.moved(ctx, id); err != nil {
+			return nil, err
+		}
+	}
+
+	return history, nil
+}
+
+func (uc *astronautUsecase) Crewmates(ctx context.Context, id int) ([]*model.Astronaut, error) {
+	if err := authorize(ctx, model.PermissionAstronautsRead); err != nil {
+		return nil, err
 	}
 
 	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
-	if err := uc.astronautStore.Delete(ctx, id); err != nil {
-		return fmt.Errorf("error deleting astronaut: %w", err)
+	if _, err := uc.find(ctx, id); err != nil {
+		return nil, err
+	}
+
+	astronauts, err := uc.astronautStore.Crewmates(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching crewmates: %w", err)
+	}
+
+	return astronauts, nil
+}
+
+func (uc *astronautUsecase) Duplicates(ctx context.Context, threshold float64) ([]*model.DuplicateCandidate, error) {
+	if err := authorize(ctx, model.PermissionAstronautsAudit); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, bulkTimeout)
+	defer cancel()
+
+	astronauts, err := uc.astronautStore.All(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching astronauts: %w", err)
+	}
+
+	return dataset.Duplicates(astronauts, threshold), nil
+}
+
+func (uc *astronautUsecase) Merge(ctx context.Context, winnerID, loserID int) (*model.Astronaut, error) {
+	if err := authorize(ctx, model.PermissionAstronautsWrite); err != nil {
+		return nil, err
+	}
+
+	if winnerID == loserID {
+		return nil, model.NewError(model.KindInvalid, "invalid_merge", "cannot merge an astronaut into itself")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	winner, err := uc.astronautStore.Get(ctx, winnerID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching astronaut %d: %w", winnerID, err)
+	}
+
+	loser, err := uc.astronautStore.Get(ctx, loserID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching astronaut %d: %w", loserID, err)
+	}
+
+	merged := dataset.Merge(winner, loser)
+
+	if err := uc.astronautStore.Merge(ctx, merged, loserID); err != nil {
+		return nil, fmt.Errorf("error merging astronauts: %w", err)
 	}
 
+	return merged, nil
+}
+
+func validateAstronaut(a *model.Astronaut) error {
+	v := validation.New(astronautValidatorRules)
+
+	checks := map[string]validation.Check{
+		"name":        {Value: a.Name, RuleKey: []string{"require"}},
+		"status":      {Value: a.Status, RuleKey: []string{"status"}},
+		"birth date":  {Value: a.BirthDate, RuleKey: []string{"date"}},
+		"birth place": {Value: a.BirthPlace, RuleKey: []string{"require"}},
+		"gender":      {Value: a.Gender, RuleKey: []string{"gender"}},
+	}
+
+	if errs := v.Validate(checks); errs != nil {
+		return model.NewValidationError(errs)
+	}
 	return nil
 }
 
-func compareAstronautData(old, new *model.Astronaut) *model.Astronaut {
-	if new.Name != "" && new.Name != old.Name {
-		old.Name = new.Name
+// maxBatchOperations bounds how long a batch holds its transaction open
+const maxBatchOperations = 100
+
+// Batch validates every operation before running any of them in one
+// transaction. An atomic batch is rolled back on the first failure, a
+// best-effort batch runs each operation in a savepoint and keeps the ones
+// that succeed. Failures are reported on the results, the error is only set
+// when the batch as a whole is rejected.
+func (uc *astronautUsecase) Batch(ctx context.Context, mode string, ops []*model.BatchOperation) ([]*model.BatchResult, error) {
+	if err := authorize(ctx, model.PermissionAstronautsWrite); err != nil {
+		return nil, err
+	}
+
+	if mode == "" {
+		mode = model.BatchAtomic
+	}
+	if mode != model.BatchAtomic && mode != model.BatchBestEffort {
+		return nil, model.NewError(model.KindInvalid, "invalid_batch_mode", fmt.Sprintf("mode must be %s or %s", model.BatchAtomic, model.BatchBestEffort))
+	}
+	if len(ops) == 0 || len(ops) > maxBatchOperations {
+		return nil, model.NewError(model.KindInvalid, "invalid_batch_size", fmt.Sprintf("a batch holds between 1 and %d operations", maxBatchOperations))
+	}
+
+	results := make([]*model.BatchResult, len(ops))
+	invalid := model.NewError(model.KindValidation, "validation_failed", "batch failed validation")
+
+	for i, op := range ops {
+		results[i] = &model.BatchResult{Index: i, Op: op.Op, ID: op.ID}
+
+		if err := validateBatchOperation(op); err != nil {
+			results[i].Err = err
+			for _, f := range err.Fields {
+				invalid.Fields = append(invalid.Fields, &model.FieldError{Field: fmt.Sprintf("operations[%d].%s", i, f.Field), Message: f.Message})
+			}
+		}
 	}
-	if new.Year != 0 && new.Year != old.Year {
-		old.Year = new.Year
+
+	if mode == model.BatchAtomic && len(invalid.Fields) > 0 {
+		return nil, invalid
 	}
-	if new.Group != 0 && new.Group != old.Group {
-		old.Group = new.Group
+
+	ctx, cancel := context.WithTimeout(ctx, bulkTimeout)
+	defer cancel()
+
+	err := uc.astronautStore.InTx(ctx, func(tx model.AstronautStore) error {
+		for i, op := range ops {
+			res := results[i]
+			if res.Err != nil {
+				continue
+			}
+
+			if mode == model.BatchAtomic {
+				if err := runBatchOperation(ctx, tx, op, res); err != nil {
+					res.Err = err
+					return err
+				}
+				continue
+			}
+
+			res.Err = tx.InTx(ctx, func(sp model.AstronautStore) error {
+				return runBatchOperation(ctx, sp, op, res)
+			})
+		}
+		return nil
+	})
+
+	if err != nil {
+		for _, res := range results {
+			if res.Err == nil {
+				res.Err = model.ErrBatchRolledBack
+				res.Astronaut = nil
+			}
+		}
+
+		var apiErr *model.ApiError
+		if !errors.As(err, &apiErr) || apiErr.Kind == model.KindInternal || apiErr.Kind == model.KindUnavailable || apiErr.Kind == model.KindTimeout {
+			return nil, fmt.Errorf("error running astronaut batch: %w", err)
+		}
 	}
-	if new.Status != "" && new.Status != old.Status {
-		old.Status = new.Status
+
+	return results, nil
+}
+
+func validateBatchOperation(op *model.BatchOperation) *model.ApiError {
+	var fields []*model.FieldError
+
+	switch op.Op {
+	case model.BatchCreate:
+	case model.BatchUpdate, model.BatchDelete:
+		if op.ID < 1 {
+			fields = append(fields, &model.FieldError{Field: "id", Message: "id is required"})
+		}
+		if op.Version == nil {
+			fields = append(fields, &model.FieldError{Field: "version", Message: "version is required, 0 skips the check"})
+		}
+	default:
+		fields = append(fields, &model.FieldError{Field: "op", Message: fmt.Sprintf("op must be %s, %s or %s", model.BatchCreate, model.BatchUpdate, model.BatchDelete)})
 	}
-	if new.BirthDate != "" && new.Status != old.Status {
-		old.Status = new.Status
+
+	if op.Op == model.BatchCreate || op.Op == model.BatchUpdate {
+		if op.Astronaut == nil {
+			fields = append(fields, &model.FieldError{Field: "astronaut", Message: "astronaut is required"})
+		} else if err := validateAstronaut(op.Astronaut); err != nil {
+			var apiErr *model.ApiError
+			errors.As(err, &apiErr)
+			for _, f := range apiErr.Fields {
+				fields = append(fields, &model.FieldError{Field: "astronaut." + f.Field, Message: f.Message})
+			}
+		}
 	}
-	if new.BirthPlace != "" && new.Status != old.BirthPlace {
-		old.BirthPlace = new.BirthPlace
+
+	if len(fields) == 0 {
+		return nil
 	}
-	if new.Gender != "" && new.Gender != old.Gender {
-		old.Gender = new.Gender
+
+	err := model.NewError(model.KindValidation, "validation_failed", "operation failed validation")
+	err.Fields = fields
+	return err
+}
+
+func runBatchOperation(ctx context.Context, s model.AstronautStore, op *model.BatchOperation, res *model.BatchResult) error {
+	switch op.Op {
+	case model.BatchCreate:
+		a, err := s.Create(ctx, op.Astronaut)
+		if err != nil {
+			return err
+		}
+		res.ID, res.Astronaut = a.ID, a
+
+	case model.BatchUpdate:
+		if _, err := s.Get(ctx, op.ID); err != nil {
+			return err
+		}
+
+		a := op.Astronaut
+		a.ID, a.Version = op.ID, *op.Version
+		if err := s.Update(ctx, a); err != nil {
+			return err
+		}
+		res.Astronaut = a
+
+	case model.BatchDelete:
+		return s.Delete(ctx, op.ID, *op.Version)
 	}
-	return old
+
+	return nil
 }
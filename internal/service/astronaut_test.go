@@ -0,0 +1,175 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+)
+
+func TestAstronautGetMerged(t *testing.T) {
+	s := newFakeAstronautStore(&model.Astronaut{Name: "joseph m. acaba"})
+	s.moved[2] = 1
+	uc := NewAstronautUsecase(s, nil)
+	ctx := requestContext(roleUser(t, "auditor"), nil)
+
+	lookups := map[string]func(id int) error{
+		"get": func(id int) error {
+			_, err := uc.Get(ctx, id)
+			return err
+		},
+		"history": func(id int) error {
+			_, err := uc.History(ctx, id)
+			return err
+		},
+		"crewmates": func(id int) error {
+			_, err := uc.Crewmates(ctx, id)
+			return err
+		},
+	}
+
+	for name, lookup := range lookups {
+		t.Run(name, func(t *testing.T) {
+			err := lookup(2)
+			var moved *model.MovedError
+			if !errors.As(err, &moved) || moved.ID != 1 {
+				t.Fatalf("expected astronaut 2 to have moved to 1, got %v", err)
+			}
+
+			if err := lookup(1); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+
+	if _, err := uc.Get(ctx, 3); !errors.Is(err, model.ErrNotFound) {
+		t.Fatalf("expected an unknown astronaut to be not found, got %v", err)
+	}
+}
+
+func newBatchAstronaut(name string) *model.Astronaut {
+	return &model.Astronaut{Name: name, Status: "active", BirthDate: "1/1/1960", BirthPlace: "houston, tx", Gender: "female"}
+}
+
+func TestAstronautBatch(t *testing.T) {
+	ctx := requestContext(roleUser(t, "editor"), nil)
+
+	newStore := func() *fakeAstronautStore {
+		return newFakeAstronautStore(newBatchAstronaut("sally ride"), newBatchAstronaut("mae jemison"))
+	}
+
+	// the update in the middle expects a stale version of sally ride
+	newOps := func() []*model.BatchOperation {
+		stale, current := 5, 1
+		return []*model.BatchOperation{
+			{Op: model.BatchCreate, Astronaut: newBatchAstronaut("eileen collins")},
+			{Op: model.BatchUpdate, ID: 1, Version: &stale, Astronaut: newBatchAstronaut("sally k. ride")},
+			{Op: model.BatchDelete, ID: 2, Version: &current},
+		}
+	}
+
+	names := func(s *fakeAstronautStore) []string {
+		all, _ := s.All(context.Background())
+		names := make([]string, 0, len(all))
+		for _, a := range all {
+			names = append(names, a.Name)
+		}
+		return names
+	}
+
+	t.Run("atomic rolls back every operation", func(t *testing.T) {
+		s := newStore()
+
+		results, err := NewAstronautUsecase(s, nil).Batch(ctx, model.BatchAtomic, newOps())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		want := []error{model.ErrBatchRolledBack, model.ErrVersionConflict, model.ErrBatchRolledBack}
+		for i, res := range results {
+			if !errors.Is(res.Err, want[i]) {
+				t.Errorf("operation %d: expected %v, got %v", i, want[i], res.Err)
+			}
+			if res.Astronaut != nil {
+				t.Errorf("operation %d: expected no astronaut for a rolled back operation", i)
+			}
+		}
+
+		if got := names(s); !reflect.DeepEqual(got, []string{"sally ride", "mae jemison"}) {
+			t.Fatalf("expected the table to be untouched, got %v", got)
+		}
+	})
+
+	t.Run("best effort keeps the successes", func(t *testing.T) {
+		s := newStore()
+
+		results, err := NewAstronautUsecase(s, nil).Batch(ctx, model.BatchBestEffort, newOps())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if res := results[0]; res.Err != nil || res.ID != 3 || res.Astronaut == nil {
+			t.Errorf("expected the create to succeed with id 3, got %+v", res)
+		}
+		if res := results[1]; !errors.Is(res.Err, model.ErrVersionConflict) {
+			t.Errorf("expected the update to fail with %v, got %v", model.ErrVersionConflict, res.Err)
+		}
+		if res := results[2]; res.Err != nil || res.ID != 2 {
+			t.Errorf("expected the delete of 2 to succeed, got %+v", res)
+		}
+
+		if got := names(s); !reflect.DeepEqual(got, []string{"sally ride", "eileen collins"}) {
+			t.Fatalf("expected the create and delete to be kept, got %v", got)
+		}
+	})
+
+	t.Run("best effort reports invalid operations", func(t *testing.T) {
+		s := newStore()
+		ops := []*model.BatchOperation{
+			{Op: model.BatchCreate, Astronaut: newBatchAstronaut("eileen collins")},
+			{Op: model.BatchDelete, ID: 2},
+		}
+
+		results, err := NewAstronautUsecase(s, nil).Batch(ctx, model.BatchBestEffort, ops)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if results[0].Err != nil || !errors.Is(results[1].Err, &model.ApiError{Kind: model.KindValidation}) {
+			t.Fatalf("expected only the delete without a version to fail, got %v and %v", results[0].Err, results[1].Err)
+		}
+	})
+
+	t.Run("atomic rejects invalid operations before running any", func(t *testing.T) {
+		s := newStore()
+		ops := []*model.BatchOperation{
+			{Op: model.BatchCreate, Astronaut: newBatchAstronaut("eileen collins")},
+			{Op: "launch"},
+		}
+
+		_, err := NewAstronautUsecase(s, nil).Batch(ctx, model.BatchAtomic, ops)
+		var apiErr *model.ApiError
+		if !errors.As(err, &apiErr) || apiErr.Kind != model.KindValidation || len(apiErr.Fields) != 1 || apiErr.Fields[0].Field != "operations[1].op" {
+			t.Fatalf("expected operations[1].op to fail validation, got %v", err)
+		}
+		if len(names(s)) != 2 {
+			t.Fatal("expected nothing to be created")
+		}
+	})
+
+	t.Run("batch size", func(t *testing.T) {
+		for _, n := range []int{0, maxBatchOperations + 1} {
+			ops := make([]*model.BatchOperation, n)
+			for i := range ops {
+				ops[i] = &model.BatchOperation{Op: model.BatchCreate, Astronaut: newBatchAstronaut("eileen collins")}
+			}
+
+			_, err := NewAstronautUsecase(newStore(), nil).Batch(ctx, model.BatchAtomic, ops)
+			var apiErr *model.ApiError
+			if !errors.As(err, &apiErr) || apiErr.Code != "invalid_batch_size" {
+				t.Fatalf("expected %d operations to be rejected, got %v", n, err)
+			}
+		}
+	})
+}
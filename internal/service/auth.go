@@ -0,0 +1,52 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+	"github.com/LaQuannT/astronaut-data-api/internal/transport/middleware"
+)
+
+var (
+	errInvalidRequestUser = model.NewError(model.KindUnauthenticated, "unauthenticated", "invalid request-user")
+	errNotAuthorised      = model.NewError(model.KindForbidden, "not_authorised", "user is not authorised")
+)
+
+func requestUserFrom(ctx context.Context) (*model.User, error) {
+	requestUser, ok := ctx.Value(middleware.RequestUser).(*model.User)
+	if !ok {
+		return nil, errInvalidRequestUser
+	}
+	return requestUser, nil
+}
+
+// authorize checks the role of the request user grants permission and the
+// credential of the request is scoped to it
+func authorize(ctx context.Context, permission string) error {
+	requestUser, err := requestUserFrom(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !slices.Contains(requestUser.Permissions, permission) {
+		return errNotAuthorised
+	}
+
+	return requireScope(ctx, permission)
+}
+
+// requestScopesFrom returns the scopes of the credential the request was
+// authenticated with
+func requestScopesFrom(ctx context.Context) []string {
+	scopes, _ := ctx.Value(middleware.RequestScopes).([]string)
+	return scopes
+}
+
+func requireScope(ctx context.Context, scope string) error {
+	if !slices.Contains(requestScopesFrom(ctx), scope) {
+		return model.NewError(model.KindForbidden, "insufficient_scope", fmt.Sprintf("credential lacks the %s scope", scope))
+	}
+	return nil
+}
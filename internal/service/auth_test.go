@@ -0,0 +1,89 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+)
+
+// roleUser is a user of one of the seeded roles
+func roleUser(t *testing.T, role string) *model.User {
+	t.Helper()
+	r, err := newFakeRoleStore().Get(context.Background(), role)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &model.User{ID: 1, Role: role, Permissions: r.Permissions}
+}
+
+func TestAuthorizeRoles(t *testing.T) {
+	allowed := map[string][]string{
+		"editor": {
+			model.PermissionAstronautsRead, model.PermissionAstronautsWrite, model.PermissionAstronautsAudit,
+			model.PermissionUsersRead, model.PermissionUsersWrite,
+		},
+		"auditor": {model.PermissionAstronautsRead, model.PermissionAstronautsAudit, model.PermissionUsersRead},
+	}
+
+	for role, permissions := range allowed {
+		ctx := requestContext(roleUser(t, role), nil)
+
+		for _, p := range model.Permissions {
+			t.Run(role+" "+p, func(t *testing.T) {
+				err := authorize(ctx, p)
+
+				if slices.Contains(permissions, p) {
+					if err != nil {
+						t.Fatalf("expected %s to be allowed, got %v", p, err)
+					}
+				} else if !errors.Is(err, errNotAuthorised) {
+					t.Fatalf("expected %s to be denied, got %v", p, err)
+				}
+			})
+		}
+	}
+}
+
+func TestAuthorizeScopes(t *testing.T) {
+	tests := []struct {
+		name       string
+		role       string
+		scopes     []string
+		permission string
+		code       string
+	}{
+		{"scope granted", "editor", []string{model.PermissionAstronautsWrite}, model.PermissionAstronautsWrite, ""},
+		{"scope missing", "editor", []string{model.PermissionAstronautsRead}, model.PermissionAstronautsWrite, "insufficient_scope"},
+		{"no scopes", "editor", []string{}, model.PermissionAstronautsRead, "insufficient_scope"},
+		{"scope beyond the role", "editor", []string{model.PermissionUsersAdmin}, model.PermissionUsersAdmin, "not_authorised"},
+		{"auditor scoped to write", "auditor", []string{model.PermissionAstronautsWrite}, model.PermissionAstronautsWrite, "not_authorised"},
+		{"auditor scope granted", "auditor", []string{model.PermissionAstronautsAudit}, model.PermissionAstronautsAudit, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := authorize(requestContext(roleUser(t, tt.role), tt.scopes), tt.permission)
+
+			if tt.code == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+
+			var apiErr *model.ApiError
+			if !errors.As(err, &apiErr) || apiErr.Kind != model.KindForbidden || apiErr.Code != tt.code {
+				t.Fatalf("expected a forbidden %s error, got %v", tt.code, err)
+			}
+		})
+	}
+}
+
+func TestAuthorizeWithoutUser(t *testing.T) {
+	if err := authorize(context.Background(), model.PermissionAstronautsRead); !errors.Is(err, model.ErrUnauthenticated) {
+		t.Fatalf("expected an unauthenticated error, got %v", err)
+	}
+}
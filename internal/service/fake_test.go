@@ -0,0 +1,379 @@
+package usecase
+
+import (
+	"context"
+	"maps"
+	"time"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+	"github.com/LaQuannT/astronaut-data-api/internal/transport/middleware"
+)
+
+// requestContext authenticates a request as u with a credential limited to
+// scopes, nil scopes grant every permission of u
+func requestContext(u *model.User, scopes []string) context.Context {
+	if scopes == nil {
+		scopes = u.Permissions
+	}
+	ctx := context.WithValue(context.Background(), middleware.RequestUser, u)
+	return context.WithValue(ctx, middleware.RequestScopes, scopes)
+}
+
+// fakeAstronautStore keeps astronauts in memory, InTx works on a copy that
+// replaces the rows only when fn succeeds. Methods the tests do not need
+// panic through the nil embedded store.
+type fakeAstronautStore struct {
+	model.AstronautStore
+
+	rows   map[int]*model.Astronaut
+	moved  map[int]int
+	nextID int
+	// failName makes any write of the astronaut with the name fail
+	failName string
+}
+
+var errFakeWrite = model.NewError(model.KindInternal, "fake_write", "write failed")
+
+func newFakeAstronautStore(astronauts ...*model.Astronaut) *fakeAstronautStore {
+	s := &fakeAstronautStore{rows: make(map[int]*model.Astronaut), moved: make(map[int]int)}
+	for _, a := range astronauts {
+		s.Create(context.Background(), a)
+	}
+	return s
+}
+
+func (s *fakeAstronautStore) Create(ctx context.Context, a *model.Astronaut) (*model.Astronaut, error) {
+	if a.Name == s.failName {
+		return nil, errFakeWrite
+	}
+	s.nextID++
+	a.ID = s.nextID
+	a.Version = 1
+	a.UpdatedAt = time.Now().UTC()
+	c := *a
+	s.rows[a.ID] = &c
+	return a, nil
+}
+
+func (s *fakeAstronautStore) Get(ctx context.Context, id int) (*model.Astronaut, error) {
+	a, ok := s.rows[id]
+	if !ok {
+		return nil, model.NewError(model.KindNotFound, "not_found", "astronaut not found")
+	}
+	c := *a
+	return &c, nil
+}
+
+func (s *fakeAstronautStore) All(ctx context.Context) ([]*model.Astronaut, error) {
+	astronauts := make([]*model.Astronaut, 0, len(s.rows))
+	for id := 1; id <= s.nextID; id++ {
+		if a, ok := s.rows[id]; ok {
+			c := *a
+			astronauts = append(astronauts, &c)
+		}
+	}
+	return astronauts, nil
+}
+
+func (s *fakeAstronautStore) Update(ctx context.Context, a *model.Astronaut) error {
+	old, ok := s.rows[a.ID]
+	switch {
+	case a.Name == s.failName:
+		return errFakeWrite
+	case !ok:
+		return model.NewError(model.KindNotFound, "not_found", "astronaut not found")
+	case a.Version != 0 && a.Version != old.Version:
+		return model.ErrVersionConflict
+	}
+	a.Version = old.Version + 1
+	a.UpdatedAt = time.Now().UTC()
+	c := *a
+	s.rows[a.ID] = &c
+	return nil
+}
+
+func (s *fakeAstronautStore) Delete(ctx context.Context, id, version int) error {
+	old, ok := s.rows[id]
+	switch {
+	case !ok:
+		return model.NewError(model.KindNotFound, "not_found", "astronaut not found")
+	case old.Name == s.failName:
+		return errFakeWrite
+	case version != 0 && version != old.Version:
+		return model.ErrVersionConflict
+	}
+	delete(s.rows, id)
+	return nil
+}
+
+func (s *fakeAstronautStore) Redirect(ctx context.Context, id int) (int, error) {
+	// like the store, ids that were never merged have no redirect
+	return s.moved[id], nil
+}
+
+// History keeps no entries, only ids that were merged away are told apart
+func (s *fakeAstronautStore) History(ctx context.Context, id int) ([]*model.HistoryEntry, error) {
+	return []*model.HistoryEntry{}, nil
+}
+
+func (s *fakeAstronautStore) Crewmates(ctx context.Context, id int) ([]*model.Astronaut, error) {
+	return []*model.Astronaut{}, nil
+}
+
+func (s *fakeAstronautStore) InTx(ctx context.Context, fn func(model.AstronautStore) error) error {
+	tx := &fakeAstronautStore{rows: maps.Clone(s.rows), moved: maps.Clone(s.moved), nextID: s.nextID, failName: s.failName}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	s.rows, s.moved, s.nextID = tx.rows, tx.moved, tx.nextID
+	return nil
+}
+
+// fakeUserStore keeps users and their keys in memory
+type fakeUserStore struct {
+	model.UserStore
+
+	users map[int]*model.User
+	keys  map[string]*model.APIKey
+}
+
+func newFakeUserStore() *fakeUserStore {
+	return &fakeUserStore{users: make(map[int]*model.User), keys: make(map[string]*model.APIKey)}
+}
+
+func (s *fakeUserStore) Create(ctx context.Context, u *model.User, k *model.APIKey) (int, error) {
+	for _, o := range s.users {
+		if o.Email == u.Email {
+			return 0, model.NewError(model.KindConflict, "conflict", "user already exists")
+		}
+	}
+	c := *u
+	c.ID = len(s.users) + 1
+	c.Version = 1
+	s.users[c.ID] = &c
+
+	k.UserID = c.ID
+	return c.ID, s.CreateAPIKey(ctx, k)
+}
+
+func (s *fakeUserStore) CountRole(ctx context.Context, role string) (int, error) {
+	n := 0
+	for _, u := range s.users {
+		if u.Role == role {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// Get leaves out the password hash like the store, only SearchEmail loads it
+func (s *fakeUserStore) Get(ctx context.Context, id int) (*model.User, error) {
+	u, ok := s.users[id]
+	if !ok {
+		return nil, model.NewError(model.KindNotFound, "not_found", "user not found")
+	}
+	c := *u
+	c.Password = ""
+	return &c, nil
+}
+
+func (s *fakeUserStore) SearchEmail(ctx context.Context, email string) (*model.User, error) {
+	for _, u := range s.users {
+		if u.Email == email {
+			c := *u
+			return &c, nil
+		}
+	}
+	return nil, model.NewError(model.KindNotFound, "not_found", "user not found")
+}
+
+func (s *fakeUserStore) Update(ctx context.Context, u *model.User) error {
+	old, ok := s.users[u.ID]
+	switch {
+	case !ok:
+		return model.NewError(model.KindNotFound, "not_found", "user not found")
+	case u.Version != 0 && u.Version != old.Version:
+		return model.ErrVersionConflict
+	}
+	c := *u
+	c.Version = old.Version + 1
+	c.Password = old.Password
+	if c.Email != old.Email {
+		c.EmailVerifiedAt = nil
+	} else {
+		c.EmailVerifiedAt = old.EmailVerifiedAt
+	}
+	u.Version = c.Version
+	s.users[u.ID] = &c
+	return nil
+}
+
+func (s *fakeUserStore) CreateAPIKey(ctx context.Context, k *model.APIKey) error {
+	c := *k
+	// like the api_key table only the digest and prefix are kept
+	c.Key = ""
+	c.ID = len(s.keys) + 1
+	k.ID = c.ID
+	s.keys[c.Hash] = &c
+	return nil
+}
+
+func (s *fakeUserStore) SearchAPIKeyHash(ctx context.Context, hash string) (*model.APIKey, error) {
+	k, ok := s.keys[hash]
+	if !ok {
+		return nil, model.NewError(model.KindNotFound, "not_found", "api key not found")
+	}
+	c := *k
+	c.User, _ = s.Get(ctx, k.UserID)
+	return &c, nil
+}
+
+// fakeRoleStore serves the builtin and seeded roles of the migrations
+type fakeRoleStore struct {
+	model.RoleStore
+
+	roles map[string]*model.Role
+}
+
+func newFakeRoleStore() *fakeRoleStore {
+	return &fakeRoleStore{roles: map[string]*model.Role{
+		model.AdminUser: {Name: model.AdminUser, Permissions: model.Permissions, Builtin: true, Version: 1},
+		model.BaseUser: {Name: model.BaseUser, Permissions: []string{
+			model.PermissionAstronautsRead, model.PermissionUsersRead, model.PermissionUsersWrite,
+		}, Builtin: true, Version: 1},
+		"editor": {Name: "editor", Permissions: []string{
+			model.PermissionAstronautsRead, model.PermissionAstronautsWrite, model.PermissionAstronautsAudit,
+			model.PermissionUsersRead, model.PermissionUsersWrite,
+		}, Version: 1},
+		"auditor": {Name: "auditor", Permissions: []string{
+			model.PermissionAstronautsRead, model.PermissionAstronautsAudit, model.PermissionUsersRead,
+		}, Version: 1},
+	}}
+}
+
+func (s *fakeRoleStore) Get(ctx context.Context, name string) (*model.Role, error) {
+	r, ok := s.roles[name]
+	if !ok {
+		return nil, model.NewError(model.KindNotFound, "not_found", "role not found")
+	}
+	c := *r
+	return &c, nil
+}
+
+func (s *fakeRoleStore) Delete(ctx context.Context, name string, version int) error {
+	if _, ok := s.roles[name]; !ok {
+		return model.NewError(model.KindNotFound, "not_found", "role not found")
+	}
+	delete(s.roles, name)
+	return nil
+}
+
+// fakeMailer records the mails it is asked to send
+type fakeMailer struct {
+	sent []*model.Mail
+}
+
+func (m *fakeMailer) Send(ctx context.Context, mail *model.Mail) error {
+	m.sent = append(m.sent, mail)
+	return nil
+}
+
+// fakeUserTokenStore keeps issued tokens in memory
+type fakeUserTokenStore struct {
+	model.UserTokenStore
+
+	tokens []*model.UserToken
+}
+
+func (s *fakeUserTokenStore) Create(ctx context.Context, t *model.UserToken) error {
+	t.ID = len(s.tokens) + 1
+	s.tokens = append(s.tokens, t)
+	return nil
+}
+
+// fakeInvitationStore keeps invitations in memory and creates accepted
+// admins in users
+type fakeInvitationStore struct {
+	model.InvitationStore
+
+	invitations map[string]*model.Invitation
+	users       *fakeUserStore
+}
+
+func newFakeInvitationStore(users *fakeUserStore) *fakeInvitationStore {
+	return &fakeInvitationStore{invitations: make(map[string]*model.Invitation), users: users}
+}
+
+func (s *fakeInvitationStore) Create(ctx context.Context, inv *model.Invitation) error {
+	inv.ID = len(s.invitations) + 1
+	inv.CreatedAt = time.Now().UTC()
+	c := *inv
+	s.invitations[inv.TokenHash] = &c
+	return nil
+}
+
+func (s *fakeInvitationStore) GetByHash(ctx context.Context, hash string) (*model.Invitation, error) {
+	inv, ok := s.invitations[hash]
+	if !ok {
+		return nil, model.NewError(model.KindNotFound, "not_found", "invitation not found")
+	}
+	c := *inv
+	return &c, nil
+}
+
+func (s *fakeInvitationStore) Accept(ctx context.Context, id int, u *model.User, k *model.APIKey, now time.Time) (int, error) {
+	for _, inv := range s.invitations {
+		if inv.ID != id || inv.AcceptedAt != nil || !now.Before(inv.ExpiresAt) {
+			continue
+		}
+		inv.AcceptedAt = &now
+		return s.users.Create(ctx, u, k)
+	}
+	return 0, model.NewError(model.KindNotFound, "not_found", "invitation not found")
+}
+
+// fakeLockoutStore counts failures in memory, since is kept to check the
+// window the caller asked for
+type fakeLockoutStore struct {
+	model.LockoutStore
+
+	failures map[string]*model.AuthFailure
+	events   []*model.SecurityEvent
+	since    time.Time
+}
+
+func newFakeLockoutStore() *fakeLockoutStore {
+	return &fakeLockoutStore{failures: make(map[string]*model.AuthFailure)}
+}
+
+func (s *fakeLockoutStore) Get(ctx context.Context, key string) (*model.AuthFailure, error) {
+	f, ok := s.failures[key]
+	if !ok {
+		return nil, model.NewError(model.KindNotFound, "not_found", "auth failure not found")
+	}
+	c := *f
+	return &c, nil
+}
+
+func (s *fakeLockoutStore) Fail(ctx context.Context, key string, now, since time.Time) (int, error) {
+	s.since = since
+	f, ok := s.failures[key]
+	if !ok || f.LastFailureAt.Before(since) {
+		f = &model.AuthFailure{Key: key}
+		s.failures[key] = f
+	}
+	f.Failures++
+	f.LastFailureAt = now
+	return f.Failures, nil
+}
+
+func (s *fakeLockoutStore) Delete(ctx context.Context, key string) error {
+	delete(s.failures, key)
+	return nil
+}
+
+func (s *fakeLockoutStore) CreateEvent(ctx context.Context, e *model.SecurityEvent) error {
+	e.ID = len(s.events) + 1
+	s.events = append(s.events, e)
+	return nil
+}
@@ -0,0 +1,88 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+)
+
+var (
+	errIdempotencyKeyReused     = model.NewError(model.KindValidation, "idempotency_key_reused", "idempotency key was already used with a different request")
+	errIdempotencyKeyInProgress = model.NewError(model.KindConflict, "idempotency_key_in_progress", "a request with this idempotency key is still being processed")
+)
+
+type idempotencyUsecase struct {
+	store model.IdempotencyStore
+	ttl   time.Duration
+}
+
+// NewIdempotencyUsecase keeps the first response to each key for ttl
+func NewIdempotencyUsecase(s model.IdempotencyStore, ttl time.Duration) *idempotencyUsecase {
+	return &idempotencyUsecase{
+		store: s,
+		ttl:   ttl,
+	}
+}
+
+// Begin claims the key of rec. It returns nil when the request should run,
+// or the stored record whose response should be replayed.
+func (uc *idempotencyUsecase) Begin(ctx context.Context, rec *model.IdempotencyRecord) (*model.IdempotencyRecord, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	existing, err := uc.store.Claim(ctx, rec, uc.expiry())
+	if err != nil {
+		return nil, fmt.Errorf("error claiming idempotency key: %w", err)
+	}
+
+	switch {
+	case existing == nil:
+		return nil, nil
+	case existing.RequestHash != rec.RequestHash:
+		return nil, errIdempotencyKeyReused
+	case existing.Status == 0:
+		return nil, errIdempotencyKeyInProgress
+	}
+
+	return existing, nil
+}
+
+func (uc *idempotencyUsecase) Complete(ctx context.Context, rec *model.IdempotencyRecord) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if err := uc.store.Complete(ctx, rec); err != nil {
+		return fmt.Errorf("error storing idempotent response: %w", err)
+	}
+	return nil
+}
+
+// Release frees a claimed key whose request failed, so a retry runs again
+func (uc *idempotencyUsecase) Release(ctx context.Context, userID int, key string) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if err := uc.store.Release(ctx, userID, key); err != nil {
+		return fmt.Errorf("error releasing idempotency key: %w", err)
+	}
+	return nil
+}
+
+// Purge deletes records older than the ttl, returning how many were removed
+func (uc *idempotencyUsecase) Purge(ctx context.Context) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, bulkTimeout)
+	defer cancel()
+
+	n, err := uc.store.DeleteExpired(ctx, uc.expiry())
+	if err != nil {
+		return 0, fmt.Errorf("error purging idempotency keys: %w", err)
+	}
+	return n, nil
+}
+
+// expiry is in utc like the timestamps postgres records
+func (uc *idempotencyUsecase) expiry() time.Time {
+	return time.Now().UTC().Add(-uc.ttl)
+}
@@ -0,0 +1,157 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+	"github.com/LaQuannT/astronaut-data-api/internal/validation"
+)
+
+type invitationUsecase struct {
+	store     model.InvitationStore
+	userStore model.UserStore
+	roleStore model.RoleStore
+	mailer    model.Mailer
+	authURL   string
+	ttl       time.Duration
+}
+
+// NewInvitationUsecase mails admin invitations through m that can be
+// accepted for ttl, authURL is where the auth endpoints are served
+func NewInvitationUsecase(is model.InvitationStore, us model.UserStore, rs model.RoleStore, m model.Mailer, authURL string, ttl time.Duration) *invitationUsecase {
+	return &invitationUsecase{
+		store:     is,
+		userStore: us,
+		roleStore: rs,
+		mailer:    m,
+		authURL:   authURL,
+		ttl:       ttl,
+	}
+}
+
+func (uc *invitationUsecase) Invite(ctx context.Context, email string) (*model.Invitation, error) {
+	requestUser, err := requestUserFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := authorize(ctx, model.PermissionUsersAdmin); err != nil {
+		return nil, err
+	}
+
+	v := validation.New(userValidatorRules)
+
+	checks := map[string]validation.Check{
+		"email": {Value: email, RuleKey: []string{"require", "email", "length"}},
+	}
+
+	if errs := v.Validate(checks); errs != nil {
+		return nil, model.NewValidationError(errs)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// registered users are promoted by changing their role instead
+	if _, err := uc.userStore.SearchEmail(ctx, email); err == nil {
+		return nil, errEmailTaken
+	} else if !errors.Is(err, model.ErrNotFound) {
+		return nil, fmt.Errorf("error searching for user by email: %w", err)
+	}
+
+	token, err := randomToken()
+	if err != nil {
+		return nil, fmt.Errorf("error generating invitation token: %w", err)
+	}
+
+	inv := &model.Invitation{
+		Email:     email,
+		TokenHash: hashToken(token),
+		InvitedBy: &requestUser.ID,
+		ExpiresAt: time.Now().UTC().Add(uc.ttl),
+	}
+
+	if err := uc.store.Create(ctx, inv); err != nil {
+		return nil, fmt.Errorf("error storing invitation: %w", err)
+	}
+
+	m := &model.Mail{
+		To:      email,
+		Subject: "You're invited to be an admin",
+		Body: fmt.Sprintf(`Hi,
+
+%s %s invited you to be an admin of astronaut-data-api. Create your account
+by sending the token below with your name and a password to
+
+  POST %s/invitations/accept
+  {"token": "%s", "firstName": "...", "surename": "...", "password": "..."}
+
+The invitation works once and expires in %s.
+`, requestUser.FirstName, requestUser.Surename, uc.authURL, token, uc.ttl),
+	}
+
+	if err := uc.mailer.Send(ctx, m); err != nil {
+		return nil, fmt.Errorf("error sending %q mail: %w", m.Subject, err)
+	}
+
+	return inv, nil
+}
+
+func (uc *invitationUsecase) Accept(ctx context.Context, token string, u *model.User) (*model.User, error) {
+	if token == "" {
+		return nil, errInvalidUserToken
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	now := time.Now().UTC()
+
+	inv, err := uc.store.GetByHash(ctx, hashToken(token))
+	if errors.Is(err, model.ErrNotFound) {
+		return nil, errInvalidUserToken
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching invitation: %w", err)
+	}
+
+	if inv.AcceptedAt != nil || !now.Before(inv.ExpiresAt) {
+		return nil, errInvalidUserToken
+	}
+
+	u.Email = inv.Email
+
+	k, err := prepareUser(ctx, uc.roleStore, u, model.AdminUser)
+	if err != nil {
+		return nil, err
+	}
+	// the invitation was mailed to the address, so it is verified
+	u.EmailVerifiedAt = &now
+
+	id, err := uc.store.Accept(ctx, inv.ID, u, k, now)
+	if errors.Is(err, model.ErrNotFound) {
+		return nil, errInvalidUserToken
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error accepting invitation: %w", err)
+	}
+
+	u.ID = id
+	u.ApiKey = k.Key
+	return u, nil
+}
+
+// Purge deletes expired invitations, returning how many were removed
+func (uc *invitationUsecase) Purge(ctx context.Context) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, bulkTimeout)
+	defer cancel()
+
+	n, err := uc.store.DeleteExpired(ctx, time.Now().UTC())
+	if err != nil {
+		return 0, fmt.Errorf("error purging invitations: %w", err)
+	}
+	return n, nil
+}
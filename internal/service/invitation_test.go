@@ -0,0 +1,79 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+)
+
+var mailedToken = regexp.MustCompile(`"token": "([^"]+)"`)
+
+func TestInvitation(t *testing.T) {
+	newUsecase := func() (*invitationUsecase, *fakeInvitationStore, *fakeMailer, context.Context) {
+		us := newFakeUserStore()
+		is := newFakeInvitationStore(us)
+		m := new(fakeMailer)
+
+		admin := newTestUser("admin@example.com")
+		admin.Role = model.AdminUser
+		admin.Permissions = model.Permissions
+		admin.ID, _ = us.Create(context.Background(), admin, newAPIKey(defaultAPIKeyName, admin.Permissions, nil))
+
+		return NewInvitationUsecase(is, us, newFakeRoleStore(), m, "http://localhost/api/v1/auth", time.Hour), is, m, requestContext(admin, nil)
+	}
+
+	invite := func(t *testing.T, uc *invitationUsecase, m *fakeMailer, ctx context.Context) string {
+		t.Helper()
+		if _, err := uc.Invite(ctx, "new@example.com"); err != nil {
+			t.Fatalf("unexpected error inviting: %v", err)
+		}
+		match := mailedToken.FindStringSubmatch(m.sent[len(m.sent)-1].Body)
+		if match == nil {
+			t.Fatal("expected the invitation mail to carry a token")
+		}
+		return match[1]
+	}
+
+	t.Run("accepts once", func(t *testing.T) {
+		uc, _, m, ctx := newUsecase()
+		token := invite(t, uc, m, ctx)
+
+		u, err := uc.Accept(context.Background(), token, newTestUser(""))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if u.Email != "new@example.com" || u.Role != model.AdminUser {
+			t.Fatalf("expected new@example.com to become an admin, got %+v", u)
+		}
+
+		if _, err := uc.Accept(context.Background(), token, newTestUser("")); !errors.Is(err, errInvalidUserToken) {
+			t.Fatalf("expected %v accepting twice, got %v", errInvalidUserToken, err)
+		}
+	})
+
+	t.Run("rejects expired invitations", func(t *testing.T) {
+		uc, is, m, ctx := newUsecase()
+		token := invite(t, uc, m, ctx)
+
+		is.invitations[hashToken(token)].ExpiresAt = time.Now().UTC().Add(-time.Minute)
+
+		if _, err := uc.Accept(context.Background(), token, newTestUser("")); !errors.Is(err, errInvalidUserToken) {
+			t.Fatalf("expected %v, got %v", errInvalidUserToken, err)
+		}
+	})
+
+	t.Run("rejects registered emails", func(t *testing.T) {
+		uc, is, m, ctx := newUsecase()
+
+		if _, err := uc.Invite(ctx, "admin@example.com"); !errors.Is(err, errEmailTaken) {
+			t.Fatalf("expected %v, got %v", errEmailTaken, err)
+		}
+		if len(is.invitations) != 0 || len(m.sent) != 0 {
+			t.Fatal("expected no invitation to be stored or mailed")
+		}
+	})
+}
@@ -0,0 +1,232 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+	"github.com/LaQuannT/astronaut-data-api/internal/transport/middleware"
+)
+
+const (
+	// freeFailures are allowed before attempts are slowed down
+	freeFailures = 3
+	// backoffBase is the wait after the first failure past freeFailures, it
+	// doubles with each further failure up to maxBackoff
+	backoffBase = time.Second
+	maxBackoff  = time.Minute
+	// accounts and client IPs are locked out for lockoutDuration once they
+	// reach their threshold, an IP may fail for several accounts behind a NAT
+	accountLockoutThreshold = 10
+	ipLockoutThreshold      = 50
+	lockoutDuration         = 15 * time.Minute
+	// failureWindow forgets failures older than it
+	failureWindow = time.Hour
+)
+
+type (
+	// lockoutKey names what failed attempts are counted for
+	lockoutKey struct {
+		key       string
+		threshold int
+		event     string
+	}
+
+	// lockout tracks failed attempts in store, a nil store disables it
+	lockout struct {
+		store model.LockoutStore
+	}
+)
+
+func accountKey(email string) lockoutKey {
+	return lockoutKey{key: "account:" + strings.ToLower(email), threshold: accountLockoutThreshold, event: model.EventAccountLocked}
+}
+
+func ipKey(ip string) lockoutKey {
+	return lockoutKey{key: "ip:" + ip, threshold: ipLockoutThreshold, event: model.EventIPLocked}
+}
+
+// requestKeys counts failures for the request's client IP too, when it is
+// known
+func requestKeys(ctx context.Context, keys ...lockoutKey) []lockoutKey {
+	if ip := requestIPFrom(ctx); ip != "" {
+		keys = append(keys, ipKey(ip))
+	}
+	return keys
+}
+
+func requestIPFrom(ctx context.Context) string {
+	ip, _ := ctx.Value(middleware.RequestIP).(string)
+	return ip
+}
+
+// blockedUntil is when the next attempt for f is allowed
+func (k lockoutKey) blockedUntil(f *model.AuthFailure) time.Time {
+	switch {
+	case f.Failures >= k.threshold:
+		return f.LastFailureAt.Add(lockoutDuration)
+	case f.Failures > freeFailures:
+		// the shift is capped before the duration could overflow
+		shift := min(f.Failures-freeFailures-1, 16)
+		return f.LastFailureAt.Add(min(backoffBase<<shift, maxBackoff))
+	}
+	return time.Time{}
+}
+
+// check fails with a 429 while any of keys is backing off or locked out
+func (l lockout) check(ctx context.Context, keys ...lockoutKey) error {
+	if l.store == nil {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	for _, k := range keys {
+		f, err := l.store.Get(ctx, k.key)
+		if errors.Is(err, model.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("error fetching failed attempts: %w", err)
+		}
+
+		if until := k.blockedUntil(f); now.Before(until) {
+			e := model.NewError(model.KindTooManyRequests, "too_many_failures", "too many failed attempts, retry later")
+			if f.Failures >= k.threshold {
+				e = model.NewError(model.KindTooManyRequests, "locked_out", "locked out after too many failed attempts")
+			}
+			e.RetryAfter = until.Sub(now)
+			return e
+		}
+	}
+
+	return nil
+}
+
+// fail counts a failed attempt for each of keys, recording a security event
+// for every key it locks out. userID is the account attempted, if it exists.
+func (l lockout) fail(ctx context.Context, userID *int, keys ...lockoutKey) error {
+	if l.store == nil {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	for _, k := range keys {
+		failures, err := l.store.Fail(ctx, k.key, now, now.Add(-failureWindow))
+		if err != nil {
+			return fmt.Errorf("error counting failed attempt: %w", err)
+		}
+
+		// attempts are refused while locked out, so each failure past the
+		// threshold starts a new lockout
+		if failures < k.threshold {
+			continue
+		}
+
+		e := &model.SecurityEvent{
+			Type:   k.event,
+			IP:     requestIPFrom(ctx),
+			Detail: fmt.Sprintf("%s locked for %s after %d failed attempts", k.key, lockoutDuration, failures),
+		}
+		if k.event == model.EventAccountLocked {
+			e.UserID = userID
+		}
+		if err := l.store.CreateEvent(ctx, e); err != nil {
+			return fmt.Errorf("error recording security event: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// reset forgets the failures of k after a successful attempt
+func (l lockout) reset(ctx context.Context, k lockoutKey) error {
+	if l.store == nil {
+		return nil
+	}
+
+	if err := l.store.Delete(ctx, k.key); err != nil {
+		return fmt.Errorf("error resetting failed attempts: %w", err)
+	}
+	return nil
+}
+
+type lockoutUsecase struct {
+	store     model.LockoutStore
+	userStore model.UserStore
+}
+
+func NewLockoutUsecase(s model.LockoutStore, us model.UserStore) *lockoutUsecase {
+	return &lockoutUsecase{
+		store:     s,
+		userStore: us,
+	}
+}
+
+// Unlock clears the failed logins of a user's account, the IPs they failed
+// from stay locked until their lockout ends
+func (uc *lockoutUsecase) Unlock(ctx context.Context, userID int) error {
+	if err := authorize(ctx, model.PermissionUsersAdmin); err != nil {
+		return err
+	}
+
+	requestUser, err := requestUserFrom(ctx)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	u, err := uc.userStore.Get(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("error fetching user: %w", err)
+	}
+
+	if err := uc.store.Delete(ctx, accountKey(u.Email).key); err != nil {
+		return fmt.Errorf("error unlocking account: %w", err)
+	}
+
+	e := &model.SecurityEvent{
+		Type:   model.EventAccountUnlocked,
+		UserID: &u.ID,
+		IP:     requestIPFrom(ctx),
+		Detail: fmt.Sprintf("unlocked by user %d", requestUser.ID),
+	}
+	if err := uc.store.CreateEvent(ctx, e); err != nil {
+		return fmt.Errorf("error recording security event: %w", err)
+	}
+
+	return nil
+}
+
+func (uc *lockoutUsecase) Events(ctx context.Context, limit, offset int) ([]*model.SecurityEvent, error) {
+	if err := authorize(ctx, model.PermissionUsersAdmin); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	events, err := uc.store.ListEvents(ctx, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("error listing security events: %w", err)
+	}
+
+	return events, nil
+}
+
+// Purge deletes failure counts past the failure window, returning how many
+// were removed
+func (uc *lockoutUsecase) Purge(ctx context.Context) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, bulkTimeout)
+	defer cancel()
+
+	n, err := uc.store.DeleteStale(ctx, time.Now().UTC().Add(-failureWindow))
+	if err != nil {
+		return 0, fmt.Errorf("error purging failed attempts: %w", err)
+	}
+	return n, nil
+}
@@ -0,0 +1,157 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+	"github.com/LaQuannT/astronaut-data-api/internal/transport/middleware"
+)
+
+func TestBlockedUntil(t *testing.T) {
+	last := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		threshold int
+		failures  int
+		wait      time.Duration
+	}{
+		{"no failures", 10, 0, 0},
+		{"free failures", 10, freeFailures, 0},
+		{"first backoff", 10, freeFailures + 1, backoffBase},
+		{"doubles", 10, freeFailures + 2, 2 * backoffBase},
+		{"doubles again", 10, freeFailures + 3, 4 * backoffBase},
+		{"last backoff before threshold", 10, 9, 32 * backoffBase},
+		{"capped at max backoff", 100, 10, maxBackoff},
+		{"shift capped before overflow", 100, 80, maxBackoff},
+		{"locked at threshold", 10, 10, lockoutDuration},
+		{"failure past threshold locks again", 10, 11, lockoutDuration},
+		{"ip threshold", ipLockoutThreshold, ipLockoutThreshold, lockoutDuration},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := lockoutKey{key: "account:jane@example.com", threshold: tt.threshold}
+
+			got := k.blockedUntil(&model.AuthFailure{Failures: tt.failures, LastFailureAt: last})
+
+			want := time.Time{}
+			if tt.wait > 0 {
+				want = last.Add(tt.wait)
+			}
+			if !got.Equal(want) {
+				t.Fatalf("blocked until %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestLockout(t *testing.T) {
+	userID := 7
+	ctx := context.WithValue(context.Background(), middleware.RequestIP, "192.0.2.1")
+
+	// checkCode returns the code check fails with, empty when allowed
+	checkCode := func(t *testing.T, l lockout, keys ...lockoutKey) string {
+		t.Helper()
+		err := l.check(ctx, keys...)
+		if err == nil {
+			return ""
+		}
+		var apiErr *model.ApiError
+		if !errors.As(err, &apiErr) || apiErr.Kind != model.KindTooManyRequests || apiErr.RetryAfter <= 0 {
+			t.Fatalf("expected a 429 with a retry after, got %v", err)
+		}
+		return apiErr.Code
+	}
+
+	t.Run("backs off after free failures", func(t *testing.T) {
+		s := newFakeLockoutStore()
+		l := lockout{store: s}
+		k := accountKey("Jane@example.com")
+
+		for i := 0; i < freeFailures; i++ {
+			l.fail(ctx, &userID, k)
+		}
+		if code := checkCode(t, l, k); code != "" {
+			t.Fatalf("expected free failures to be allowed, got %s", code)
+		}
+
+		l.fail(ctx, &userID, k)
+		if code := checkCode(t, l, k); code != "too_many_failures" {
+			t.Fatalf("expected too_many_failures, got %q", code)
+		}
+
+		if want := time.Now().UTC().Add(-failureWindow); s.since.Sub(want).Abs() > time.Second {
+			t.Fatalf("expected failures since %v to count, got %v", want, s.since)
+		}
+	})
+
+	t.Run("records an event once the threshold is reached", func(t *testing.T) {
+		s := newFakeLockoutStore()
+		l := lockout{store: s}
+		keys := requestKeys(ctx, accountKey("jane@example.com"))
+
+		for i := 1; i < accountLockoutThreshold; i++ {
+			l.fail(ctx, &userID, keys...)
+		}
+		if len(s.events) != 0 {
+			t.Fatalf("expected no event below the threshold, got %d", len(s.events))
+		}
+
+		l.fail(ctx, &userID, keys...)
+		if len(s.events) != 1 {
+			t.Fatalf("expected 1 event at the threshold, got %d", len(s.events))
+		}
+		if e := s.events[0]; e.Type != model.EventAccountLocked || e.UserID == nil || *e.UserID != userID || e.IP != "192.0.2.1" {
+			t.Fatalf("expected user %d locked from 192.0.2.1, got %+v", userID, e)
+		}
+		if code := checkCode(t, l, keys...); code != "locked_out" {
+			t.Fatalf("expected locked_out, got %q", code)
+		}
+
+		l.fail(ctx, &userID, keys...)
+		if len(s.events) != 2 {
+			t.Fatalf("expected a failure past the threshold to lock again, got %d events", len(s.events))
+		}
+	})
+
+	t.Run("locks client IPs without a user", func(t *testing.T) {
+		s := newFakeLockoutStore()
+		l := lockout{store: s}
+		k := ipKey("192.0.2.1")
+
+		for i := 0; i < ipLockoutThreshold; i++ {
+			l.fail(ctx, &userID, k)
+		}
+		if len(s.events) != 1 || s.events[0].Type != model.EventIPLocked || s.events[0].UserID != nil {
+			t.Fatalf("expected one ip_locked event without a user, got %+v", s.events)
+		}
+	})
+
+	t.Run("reset forgets failures", func(t *testing.T) {
+		s := newFakeLockoutStore()
+		l := lockout{store: s}
+		k := accountKey("jane@example.com")
+
+		for i := 0; i < accountLockoutThreshold; i++ {
+			l.fail(ctx, &userID, k)
+		}
+		l.reset(ctx, k)
+		if code := checkCode(t, l, k); code != "" {
+			t.Fatalf("expected a reset account to be allowed, got %s", code)
+		}
+	})
+
+	t.Run("nil store disables it", func(t *testing.T) {
+		var l lockout
+		if err := l.fail(ctx, &userID, accountKey("jane@example.com")); err != nil {
+			t.Fatal(err)
+		}
+		if code := checkCode(t, l, accountKey("jane@example.com")); code != "" {
+			t.Fatalf("expected no lockout, got %s", code)
+		}
+	})
+}
@@ -0,0 +1,40 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/dataset"
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+)
+
+type dataQualityUsecase struct {
+	astronautStore model.AstronautStore
+}
+
+func NewDataQualityUsecase(as model.AstronautStore) *dataQualityUsecase {
+	return &dataQualityUsecase{
+		astronautStore: as,
+	}
+}
+
+func (uc *dataQualityUsecase) Report(ctx context.Context) ([]*model.QualityFinding, error) {
+	if err := authorize(ctx, model.PermissionAstronautsAudit); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, bulkTimeout)
+	defer cancel()
+
+	astronauts, err := uc.astronautStore.All(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching astronauts: %w", err)
+	}
+
+	findings := make([]*model.QualityFinding, 0)
+	for _, a := range astronauts {
+		findings = append(findings, dataset.Check(a)...)
+	}
+
+	return findings, nil
+}
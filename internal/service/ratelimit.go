@@ -0,0 +1,67 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+)
+
+// rateLimitIdle is how long a bucket is kept after its last request, a
+// bucket refilled by then is the same as a new one
+const rateLimitIdle = time.Hour
+
+type rateLimitUsecase struct {
+	store  model.RateLimitStore
+	limits model.RateLimits
+}
+
+func NewRateLimitUsecase(s model.RateLimitStore, limits model.RateLimits) *rateLimitUsecase {
+	return &rateLimitUsecase{
+		store:  s,
+		limits: limits,
+	}
+}
+
+func (uc *rateLimitUsecase) Allow(ctx context.Context, key, role string) (*model.RateLimitResult, error) {
+	l := uc.limit(role)
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	b, taken, err := uc.store.Take(ctx, key, l, time.Now().UTC())
+	if err != nil {
+		return nil, fmt.Errorf("error taking rate limit token: %w", err)
+	}
+
+	return &model.RateLimitResult{
+		Allowed:    taken,
+		Limit:      l.Requests,
+		Remaining:  int(math.Floor(b.Tokens)),
+		RetryAfter: b.Wait(l),
+	}, nil
+}
+
+// Purge deletes idle buckets, returning how many were removed
+func (uc *rateLimitUsecase) Purge(ctx context.Context) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, bulkTimeout)
+	defer cancel()
+
+	n, err := uc.store.DeleteIdle(ctx, time.Now().UTC().Add(-rateLimitIdle))
+	if err != nil {
+		return 0, fmt.Errorf("error purging rate limit buckets: %w", err)
+	}
+	return n, nil
+}
+
+func (uc *rateLimitUsecase) limit(role string) model.RateLimit {
+	if role == "" {
+		return uc.limits.Anonymous
+	}
+	if l, ok := uc.limits.Roles[role]; ok {
+		return l
+	}
+	return uc.limits.Default
+}
@@ -0,0 +1,95 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/dataset"
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+)
+
+// bulkTimeout applies to operations touching every astronaut row
+var bulkTimeout = 60 * time.Second
+
+type reconcileUsecase struct {
+	astronautStore model.AstronautStore
+}
+
+func NewReconcileUsecase(as model.AstronautStore) *reconcileUsecase {
+	return &reconcileUsecase{
+		astronautStore: as,
+	}
+}
+
+func (uc *reconcileUsecase) Diff(ctx context.Context, r io.Reader) (*model.DatasetDiff, error) {
+	if err := authorize(ctx, model.PermissionAstronautsAudit); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, bulkTimeout)
+	defer cancel()
+
+	return uc.diff(ctx, r)
+}
+
+func (uc *reconcileUsecase) Apply(ctx context.Context, r io.Reader, keys []string) (*model.DatasetDiff, error) {
+	if err := authorize(ctx, model.PermissionAstronautsWrite); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, bulkTimeout)
+	defer cancel()
+
+	diff, err := uc.diff(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+
+	diff = dataset.Select(diff, keys)
+
+	// a failing change rolls back the ones before it, so the table is never
+	// left half reconciled
+	err = uc.astronautStore.InTx(ctx, func(tx model.AstronautStore) error {
+		for _, c := range diff.Added {
+			if _, err := tx.Create(ctx, c.Astronaut); err != nil {
+				return fmt.Errorf("error adding astronaut %q: %w", c.Key, err)
+			}
+			c.ID = c.Astronaut.ID
+		}
+
+		for _, c := range diff.Changed {
+			if err := tx.Update(ctx, c.Astronaut); err != nil {
+				return fmt.Errorf("error updating astronaut %q: %w", c.Key, err)
+			}
+		}
+
+		for _, c := range diff.Removed {
+			if err := tx.Delete(ctx, c.ID, c.Astronaut.Version); err != nil {
+				return fmt.Errorf("error removing astronaut %q: %w", c.Key, err)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("dataset was not applied: %w", err)
+	}
+
+	return diff, nil
+}
+
+func (uc *reconcileUsecase) diff(ctx context.Context, r io.Reader) (*model.DatasetDiff, error) {
+	incoming, err := dataset.Load(r)
+	if err != nil {
+		return nil, model.WrapError(model.KindInvalid, "invalid_dataset", "dataset is not valid csv", err)
+	}
+
+	current, err := uc.astronautStore.All(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching astronauts: %w", err)
+	}
+
+	return dataset.Diff(current, incoming), nil
+}
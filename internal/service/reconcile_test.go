@@ -0,0 +1,66 @@
+package usecase
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+)
+
+const reconcileCSV = `Name,Year,Group,Status,Birth Date,Birth Place,Gender,Alma Mater,Undergraduate Major,Graduate Major,Military Rank,Military Branch,Space Flights,Space Flight (hr),Space Walks,Space Walk (hr),Missions,Death Date,Death Mission
+Joseph M. Acaba,2004,19,Active,5/17/1967,"Inglewood, CA",Male,,,,,,2,3307,2,13,,,
+Loren W. Acton,,,Retired,3/7/1936,"Lewiston, MT",Male,,,,,,1,190,0,0,,,
+`
+
+func TestReconcileApply(t *testing.T) {
+	editor := &model.User{ID: 1, Role: "editor", Permissions: []string{model.PermissionAstronautsWrite}}
+	ctx := requestContext(editor, nil)
+
+	newStore := func() *fakeAstronautStore {
+		return newFakeAstronautStore(
+			&model.Astronaut{Name: "joseph m. acaba", Status: "retired", BirthDate: "5/17/1967"},
+			&model.Astronaut{Name: "john doe", BirthDate: "1/1/1950"},
+		)
+	}
+
+	t.Run("applies every change", func(t *testing.T) {
+		s := newStore()
+
+		diff, err := NewReconcileUsecase(s).Apply(ctx, strings.NewReader(reconcileCSV), nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(diff.Added) != 1 || len(diff.Changed) != 1 || len(diff.Removed) != 1 {
+			t.Fatalf("expected one change of each type, got %+v", diff)
+		}
+
+		all, _ := s.All(context.Background())
+		names := make([]string, 0, len(all))
+		for _, a := range all {
+			names = append(names, a.Name)
+		}
+		if want := []string{"joseph m. acaba", "loren w. acton"}; !reflect.DeepEqual(names, want) {
+			t.Fatalf("expected astronauts %v, got %v", want, names)
+		}
+	})
+
+	t.Run("failing change leaves the table untouched", func(t *testing.T) {
+		s := newStore()
+		before, _ := s.All(context.Background())
+
+		// acton is added before acaba's update fails and john doe is removed after
+		s.failName = "joseph m. acaba"
+
+		if _, err := NewReconcileUsecase(s).Apply(ctx, strings.NewReader(reconcileCSV), nil); err == nil {
+			t.Fatal("expected the failing update to fail the apply")
+		}
+
+		after, _ := s.All(context.Background())
+		if !reflect.DeepEqual(before, after) {
+			t.Fatalf("expected astronauts to be untouched, had %d and got %d", len(before), len(after))
+		}
+	})
+}
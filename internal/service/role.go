@@ -0,0 +1,175 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+	"github.com/LaQuannT/astronaut-data-api/internal/validation"
+)
+
+var (
+	errBuiltinRole   = model.NewError(model.KindConflict, "role_builtin", "builtin roles can not be deleted")
+	errImmutableRole = model.NewError(model.KindConflict, "role_immutable", "the admin role can not be changed")
+)
+
+type roleUsecase struct {
+	store model.RoleStore
+}
+
+func NewRoleUsecase(s model.RoleStore) *roleUsecase {
+	return &roleUsecase{
+		store: s,
+	}
+}
+
+var roleValidatorRules = validation.Rules{
+	"require": validation.Required,
+	"length":  validation.Length(50),
+	"text":    validation.Length(255),
+}
+
+func (uc *roleUsecase) List(ctx context.Context) ([]*model.Role, error) {
+	if err := authorize(ctx, model.PermissionRolesAdmin); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	roles, err := uc.store.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error listing roles: %w", err)
+	}
+
+	return roles, nil
+}
+
+func (uc *roleUsecase) Get(ctx context.Context, name string) (*model.Role, error) {
+	if err := authorize(ctx, model.PermissionRolesAdmin); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	r, err := uc.store.Get(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching role: %w", err)
+	}
+
+	return r, nil
+}
+
+func (uc *roleUsecase) Create(ctx context.Context, r *model.Role) (*model.Role, error) {
+	if err := authorize(ctx, model.PermissionRolesAdmin); err != nil {
+		return nil, err
+	}
+
+	v := validation.New(roleValidatorRules)
+
+	errs := v.Validate(map[string]validation.Check{
+		"name":        {Value: r.Name, RuleKey: []string{"require", "length"}},
+		"description": {Value: r.Description, RuleKey: []string{"text"}},
+	})
+	if errs = append(errs, validatePermissions(r.Permissions)...); errs != nil {
+		return nil, model.NewValidationError(errs)
+	}
+
+	r.Builtin = false
+	r.CreatedAt = time.Now().UTC()
+	r.UpdatedAt = r.CreatedAt
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if err := uc.store.Create(ctx, r); err != nil {
+		return nil, fmt.Errorf("error creating role: %w", err)
+	}
+
+	return r, nil
+}
+
+// Update replaces the description and permissions of a role, users of the
+// role get the new permissions on their next request
+func (uc *roleUsecase) Update(ctx context.Context, r *model.Role) (*model.Role, error) {
+	if err := authorize(ctx, model.PermissionRolesAdmin); err != nil {
+		return nil, err
+	}
+
+	// the admin role always holds roles:admin, so it can not lock itself out
+	if r.Name == model.AdminUser {
+		return nil, errImmutableRole
+	}
+
+	v := validation.New(roleValidatorRules)
+
+	errs := v.Validate(map[string]validation.Check{
+		"description": {Value: r.Description, RuleKey: []string{"text"}},
+	})
+	if errs = append(errs, validatePermissions(r.Permissions)...); errs != nil {
+		return nil, model.NewValidationError(errs)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	original, err := uc.store.Get(ctx, r.Name)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching role: %w", err)
+	}
+
+	original.Description = r.Description
+	original.Permissions = r.Permissions
+	original.Version = r.Version
+	original.UpdatedAt = time.Now().UTC()
+
+	if err := uc.store.Update(ctx, original); err != nil {
+		return nil, fmt.Errorf("error updating role: %w", err)
+	}
+
+	return original, nil
+}
+
+// Delete fails with a conflict while users still have the role
+func (uc *roleUsecase) Delete(ctx context.Context, name string, version int) error {
+	if err := authorize(ctx, model.PermissionRolesAdmin); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	r, err := uc.store.Get(ctx, name)
+	if err != nil {
+		return fmt.Errorf("error fetching role: %w", err)
+	}
+
+	if r.Builtin {
+		return errBuiltinRole
+	}
+
+	if err := uc.store.Delete(ctx, name, version); err != nil {
+		return fmt.Errorf("error deleting role: %w", err)
+	}
+
+	return nil
+}
+
+func validatePermissions(permissions []string) []error {
+	var errs []error
+
+	if len(permissions) == 0 {
+		errs = append(errs, &model.FieldError{Field: "permissions", Message: "permissions must not be empty"})
+	}
+
+	for i, p := range permissions {
+		if !slices.Contains(model.Permissions, p) {
+			errs = append(errs, &model.FieldError{Field: fmt.Sprintf("permissions[%d]", i), Message: fmt.Sprintf("%s is not a permission", p)})
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,41 @@
+package usecase
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+)
+
+func TestBuiltinRoles(t *testing.T) {
+	uc := NewRoleUsecase(newFakeRoleStore())
+	ctx := requestContext(roleUser(t, model.AdminUser), nil)
+
+	t.Run("admin role can not be edited", func(t *testing.T) {
+		r := &model.Role{Name: model.AdminUser, Permissions: []string{model.PermissionAstronautsRead}, Version: 1}
+		if _, err := uc.Update(ctx, r); !errors.Is(err, errImmutableRole) {
+			t.Fatalf("expected %v, got %v", errImmutableRole, err)
+		}
+	})
+
+	t.Run("builtin roles can not be deleted", func(t *testing.T) {
+		for _, name := range []string{model.AdminUser, model.BaseUser} {
+			if err := uc.Delete(ctx, name, 1); !errors.Is(err, errBuiltinRole) {
+				t.Fatalf("expected %v deleting %s, got %v", errBuiltinRole, name, err)
+			}
+		}
+	})
+
+	t.Run("custom roles can be deleted", func(t *testing.T) {
+		if err := uc.Delete(ctx, "auditor", 1); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("roles take roles:admin", func(t *testing.T) {
+		editor := requestContext(roleUser(t, "editor"), nil)
+		if err := uc.Delete(editor, "auditor", 1); !errors.Is(err, errNotAuthorised) {
+			t.Fatalf("expected %v, got %v", errNotAuthorised, err)
+		}
+	})
+}
@@ -0,0 +1,277 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/auth"
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const tokenType = "Bearer"
+
+var (
+	errInvalidCredentials  = model.NewError(model.KindUnauthenticated, "invalid_credentials", "email or password is incorrect")
+	errInvalidRefreshToken = model.NewError(model.KindUnauthenticated, "invalid_refresh_token", "refresh token is invalid, expired or revoked")
+	errInvalidAccessToken  = model.NewError(model.KindUnauthenticated, "invalid_token", "access token is invalid")
+	errExpiredAccessToken  = model.NewError(model.KindUnauthenticated, "token_expired", "access token has expired")
+	errEmailUnverified     = model.NewError(model.KindForbidden, "email_unverified", "email address is not verified")
+)
+
+// dummyHash is compared against when no user has the email, so unknown
+// addresses take as long to reject as wrong passwords
+var dummyHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("not-a-real-password"), 12)
+	return hash
+})
+
+type authUsecase struct {
+	userStore  model.UserStore
+	tokenStore model.RefreshTokenStore
+	lockout    lockout
+	signer     *auth.Signer
+	refreshTTL time.Duration
+}
+
+// NewAuthUsecase issues access tokens with signer and refresh tokens valid
+// for refreshTTL. Failed logins are counted in ls, a nil ls leaves them
+// unlimited.
+func NewAuthUsecase(us model.UserStore, ts model.RefreshTokenStore, ls model.LockoutStore, signer *auth.Signer, refreshTTL time.Duration) *authUsecase {
+	return &authUsecase{
+		userStore:  us,
+		tokenStore: ts,
+		lockout:    lockout{store: ls},
+		signer:     signer,
+		refreshTTL: refreshTTL,
+	}
+}
+
+func (uc *authUsecase) Login(ctx context.Context, c *model.Credentials) (*model.TokenPair, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// accounts are tracked by email whether or not it is registered, so
+	// lockouts do not reveal which emails are
+	account := accountKey(c.Email)
+	keys := requestKeys(ctx, account)
+	if err := uc.lockout.check(ctx, keys...); err != nil {
+		return nil, err
+	}
+
+	u, err := uc.userStore.SearchEmail(ctx, c.Email)
+	if errors.Is(err, model.ErrNotFound) {
+		bcrypt.CompareHashAndPassword(dummyHash(), []byte(c.Password))
+		if err := uc.lockout.fail(ctx, nil, keys...); err != nil {
+			return nil, err
+		}
+		return nil, errInvalidCredentials
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error searching for user by email: %w", err)
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(c.Password)); err != nil {
+		if err := uc.lockout.fail(ctx, &u.ID, keys...); err != nil {
+			return nil, err
+		}
+		return nil, errInvalidCredentials
+	}
+
+	if err := uc.lockout.reset(ctx, account); err != nil {
+		return nil, err
+	}
+
+	// API keys keep working, only password logins wait for the email
+	if u.EmailVerifiedAt == nil {
+		return nil, errEmailUnverified
+	}
+
+	refresh, t, err := uc.newRefreshToken(u.ID, uuid.NewString())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := uc.tokenStore.Create(ctx, t); err != nil {
+		return nil, fmt.Errorf("error storing refresh token: %w", err)
+	}
+
+	return uc.tokenPair(u, refresh)
+}
+
+// Refresh swaps a refresh token for a new pair. Presenting a token that was
+// already rotated means it leaked, every token of its family is revoked.
+func (uc *authUsecase) Refresh(ctx context.Context, token string) (*model.TokenPair, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	old, err := uc.lookup(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	if old.RevokedAt != nil {
+		if err := uc.tokenStore.RevokeFamily(ctx, old.Family); err != nil {
+			return nil, fmt.Errorf("error revoking refresh token family: %w", err)
+		}
+		return nil, errInvalidRefreshToken
+	}
+
+	if !time.Now().UTC().Before(old.ExpiresAt) {
+		return nil, errInvalidRefreshToken
+	}
+
+	u, err := uc.userStore.Get(ctx, old.UserID)
+	if errors.Is(err, model.ErrNotFound) {
+		return nil, errInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching refresh token user: %w", err)
+	}
+
+	refresh, next, err := uc.newRefreshToken(u.ID, old.Family)
+	if err != nil {
+		return nil, err
+	}
+
+	// losing a race with another refresh of the same token is reuse too
+	if err := uc.tokenStore.Rotate(ctx, old, next); errors.Is(err, model.ErrConflict) {
+		if err := uc.tokenStore.RevokeFamily(ctx, old.Family); err != nil {
+			return nil, fmt.Errorf("error revoking refresh token family: %w", err)
+		}
+		return nil, errInvalidRefreshToken
+	} else if err != nil {
+		return nil, fmt.Errorf("error rotating refresh token: %w", err)
+	}
+
+	return uc.tokenPair(u, refresh)
+}
+
+// Logout revokes the family of token, ending the session it belongs to.
+// Access tokens already issued stay valid until they expire.
+func (uc *authUsecase) Logout(ctx context.Context, token string) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	t, err := uc.lookup(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	if err := uc.tokenStore.RevokeFamily(ctx, t.Family); err != nil {
+		return fmt.Errorf("error revoking refresh token family: %w", err)
+	}
+	return nil
+}
+
+func (uc *authUsecase) Authenticate(ctx context.Context, accessToken string) (*model.User, error) {
+	claims, err := uc.signer.Verify(accessToken)
+	if errors.Is(err, auth.ErrExpiredToken) {
+		return nil, errExpiredAccessToken
+	}
+	if err != nil {
+		return nil, errInvalidAccessToken
+	}
+
+	id, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		return nil, errInvalidAccessToken
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	u, err := uc.userStore.Get(ctx, id)
+	if errors.Is(err, model.ErrNotFound) {
+		return nil, errInvalidAccessToken
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching access token user: %w", err)
+	}
+
+	return u, nil
+}
+
+// Purge deletes expired refresh tokens, returning how many were removed
+func (uc *authUsecase) Purge(ctx context.Context) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, bulkTimeout)
+	defer cancel()
+
+	n, err := uc.tokenStore.DeleteExpired(ctx, time.Now().UTC())
+	if err != nil {
+		return 0, fmt.Errorf("error purging refresh tokens: %w", err)
+	}
+	return n, nil
+}
+
+func (uc *authUsecase) lookup(ctx context.Context, token string) (*model.RefreshToken, error) {
+	if token == "" {
+		return nil, errInvalidRefreshToken
+	}
+
+	t, err := uc.tokenStore.GetByHash(ctx, hashToken(token))
+	if errors.Is(err, model.ErrNotFound) {
+		return nil, errInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching refresh token: %w", err)
+	}
+	return t, nil
+}
+
+func (uc *authUsecase) tokenPair(u *model.User, refresh string) (*model.TokenPair, error) {
+	access, err := uc.signer.Sign(auth.Claims{
+		Subject: strconv.Itoa(u.ID),
+		Role:    u.Role,
+		ID:      uuid.NewString(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error signing access token: %w", err)
+	}
+
+	return &model.TokenPair{
+		AccessToken:  access,
+		TokenType:    tokenType,
+		ExpiresIn:    int(uc.signer.TTL().Seconds()),
+		RefreshToken: refresh,
+	}, nil
+}
+
+// newRefreshToken returns a random token for the client and the record of
+// its hash, only the hash is stored
+func (uc *authUsecase) newRefreshToken(userID int, family string) (string, *model.RefreshToken, error) {
+	token, err := randomToken()
+	if err != nil {
+		return "", nil, fmt.Errorf("error generating refresh token: %w", err)
+	}
+
+	return token, &model.RefreshToken{
+		UserID:    userID,
+		TokenHash: hashToken(token),
+		Family:    family,
+		ExpiresAt: time.Now().UTC().Add(uc.refreshTTL),
+	}, nil
+}
+
+// randomToken returns 32 random bytes, url safe
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,253 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+)
+
+const (
+	// quotaCacheTTL is how long a user's stored monthly count is trusted
+	// before it is read again, other instances' requests show up after it
+	quotaCacheTTL = time.Minute
+	// maxUsageDays caps the range of usage queries
+	maxUsageDays = 366
+)
+
+var errInvalidUsageRange = model.NewValidationError([]error{&model.FieldError{Field: "to", Message: fmt.Sprintf("must be on or after from and at most %d days later", maxUsageDays)}})
+
+type (
+	usageKey struct {
+		userID   int
+		day      time.Time
+		endpoint string
+	}
+
+	// monthCount is a user's requests this month, stored and pending ones
+	monthCount struct {
+		month    time.Time
+		requests int64
+		loadedAt time.Time
+	}
+
+	usageUsecase struct {
+		store     model.UsageStore
+		userStore model.UserStore
+		quotas    model.Quotas
+
+		mu      sync.Mutex
+		pending map[usageKey]*model.UsageRecord
+		counts  map[int]*monthCount
+	}
+)
+
+// NewUsageUsecase meters requests in memory, Flush writes them to s
+func NewUsageUsecase(s model.UsageStore, us model.UserStore, quotas model.Quotas) *usageUsecase {
+	return &usageUsecase{
+		store:     s,
+		userStore: us,
+		quotas:    quotas,
+		pending:   make(map[usageKey]*model.UsageRecord),
+		counts:    make(map[int]*monthCount),
+	}
+}
+
+func (uc *usageUsecase) Record(userID int, endpoint string, bytes int64) {
+	now := time.Now().UTC()
+	key := usageKey{userID: userID, day: startOfDay(now), endpoint: endpoint}
+
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+
+	rec, ok := uc.pending[key]
+	if !ok {
+		rec = &model.UsageRecord{UserID: userID, Day: key.day, Endpoint: endpoint}
+		uc.pending[key] = rec
+	}
+	rec.Requests++
+	rec.Bytes += bytes
+
+	if c, ok := uc.counts[userID]; ok && c.month.Equal(startOfMonth(now)) {
+		c.requests++
+	}
+}
+
+// Flush writes the pending counts, on failure they are kept for the next
+// flush
+func (uc *usageUsecase) Flush(ctx context.Context) error {
+	uc.mu.Lock()
+	pending := uc.pending
+	uc.pending = make(map[usageKey]*model.UsageRecord)
+	uc.mu.Unlock()
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	records := make([]*model.UsageRecord, 0, len(pending))
+	for _, rec := range pending {
+		records = append(records, rec)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, bulkTimeout)
+	defer cancel()
+
+	if err := uc.store.Add(ctx, records); err != nil {
+		uc.mu.Lock()
+		for key, rec := range pending {
+			if p, ok := uc.pending[key]; ok {
+				rec.Requests += p.Requests
+				rec.Bytes += p.Bytes
+			}
+			uc.pending[key] = rec
+		}
+		uc.mu.Unlock()
+
+		return fmt.Errorf("error flushing usage: %w", err)
+	}
+
+	return nil
+}
+
+// Quota returns the monthly quota of u and how much of it is used
+func (uc *usageUsecase) Quota(ctx context.Context, u *model.User) (*model.Quota, error) {
+	now := time.Now().UTC()
+	month := startOfMonth(now)
+
+	used, err := uc.monthRequests(ctx, u.ID, month, now)
+	if err != nil {
+		return nil, err
+	}
+
+	q := &model.Quota{Used: used, ResetsAt: month.AddDate(0, 1, 0)}
+
+	if limit := uc.limit(u.Role); limit > 0 {
+		remaining := max(limit-used, 0)
+		q.Limit = &limit
+		q.Remaining = &remaining
+	}
+
+	return q, nil
+}
+
+// Get returns the stored usage of a user, requests of the last flush
+// interval are not included yet
+func (uc *usageUsecase) Get(ctx context.Context, userID int, from, to time.Time) (*model.Usage, error) {
+	requestUser, err := requestUserFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := authorizeUser(ctx, requestUser, userID, model.PermissionUsersRead); err != nil {
+		return nil, err
+	}
+
+	if err := validateUsageRange(from, to); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	u, err := uc.userStore.Get(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching user: %w", err)
+	}
+
+	records, err := uc.store.List(ctx, userID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("error listing usage: %w", err)
+	}
+
+	quota, err := uc.Quota(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+
+	usage := &model.Usage{UserID: userID, From: from, To: to, Days: records, Quota: quota}
+	for _, rec := range records {
+		usage.Requests += rec.Requests
+		usage.Bytes += rec.Bytes
+	}
+
+	return usage, nil
+}
+
+func (uc *usageUsecase) Report(ctx context.Context, from, to time.Time) ([]*model.UsageTotal, error) {
+	if err := authorize(ctx, model.PermissionUsersAdmin); err != nil {
+		return nil, err
+	}
+
+	if err := validateUsageRange(from, to); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, bulkTimeout)
+	defer cancel()
+
+	totals, err := uc.store.Report(ctx, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("error reporting usage: %w", err)
+	}
+
+	return totals, nil
+}
+
+// monthRequests reads the stored count at most once per quotaCacheTTL and
+// adds the requests still pending
+func (uc *usageUsecase) monthRequests(ctx context.Context, userID int, month, now time.Time) (int64, error) {
+	uc.mu.Lock()
+	c, ok := uc.counts[userID]
+	if ok && c.month.Equal(month) && now.Sub(c.loadedAt) < quotaCacheTTL {
+		n := c.requests
+		uc.mu.Unlock()
+		return n, nil
+	}
+	uc.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	stored, err := uc.store.Requests(ctx, userID, month)
+	if err != nil {
+		return 0, fmt.Errorf("error counting usage: %w", err)
+	}
+
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+
+	n := stored
+	for key, rec := range uc.pending {
+		if key.userID == userID && !key.day.Before(month) {
+			n += rec.Requests
+		}
+	}
+	uc.counts[userID] = &monthCount{month: month, requests: n, loadedAt: now}
+
+	return n, nil
+}
+
+func (uc *usageUsecase) limit(role string) int64 {
+	if limit, ok := uc.quotas.Roles[role]; ok {
+		return limit
+	}
+	return uc.quotas.Default
+}
+
+func validateUsageRange(from, to time.Time) error {
+	if to.Before(from) || to.Sub(from) > maxUsageDays*24*time.Hour {
+		return errInvalidUsageRange
+	}
+	return nil
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func startOfMonth(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
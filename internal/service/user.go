@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
 	"time"
 
 	"github.com/LaQuannT/astronaut-data-api/internal/model"
@@ -16,12 +17,21 @@ import (
 var timeout = 5 * time.Second
 
 type userUsercase struct {
-	store model.UserStore
+	store     model.UserStore
+	roleStore model.RoleStore
+	accounts  model.AccountUsecase
+	lockout   lockout
 }
 
-func NewUserUsecase(s model.UserStore) *userUsercase {
+// NewUserUsecase counts unknown API keys against the client IP in ls, a nil
+// ls leaves key guessing unlimited. Changed emails are verified through as,
+// a nil as sends no mail.
+func NewUserUsecase(s model.UserStore, rs model.RoleStore, ls model.LockoutStore, as model.AccountUsecase) *userUsercase {
 	return &userUsercase{
-		store: s,
+		store:     s,
+		roleStore: rs,
+		accounts:  as,
+		lockout:   lockout{store: ls},
 	}
 }
 
@@ -30,67 +40,68 @@ var userValidatorRules = validation.Rules{
 	"length":   validation.Length(50),
 	"email":    validation.Email,
 	"password": validation.Password(8),
-	"role":     validation.Role,
 }
 
-func (uc *userUsercase) Create(ctx context.Context, u *model.User) (*model.User, []error) {
-	errs := make([]error, 0)
-
-	v := validation.New(userValidatorRules)
-
-	checks := map[string]validation.Check{
-		"firstName": {Value: u.FirstName, RuleKey: []string{"require", "length"}},
-		"surename":  {Value: u.Surename, RuleKey: []string{"require", "length"}},
-		"email":     {Value: u.Email, RuleKey: []string{"require", "email", "length"}},
-		"password":  {Value: u.Password, RuleKey: []string{"require", "password"}},
-	}
-
-	if errs := v.Validate(checks); errs != nil {
-		return nil, errs
-	}
+// Create signs up a base user, the role in u is ignored as anyone can sign up
+func (uc *userUsercase) Create(ctx context.Context, u *model.User) (*model.User, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
 
-	if u.Role != model.AdminUser {
-		u.Role = model.BaseUser
+	k, err := prepareUser(ctx, uc.roleStore, u, model.BaseUser)
+	if err != nil {
+		return nil, err
 	}
+	// new accounts are unverified until the user confirms the mailed token
+	u.EmailVerifiedAt = nil
 
-	hash, err := bcrypt.GenerateFromPassword([]byte(u.Password), 12)
+	id, err := uc.store.Create(ctx, u, k)
 	if err != nil {
-		err = fmt.Errorf("error generating password hash: %w", err)
-		errs = append(errs, err)
-		return nil, errs
+		return nil, fmt.Errorf("error creating a new user: %w", err)
 	}
 
-	u.Password = string(hash)
-	u.ApiKey = uuid.NewString()
-	u.CreatedAt = time.Now().UTC()
+	u.ID = id
+	u.ApiKey = k.Key
+	return u, nil
+}
 
+func (uc *userUsercase) Bootstrap(ctx context.Context, u *model.User) (*model.User, error) {
 	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
-	id, err := uc.store.Create(ctx, u)
+	admins, err := uc.store.CountRole(ctx, model.AdminUser)
+	if err != nil {
+		return nil, fmt.Errorf("error counting admins: %w", err)
+	}
+	if admins > 0 {
+		return nil, errAdminExists
+	}
+
+	k, err := prepareUser(ctx, uc.roleStore, u, model.AdminUser)
+	if err != nil {
+		return nil, err
+	}
+	// whoever runs the bootstrap vouches for the email
+	now := time.Now().UTC()
+	u.EmailVerifiedAt = &now
+
+	id, err := uc.store.Create(ctx, u, k)
 	if err != nil {
-		err = fmt.Errorf("error creating a new user: %w", err)
-		errs = append(errs, err)
-		return nil, errs
+		return nil, fmt.Errorf("error creating admin user: %w", err)
 	}
 
 	u.ID = id
+	u.ApiKey = k.Key
 	return u, nil
 }
 
 func (uc *userUsercase) List(ctx context.Context, limit, offset int) ([]*model.User, error) {
-	requestUser, ok := ctx.Value(middleware.RequestUser).(*model.User)
-	if !ok {
-		return nil, errors.New("invalid request-User")
+	if err := authorize(ctx, model.PermissionUsersAdmin); err != nil {
+		return nil, err
 	}
 
 	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
-	if requestUser.Role != model.AdminUser {
-		return nil, errors.New("user is not authorised")
-	}
-
 	users, err := uc.store.List(ctx, limit, offset)
 	if err != nil {
 		return nil, fmt.Errorf("error listing users: %w", err)
@@ -99,21 +110,37 @@ func (uc *userUsercase) List(ctx context.Context, limit, offset int) ([]*model.U
 	return users, nil
 }
 
+func (uc *userUsercase) Count(ctx context.Context) (int, error) {
+	if err := authorize(ctx, model.PermissionUsersAdmin); err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	count, err := uc.store.Count(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error counting users: %w", err)
+	}
+
+	return count, nil
+}
+
 func (uc *userUsercase) Get(ctx context.Context, id int) (*model.User, error) {
-	requestUser, ok := ctx.Value(middleware.RequestUser).(*model.User)
-	if !ok {
-		return nil, errors.New("invalid request-User")
+	requestUser, err := requestUserFrom(ctx)
+	if err != nil {
+		return nil, err
 	}
 
 	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
-	if requestUser.ID == id {
-		return requestUser, nil
+	if err := authorizeUser(ctx, requestUser, id, model.PermissionUsersRead); err != nil {
+		return nil, err
 	}
 
-	if requestUser.Role != model.AdminUser {
-		return nil, errors.New("user is not authorised")
+	if requestUser.ID == id {
+		return requestUser, nil
 	}
 
 	u, err := uc.store.Get(ctx, id)
@@ -124,37 +151,29 @@ func (uc *userUsercase) Get(ctx context.Context, id int) (*model.User, error) {
 	return u, nil
 }
 
-func (uc *userUsercase) Update(ctx context.Context, u *model.User) (*model.User, []error) {
-	errs := make([]error, 0)
-
-	requestUser, ok := ctx.Value(middleware.RequestUser).(*model.User)
-	if !ok {
-		err := errors.New("invalid request-User")
-		errs = append(errs, err)
-		return nil, errs
+func (uc *userUsercase) Update(ctx context.Context, u *model.User) (*model.User, error) {
+	requestUser, err := requestUserFrom(ctx)
+	if err != nil {
+		return nil, err
 	}
 
 	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
+	if err := authorizeUser(ctx, requestUser, u.ID, model.PermissionUsersWrite); err != nil {
+		return nil, err
+	}
+
 	var originalUser *model.User
 
 	if requestUser.ID == u.ID {
 		originalUser = requestUser
-	} else if requestUser.ID != u.ID && requestUser.Role == model.AdminUser {
+	} else {
 		ou, err := uc.store.Get(ctx, u.ID)
 		if err != nil {
-			err = fmt.Errorf("error fetching original user data: %w", err)
-			errs = append(errs, err)
-			return nil, errs
-
+			return nil, fmt.Errorf("error fetching original user data: %w", err)
 		}
 		originalUser = ou
-
-	} else {
-		err := errors.New("user is not authorised")
-		errs = append(errs, err)
-		return nil, errs
 	}
 
 	v := validation.New(userValidatorRules)
@@ -163,64 +182,87 @@ func (uc *userUsercase) Update(ctx context.Context, u *model.User) (*model.User,
 		"firstName": {Value: u.FirstName, RuleKey: []string{"require", "length"}},
 		"surename":  {Value: u.Surename, RuleKey: []string{"require", "length"}},
 		"email":     {Value: u.Email, RuleKey: []string{"require", "email", "length"}},
-		"role":      {Value: u.Role, RuleKey: []string{"role"}},
 	}
 
-	if errs := v.Validate(checks); errs != nil {
-		return nil, errs
+	errs := v.Validate(checks)
+
+	// a missing role keeps the current one, changing it takes users:admin
+	if u.Role != "" && u.Role != originalUser.Role {
+		if err := authorize(ctx, model.PermissionUsersAdmin); err != nil {
+			return nil, err
+		}
+
+		if _, err := uc.roleStore.Get(ctx, u.Role); errors.Is(err, model.ErrNotFound) {
+			errs = append(errs, &model.FieldError{Field: "role", Message: fmt.Sprintf("%s is not a role", u.Role)})
+		} else if err != nil {
+			return nil, fmt.Errorf("error fetching user role: %w", err)
+		}
 	}
 
+	if errs != nil {
+		return nil, model.NewValidationError(errs)
+	}
+
+	emailChanged := u.Email != originalUser.Email
+
+	version := u.Version
 	u = compareUserData(originalUser, u)
+	u.Version = version
 
 	u.UpdatedAt = time.Now().UTC()
+	if emailChanged {
+		u.EmailVerifiedAt = nil
+	}
 
 	if err := uc.store.Update(ctx, u); err != nil {
-		err = fmt.Errorf("error updating user data: %w", err)
-		errs = append(errs, err)
-		return nil, errs
+		return nil, fmt.Errorf("error updating user data: %w", err)
+	}
+
+	if emailChanged && uc.accounts != nil {
+		if err := uc.accounts.SendVerification(ctx, u); err != nil {
+			// the change is stored by then, only the mail has to be resent
+			return nil, model.WrapError(model.KindUnavailable, "verification_not_sent", "email was changed but the verification mail could not be sent, request it again from /auth/email/resend", err)
+		}
 	}
 
 	return u, nil
 }
 
-func (uc *userUsercase) Delete(ctx context.Context, id int) error {
-	requestUser, ok := ctx.Value(middleware.RequestUser).(*model.User)
-	if !ok {
-		return errors.New("invalid request-User")
+func (uc *userUsercase) Delete(ctx context.Context, id, version int) error {
+	requestUser, err := requestUserFrom(ctx)
+	if err != nil {
+		return err
 	}
 
 	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
-	if requestUser.Role != model.AdminUser && requestUser.ID != id {
-		return errors.New("user is not authorised")
+	if err := authorizeUser(ctx, requestUser, id, model.PermissionUsersWrite); err != nil {
+		return err
 	}
 
-	if err := uc.store.Delete(ctx, id); err != nil {
+	if err := uc.store.Delete(ctx, id, version); err != nil {
 		return fmt.Errorf("error deleting user: %w", err)
 	}
 
 	return nil
 }
 
-func (uc *userUsercase) ResetPassword(ctx context.Context, u *model.User) []error {
-	errs := make([]error, 0)
-
-	requestUser, ok := ctx.Value(middleware.RequestUser).(*model.User)
-	if !ok {
-		err := errors.New("invalid request-User")
-		errs = append(errs, err)
-		return errs
+func (uc *userUsercase) ResetPassword(ctx context.Context, u *model.User) error {
+	requestUser, err := requestUserFrom(ctx)
+	if err != nil {
+		return err
 	}
 
 	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
 	if requestUser.ID != u.ID {
-		err := errors.New("user is not authorised")
-		errs = append(errs, err)
-		return errs
+		return errNotAuthorised
+	}
 
+	if err := authorize(ctx, model.PermissionUsersWrite); err != nil {
+		return err
 	}
 
 	v := validation.New(userValidatorRules)
@@ -230,60 +272,261 @@ func (uc *userUsercase) ResetPassword(ctx context.Context, u *model.User) []erro
 	}
 
 	if errs := v.Validate(checks); errs != nil {
-		return errs
+		return model.NewValidationError(errs)
 	}
 
 	hash, err := bcrypt.GenerateFromPassword([]byte(u.Password), 12)
 	if err != nil {
-		err := fmt.Errorf("error generating password hash: %w", err)
-		errs = append(errs, err)
-		return errs
-
+		return fmt.Errorf("error generating password hash: %w", err)
 	}
 
 	u.Password = string(hash)
 	u.UpdatedAt = time.Now().UTC()
 
 	if err := uc.store.UpdatePassword(ctx, u); err != nil {
-		err = fmt.Errorf("error resetting user password: %w", err)
-		errs = append(errs, err)
-		return errs
-
+		return fmt.Errorf("error resetting user password: %w", err)
 	}
 
 	return nil
 }
 
 func (uc *userUsercase) GenerateNewAPIKey(ctx context.Context, id int) (*model.User, error) {
-	requestUser, ok := ctx.Value(middleware.RequestUser).(*model.User)
-	if !ok {
-		return nil, errors.New("invalid request-User")
+	requestUser, err := requestUserFrom(ctx)
+	if err != nil {
+		return nil, err
 	}
 
 	if requestUser.ID != id {
-		return nil, errors.New("user not authorised")
+		return nil, errNotAuthorised
+	}
+
+	if err := authorize(ctx, model.PermissionUsersWrite); err != nil {
+		return nil, err
 	}
 
 	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
-	requestUser.ApiKey = uuid.New().String()
-	requestUser.UpdatedAt = time.Now().UTC()
+	// a bearer token has no key to replace, the user gets a default key
+	old, ok := ctx.Value(middleware.RequestAPIKey).(*model.APIKey)
+	if !ok {
+		k := newAPIKey(defaultAPIKeyName, requestUser.Permissions, nil)
+		k.UserID = id
+
+		if err := uc.store.CreateAPIKey(ctx, k); err != nil {
+			return nil, fmt.Errorf("error generating new APIKey: %w", err)
+		}
+
+		requestUser.ApiKey = k.Key
+		return requestUser, nil
+	}
 
-	if err := uc.store.UpdateAPIKey(ctx, requestUser); err != nil {
+	k := newAPIKey(old.Name, old.Scopes, old.ExpiresAt)
+	k.UserID = id
+
+	if err := uc.store.RotateAPIKey(ctx, old.ID, k); err != nil {
 		return nil, fmt.Errorf("error generating new APIKey: %w", err)
 	}
 
+	requestUser.ApiKey = k.Key
 	return requestUser, nil
 }
 
-func (uc *userUsercase) SearchAPIKey(ctx context.Context, key string) (*model.User, error) {
-	u, err := uc.store.SearchApiKey(ctx, key)
+func (uc *userUsercase) CreateAPIKey(ctx context.Context, userID int, k *model.APIKey) (*model.APIKey, error) {
+	requestUser, err := requestUserFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if requestUser.ID != userID {
+		return nil, errNotAuthorised
+	}
+
+	if err := authorize(ctx, model.PermissionUsersWrite); err != nil {
+		return nil, err
+	}
+
+	if errs := validateAPIKey(ctx, requestUser, k); errs != nil {
+		return nil, model.NewValidationError(errs)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	key := newAPIKey(k.Name, k.Scopes, k.ExpiresAt)
+	key.UserID = userID
+
+	if err := uc.store.CreateAPIKey(ctx, key); err != nil {
+		return nil, fmt.Errorf("error creating APIKey: %w", err)
+	}
+
+	return key, nil
+}
+
+func (uc *userUsercase) ListAPIKeys(ctx context.Context, userID int) ([]*model.APIKey, error) {
+	requestUser, err := requestUserFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := authorizeUser(ctx, requestUser, userID, model.PermissionUsersRead); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	keys, err := uc.store.ListAPIKeys(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error listing APIKeys: %w", err)
+	}
+
+	return keys, nil
+}
+
+func (uc *userUsercase) RevokeAPIKey(ctx context.Context, userID, id int) error {
+	requestUser, err := requestUserFrom(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := authorizeUser(ctx, requestUser, userID, model.PermissionUsersWrite); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if err := uc.store.RevokeAPIKey(ctx, userID, id); err != nil {
+		return fmt.Errorf("error revoking APIKey: %w", err)
+	}
+
+	return nil
+}
+
+func (uc *userUsercase) SearchAPIKey(ctx context.Context, key string) (*model.APIKey, error) {
+	keys := requestKeys(ctx)
+	if err := uc.lockout.check(ctx, keys...); err != nil {
+		return nil, err
+	}
+
+	k, err := uc.store.SearchAPIKeyHash(ctx, hashToken(key))
+	if errors.Is(err, model.ErrNotFound) {
+		if err := uc.lockout.fail(ctx, nil, keys...); err != nil {
+			return nil, err
+		}
+	}
 	if err != nil {
 		return nil, fmt.Errorf("error searching for user by APIKey: %w", err)
 	}
 
-	return u, nil
+	if !k.Active(time.Now().UTC()) {
+		return nil, errInactiveAPIKey
+	}
+
+	return k, nil
+}
+
+// authorizeUser lets users act on their own account with permission, acting
+// on another account takes users:admin
+func authorizeUser(ctx context.Context, requestUser *model.User, id int, permission string) error {
+	if requestUser.ID == id {
+		return authorize(ctx, permission)
+	}
+	return authorize(ctx, model.PermissionUsersAdmin)
+}
+
+const (
+	// apiKeyPrefixLength is how much of a key is kept to tell keys apart
+	apiKeyPrefixLength = 8
+	defaultAPIKeyName  = "default"
+)
+
+var (
+	errInactiveAPIKey = model.NewError(model.KindUnauthenticated, "api_key_inactive", "API key is expired or revoked")
+	errAdminExists    = model.NewError(model.KindConflict, "admin_exists", "an admin already exists, further admins are invited")
+	errEmailTaken     = model.NewError(model.KindConflict, "email_taken", "email address is already registered")
+)
+
+// prepareUser validates a new user, hashes their password and gives them
+// role, returning their first key with every permission of the role
+func prepareUser(ctx context.Context, rs model.RoleStore, u *model.User, role string) (*model.APIKey, error) {
+	v := validation.New(userValidatorRules)
+
+	checks := map[string]validation.Check{
+		"firstName": {Value: u.FirstName, RuleKey: []string{"require", "length"}},
+		"surename":  {Value: u.Surename, RuleKey: []string{"require", "length"}},
+		"email":     {Value: u.Email, RuleKey: []string{"require", "email", "length"}},
+		"password":  {Value: u.Password, RuleKey: []string{"require", "password"}},
+	}
+
+	if errs := v.Validate(checks); errs != nil {
+		return nil, model.NewValidationError(errs)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(u.Password), 12)
+	if err != nil {
+		return nil, fmt.Errorf("error generating password hash: %w", err)
+	}
+
+	u.Password = string(hash)
+	u.Role = role
+	u.CreatedAt = time.Now().UTC()
+
+	r, err := rs.Get(ctx, role)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching user role: %w", err)
+	}
+	u.Permissions = r.Permissions
+
+	return newAPIKey(defaultAPIKeyName, r.Permissions, nil), nil
+}
+
+// newAPIKey issues a key, only its digest and prefix are stored
+func newAPIKey(name string, scopes []string, expiresAt *time.Time) *model.APIKey {
+	key := uuid.NewString()
+	return &model.APIKey{
+		Name:      name,
+		Key:       key,
+		Hash:      hashToken(key),
+		Prefix:    key[:apiKeyPrefixLength],
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+	}
+}
+
+// validateAPIKey limits a new key to permissions the user's role and the
+// credential creating it both have, so keys never widen access
+func validateAPIKey(ctx context.Context, u *model.User, k *model.APIKey) []error {
+	v := validation.New(userValidatorRules)
+
+	errs := v.Validate(map[string]validation.Check{
+		"name": {Value: k.Name, RuleKey: []string{"require", "length"}},
+	})
+
+	if len(k.Scopes) == 0 {
+		errs = append(errs, &model.FieldError{Field: "scopes", Message: "scopes must not be empty"})
+	}
+
+	allowed := u.Permissions
+	granted := requestScopesFrom(ctx)
+	for i, scope := range k.Scopes {
+		field := fmt.Sprintf("scopes[%d]", i)
+		switch {
+		case !slices.Contains(model.Permissions, scope):
+			errs = append(errs, &model.FieldError{Field: field, Message: fmt.Sprintf("%s is not a permission", scope)})
+		case !slices.Contains(allowed, scope):
+			errs = append(errs, &model.FieldError{Field: field, Message: fmt.Sprintf("%s is not available to the %s role", scope, u.Role)})
+		case !slices.Contains(granted, scope):
+			errs = append(errs, &model.FieldError{Field: field, Message: fmt.Sprintf("%s is not granted to the credential creating the key", scope)})
+		}
+	}
+
+	if k.ExpiresAt != nil && !k.ExpiresAt.After(time.Now()) {
+		errs = append(errs, &model.FieldError{Field: "expiresAt", Message: "expiresAt must be in the future"})
+	}
+
+	return errs
 }
 
 func compareUserData(old, new *model.User) *model.User {
@@ -299,7 +542,7 @@ func compareUserData(old, new *model.User) *model.User {
 		old.Email = new.Email
 	}
 
-	if new.Role != old.Role {
+	if new.Role != "" && new.Role != old.Role {
 		old.Role = new.Role
 	}
 
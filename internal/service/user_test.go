@@ -0,0 +1,117 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+)
+
+func newTestUser(email string) *model.User {
+	return &model.User{FirstName: "jane", Surename: "doe", Email: email, Password: "Passw0rd!"}
+}
+
+func TestUserCreate(t *testing.T) {
+	s := newFakeUserStore()
+	uc := NewUserUsecase(s, newFakeRoleStore(), nil, nil)
+
+	// the signup body asks for the admin role
+	in := newTestUser("jane@example.com")
+	in.Role = model.AdminUser
+
+	u, err := uc.Create(context.Background(), in)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	stored, _ := s.Get(context.Background(), u.ID)
+	if stored.Role != model.BaseUser {
+		t.Fatalf("expected a %s, got a %s", model.BaseUser, stored.Role)
+	}
+	if stored.EmailVerifiedAt != nil {
+		t.Fatal("expected the email to be unverified")
+	}
+
+	k, err := s.SearchAPIKeyHash(context.Background(), hashToken(u.ApiKey))
+	if err != nil {
+		t.Fatalf("expected the issued key to be stored: %v", err)
+	}
+	if len(k.Scopes) != 3 {
+		t.Fatalf("expected the key to have the %s permissions, got %v", model.BaseUser, k.Scopes)
+	}
+}
+
+func TestUserBootstrap(t *testing.T) {
+	s := newFakeUserStore()
+	uc := NewUserUsecase(s, newFakeRoleStore(), nil, nil)
+
+	u, err := uc.Bootstrap(context.Background(), newTestUser("admin@example.com"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if u.Role != model.AdminUser || u.EmailVerifiedAt == nil {
+		t.Fatalf("expected a verified %s, got %+v", model.AdminUser, u)
+	}
+
+	if _, err := uc.Bootstrap(context.Background(), newTestUser("other@example.com")); !errors.Is(err, errAdminExists) {
+		t.Fatalf("expected %v once an admin exists, got %v", errAdminExists, err)
+	}
+
+	if n, _ := s.CountRole(context.Background(), model.AdminUser); n != 1 {
+		t.Fatalf("expected 1 admin, got %d", n)
+	}
+}
+
+func TestUserUpdateEmail(t *testing.T) {
+	s := newFakeUserStore()
+	ts := new(fakeUserTokenStore)
+	m := new(fakeMailer)
+	uc := NewUserUsecase(s, newFakeRoleStore(), nil, NewAccountUsecase(s, ts, m, "http://localhost/api/v1/auth", time.Hour, time.Hour))
+
+	verified := time.Now().UTC()
+	in := newTestUser("jane@example.com")
+	in.Role = model.BaseUser
+	in.EmailVerifiedAt = &verified
+	id, _ := s.Create(context.Background(), in, newAPIKey(defaultAPIKeyName, nil, nil))
+
+	update := func(t *testing.T, firstName, email string) *model.User {
+		t.Helper()
+		requestUser, _ := s.Get(context.Background(), id)
+		requestUser.Permissions = []string{model.PermissionUsersWrite}
+
+		u, err := uc.Update(requestContext(requestUser, nil), &model.User{ID: id, FirstName: firstName, Surename: "doe", Email: email})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return u
+	}
+
+	t.Run("keeps verification of the same email", func(t *testing.T) {
+		u := update(t, "janet", "jane@example.com")
+
+		if u.EmailVerifiedAt == nil {
+			t.Fatal("expected the email to stay verified")
+		}
+		if len(m.sent) != 0 {
+			t.Fatalf("expected no mail, got %d", len(m.sent))
+		}
+	})
+
+	t.Run("clears verification of a changed email", func(t *testing.T) {
+		u := update(t, "janet", "janet@example.com")
+
+		stored, _ := s.Get(context.Background(), id)
+		if u.EmailVerifiedAt != nil || stored.EmailVerifiedAt != nil {
+			t.Fatal("expected the changed email to be unverified")
+		}
+
+		if len(m.sent) != 1 || m.sent[0].To != "janet@example.com" {
+			t.Fatalf("expected a verification mail to the new email, got %+v", m.sent)
+		}
+		if len(ts.tokens) != 1 || ts.tokens[0].Purpose != model.TokenEmailVerification || ts.tokens[0].UserID != id || ts.tokens[0].Email != "janet@example.com" {
+			t.Fatalf("expected a verification token of user %d for the new email, got %+v", id, ts.tokens)
+		}
+	})
+}
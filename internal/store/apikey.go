@@ -0,0 +1,92 @@
+package store
+
+import (
+	"context"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+	"github.com/jackc/pgx/v5"
+)
+
+const apiKeyColumns = `id, user_id, name, prefix, scopes, expires_at, revoked_at, created_at`
+
+func (s *UserStore) CreateAPIKey(ctx context.Context, k *model.APIKey) error {
+	return createAPIKey(ctx, s.db, k)
+}
+
+// ListAPIKeys returns every key of a user, revoked ones included
+func (s *UserStore) ListAPIKeys(ctx context.Context, userID int) ([]*model.APIKey, error) {
+	keys := make([]*model.APIKey, 0)
+
+	query := `SELECT ` + apiKeyColumns + ` FROM api_key WHERE user_id=$1 ORDER BY id ASC;`
+	rows, err := s.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, storeError(err, "api_key")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		k := new(model.APIKey)
+		if err := rows.Scan(&k.ID, &k.UserID, &k.Name, &k.Prefix, &k.Scopes, &k.ExpiresAt, &k.RevokedAt, &k.CreatedAt); err != nil {
+			return nil, storeError(err, "api_key")
+		}
+		keys = append(keys, k)
+	}
+
+	return keys, storeError(rows.Err(), "api_key")
+}
+
+func (s *UserStore) SearchAPIKeyHash(ctx context.Context, hash string) (*model.APIKey, error) {
+	k := &model.APIKey{Hash: hash, User: new(model.User)}
+	u := k.User
+
+	query := `SELECT k.id, k.user_id, k.name, k.prefix, k.scopes, k.expires_at, k.revoked_at, k.created_at,
+  u.id, u.first_name, u.surname, u.email, u.role, u.email_verified_at, u.created_at, u.updated_at, u.version,
+  (SELECT permissions FROM role WHERE role.name = u.role)
+  FROM api_key k JOIN "user" u ON u.id = k.user_id WHERE k.key_hash=$1;`
+
+	err := s.db.QueryRow(ctx, query, hash).Scan(&k.ID, &k.UserID, &k.Name, &k.Prefix, &k.Scopes, &k.ExpiresAt, &k.RevokedAt, &k.CreatedAt,
+		&u.ID, &u.FirstName, &u.Surename, &u.Email, &u.Role, &u.EmailVerifiedAt, &u.CreatedAt, &u.UpdatedAt, &u.Version, &u.Permissions)
+	if err != nil {
+		return nil, storeError(err, "api_key")
+	}
+	return k, nil
+}
+
+// RevokeAPIKey is a no-op for a key that is already revoked
+func (s *UserStore) RevokeAPIKey(ctx context.Context, userID, id int) error {
+	query := `UPDATE api_key SET revoked_at=COALESCE(revoked_at, now() AT TIME ZONE 'utc') WHERE id=$1 AND user_id=$2;`
+
+	tag, err := s.db.Exec(ctx, query, id, userID)
+	if err != nil {
+		return storeError(err, "api_key")
+	}
+
+	if tag.RowsAffected() == 0 {
+		return storeError(pgx.ErrNoRows, "api_key")
+	}
+	return nil
+}
+
+func (s *UserStore) RotateAPIKey(ctx context.Context, oldID int, k *model.APIKey) error {
+	return pgx.BeginFunc(ctx, s.db, func(tx pgx.Tx) error {
+		query := `UPDATE api_key SET revoked_at=(now() AT TIME ZONE 'utc') WHERE id=$1 AND user_id=$2 AND revoked_at IS NULL;`
+
+		tag, err := tx.Exec(ctx, query, oldID, k.UserID)
+		if err != nil {
+			return storeError(err, "api_key")
+		}
+		if tag.RowsAffected() == 0 {
+			return storeError(pgx.ErrNoRows, "api_key")
+		}
+
+		return createAPIKey(ctx, tx, k)
+	})
+}
+
+func createAPIKey(ctx context.Context, db querier, k *model.APIKey) error {
+	query := `INSERT INTO api_key (user_id, name, key_hash, prefix, scopes, expires_at) VALUES ($1, $2, $3, $4, $5, $6)
+  RETURNING id, created_at;`
+
+	err := db.QueryRow(ctx, query, k.UserID, k.Name, k.Hash, k.Prefix, k.Scopes, k.ExpiresAt).Scan(&k.ID, &k.CreatedAt)
+	return storeError(err, "api_key")
+}
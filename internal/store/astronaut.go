@@ -2,16 +2,32 @@ package store
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/LaQuannT/astronaut-data-api/internal/model"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/lib/pq"
 )
 
+const astronautColumns = `id, name, year, "group", status, birth_date, birth_place, gender, alma_mater, undergraduate_major,
+  graduate_major, military_rank, military_branch, space_flights, space_flight_hrs, space_walks, space_walk_hrs, missions,
+  death_date, death_mission, version, updated_at`
+
+// querier runs statements on the pool or inside a transaction, Begin on a
+// transaction starts a savepoint
+type querier interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
 type astronautStore struct {
-	db *pgxpool.Pool
+	db querier
 }
 
 func NewAstronautStore(db *pgxpool.Pool) *astronautStore {
@@ -26,13 +42,13 @@ func (s *astronautStore) Create(ctx context.Context, a *model.Astronaut) (*model
   graduate_major, military_rank, military_branch, space_flights, space_flight_hrs, space_walks,
   space_walk_hrs, missions, death_date, death_mission)
   VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19)
-  RETURNING id;`
+  RETURNING id, version, updated_at;`
 
 	err := s.db.QueryRow(ctx, query, a.Name, a.Year, a.Group, a.Status, a.BirthDate, a.BirthPlace,
 		a.Gender, pq.Array(a.AlmaMater), pq.Array(a.UndergraduateMajor), pq.Array(a.GraduateMajor), a.MilitaryRank, a.MilitaryBranch, a.SpaceFlights,
-		a.SpaceFlightHours, a.SpaceWalks, a.SpaceWalkHours, pq.Array(a.Missions), a.DeathDate, a.DeathMission).Scan(&a.ID)
+		a.SpaceFlightHours, a.SpaceWalks, a.SpaceWalkHours, pq.Array(a.Missions), a.DeathDate, a.DeathMission).Scan(&a.ID, &a.Version, &a.UpdatedAt)
 	if err != nil {
-		return nil, err
+		return nil, storeError(err, "astronaut")
 	}
 
 	return a, nil
@@ -41,17 +57,17 @@ func (s *astronautStore) Create(ctx context.Context, a *model.Astronaut) (*model
 func (s *astronautStore) List(ctx context.Context, limit, offset int) ([]*model.Astronaut, error) {
 	var astronauts []*model.Astronaut
 
-	query := `SELECT * FROM astronaut ORDER BY name ASC LIMIT $1 OFFSET $2;`
+	query := `SELECT ` + astronautColumns + ` FROM astronaut ORDER BY name ASC LIMIT $1 OFFSET $2;`
 	rows, err := s.db.Query(ctx, query, limit, offset)
 	if err != nil {
-		return nil, err
+		return nil, storeError(err, "astronaut")
 	}
 	defer rows.Close()
 
 	for rows.Next() {
 		a, err := fromRowToAstronaut(rows)
 		if err != nil {
-			return nil, err
+			return nil, storeError(err, "astronaut")
 		}
 		astronauts = append(astronauts, a)
 	}
@@ -59,57 +75,95 @@ func (s *astronautStore) List(ctx context.Context, limit, offset int) ([]*model.
 }
 
 func (s *astronautStore) Get(ctx context.Context, id int) (*model.Astronaut, error) {
-	query := `SELECT * FROM astronaut WHERE id=$1;`
+	query := `SELECT ` + astronautColumns + ` FROM astronaut WHERE id=$1;`
 	rows, err := s.db.Query(ctx, query, id)
 	if err != nil {
-		return nil, err
+		return nil, storeError(err, "astronaut")
 	}
 	defer rows.Close()
 
 	for rows.Next() {
 		return fromRowToAstronaut(rows)
 	}
-	return nil, nil
+
+	if err := rows.Err(); err != nil {
+		return nil, storeError(err, "astronaut")
+	}
+	return nil, storeError(pgx.ErrNoRows, "astronaut")
 }
 
-func (s *astronautStore) Update(ctx context.Context, a *model.Astronaut) error {
-	query := `UPDATE astronaut SET name=$1, year=$2, group=$3, status=$4, birth_date=$5, birth_place=$6, gender=$7, alma_mater=$8, undergraduate_major=$9,
+// updateAstronautQuery only matches the expected version, a version of 0
+// skips the check
+const updateAstronautQuery = `UPDATE astronaut SET name=$1, year=$2, "group"=$3, status=$4, birth_date=$5, birth_place=$6, gender=$7, alma_mater=$8, undergraduate_major=$9,
   graduate_major=$10, military_rank=$11, military_branch=$12, space_flights=$13, space_flight_hrs=$14, space_walks=$15, space_walk_hrs=$16, missions=$17,
-  death_date=$18, death_mission=$19 WHERE id=$20;`
-	_, err := s.db.Exec(ctx, query, a.Name, a.Year, a.Group, a.Status, a.BirthDate, a.BirthPlace,
+  death_date=$18, death_mission=$19, version=version+1, updated_at=(now() AT TIME ZONE 'utc')
+  WHERE id=$20 AND ($21=0 OR version=$21) RETURNING version, updated_at;`
+
+func updateAstronautArgs(a *model.Astronaut) []any {
+	return []any{a.Name, a.Year, a.Group, a.Status, a.BirthDate, a.BirthPlace,
 		a.Gender, pq.Array(a.AlmaMater), pq.Array(a.UndergraduateMajor), pq.Array(a.GraduateMajor), a.MilitaryRank, a.MilitaryBranch, a.SpaceFlights,
-		a.SpaceFlightHours, a.SpaceWalks, a.SpaceWalkHours, pq.Array(a.Missions), a.DeathDate, a.DeathMission, a.ID)
-	if err != nil {
-		return err
+		a.SpaceFlightHours, a.SpaceWalks, a.SpaceWalkHours, pq.Array(a.Missions), a.DeathDate, a.DeathMission, a.ID, a.Version}
+}
+
+// scanUpdate reads the columns returned by a versioned update, no row means
+// the expected version was stale
+func scanUpdate(row pgx.Row, dest ...any) error {
+	err := row.Scan(dest...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return model.ErrVersionConflict
 	}
-	return nil
+	return err
+}
+
+func (s *astronautStore) Update(ctx context.Context, a *model.Astronaut) error {
+	err := scanUpdate(s.db.QueryRow(ctx, updateAstronautQuery, updateAstronautArgs(a)...), &a.Version, &a.UpdatedAt)
+	return storeError(err, "astronaut")
 }
 
-func (s *astronautStore) Delete(ctx context.Context, id int) error {
-	query := `DELETE FROM astronaut WHERE id=$1;`
-	_, err := s.db.Exec(ctx, query, id)
+func (s *astronautStore) Delete(ctx context.Context, id, version int) error {
+	query := `DELETE FROM astronaut WHERE id=$1 AND ($2=0 OR version=$2);`
+	tag, err := s.db.Exec(ctx, query, id, version)
 	if err != nil {
-		return err
+		return storeError(err, "astronaut")
+	}
+
+	if tag.RowsAffected() == 0 {
+		return s.missingOrStale(ctx, id)
 	}
 	return nil
 }
 
+// missingOrStale explains why a versioned write matched no row
+func (s *astronautStore) missingOrStale(ctx context.Context, id int) error {
+	var exists bool
+
+	query := `SELECT EXISTS (SELECT 1 FROM astronaut WHERE id=$1);`
+	if err := s.db.QueryRow(ctx, query, id).Scan(&exists); err != nil {
+		return storeError(err, "astronaut")
+	}
+
+	if !exists {
+		return storeError(pgx.ErrNoRows, "astronaut")
+	}
+	return model.ErrVersionConflict
+}
+
 func (s *astronautStore) SearchByName(ctx context.Context, name string, limit, offset int) ([]*model.Astronaut, error) {
 	var astronauts []*model.Astronaut
 
-	query := `SELECT * FROM astronaut WHERE name ILIKE $1 ORDER BY name ASC LIMIT $2 OFFSET $3;`
+	query := `SELECT ` + astronautColumns + ` FROM astronaut WHERE name ILIKE $1 ORDER BY name ASC LIMIT $2 OFFSET $3;`
 	name = fmt.Sprintf("%%%s%%", name)
 
 	rows, err := s.db.Query(ctx, query, name, limit, offset)
 	if err != nil {
-		return nil, err
+		return nil, storeError(err, "astronaut")
 	}
 	defer rows.Close()
 
 	for rows.Next() {
 		a, err := fromRowToAstronaut(rows)
 		if err != nil {
-			return nil, err
+			return nil, storeError(err, "astronaut")
 		}
 
 		astronauts = append(astronauts, a)
@@ -118,13 +172,171 @@ func (s *astronautStore) SearchByName(ctx context.Context, name string, limit, o
 	return astronauts, nil
 }
 
-func fromRowToAstronaut(r pgx.Rows) (*model.Astronaut, error) {
+func (s *astronautStore) All(ctx context.Context) ([]*model.Astronaut, error) {
+	var astronauts []*model.Astronaut
+
+	query := `SELECT ` + astronautColumns + ` FROM astronaut ORDER BY id ASC;`
+	rows, err := s.db.Query(ctx, query)
+	if err != nil {
+		return nil, storeError(err, "astronaut")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		a, err := fromRowToAstronaut(rows)
+		if err != nil {
+			return nil, storeError(err, "astronaut")
+		}
+		astronauts = append(astronauts, a)
+	}
+
+	return astronauts, storeError(rows.Err(), "astronaut")
+}
+
+// Merge saves the merged winner and removes the loser in one transaction, the
+// loser's history moves to the winner and its ID redirects there
+func (s *astronautStore) Merge(ctx context.Context, winner *model.Astronaut, loserID int) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return storeError(err, "astronaut")
+	}
+	defer tx.Rollback(ctx)
+
+	if err := scanUpdate(tx.QueryRow(ctx, updateAstronautQuery, updateAstronautArgs(winner)...), &winner.Version, &winner.UpdatedAt); err != nil {
+		return storeError(err, "astronaut")
+	}
+
+	statements := []struct {
+		query string
+		args  []any
+	}{
+		{`UPDATE astronaut_redirect SET new_id=$1 WHERE new_id=$2;`, []any{winner.ID, loserID}},
+		{`DELETE FROM astronaut WHERE id=$1;`, []any{loserID}},
+		{`UPDATE astronaut_history SET astronaut_id=$1 WHERE astronaut_id=$2;`, []any{winner.ID, loserID}},
+		{`INSERT INTO astronaut_redirect (old_id, new_id) VALUES ($1, $2);`, []any{loserID, winner.ID}},
+		{`INSERT INTO astronaut_history (astronaut_id, event, snapshot) VALUES ($1, 'merge', jsonb_build_object('mergedId', $2::int));`, []any{winner.ID, loserID}},
+	}
+
+	for _, st := range statements {
+		if _, err := tx.Exec(ctx, st.query, st.args...); err != nil {
+			return storeError(err, "astronaut")
+		}
+	}
+
+	return storeError(tx.Commit(ctx), "astronaut")
+}
+
+// InTx runs fn against a store bound to one transaction, committed when fn
+// returns nil. Calling InTx on that store nests a savepoint.
+func (s *astronautStore) InTx(ctx context.Context, fn func(model.AstronautStore) error) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return storeError(err, "astronaut")
+	}
+	defer tx.Rollback(ctx)
+
+	if err := fn(&astronautStore{db: tx}); err != nil {
+		return err
+	}
+
+	return storeError(tx.Commit(ctx), "astronaut")
+}
+
+// Redirect returns the ID an astronaut was merged into, or 0 when there is none
+func (s *astronautStore) Redirect(ctx context.Context, id int) (int, error) {
+	var newID int
+
+	query := `SELECT new_id FROM astronaut_redirect WHERE old_id=$1;`
+	err := s.db.QueryRow(ctx, query, id).Scan(&newID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, storeError(err, "astronaut")
+	}
+
+	return newID, nil
+}
+
+func (s *astronautStore) History(ctx context.Context, id int) ([]*model.HistoryEntry, error) {
+	history := make([]*model.HistoryEntry, 0)
+
+	query := `SELECT id, astronaut_id, event, snapshot, recorded_at FROM astronaut_history
+  WHERE astronaut_id=$1 ORDER BY recorded_at ASC, id ASC;`
+	rows, err := s.db.Query(ctx, query, id)
+	if err != nil {
+		return nil, storeError(err, "astronaut")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		h := new(model.HistoryEntry)
+		if err := rows.Scan(&h.ID, &h.AstronautID, &h.Event, &h.Snapshot, &h.RecordedAt); err != nil {
+			return nil, storeError(err, "astronaut")
+		}
+		history = append(history, h)
+	}
+
+	return history, storeError(rows.Err(), "astronaut")
+}
+
+func (s *astronautStore) Count(ctx context.Context) (int, error) {
+	var count int
+
+	query := `SELECT COUNT(*) FROM astronaut;`
+	if err := s.db.QueryRow(ctx, query).Scan(&count); err != nil {
+		return 0, storeError(err, "astronaut")
+	}
+
+	return count, nil
+}
+
+// Crewmates returns the astronauts sharing at least one mission with id
+func (s *astronautStore) Crewmates(ctx context.Context, id int) ([]*model.Astronaut, error) {
+	astronauts := make([]*model.Astronaut, 0)
+
+	query := `SELECT ` + astronautColumns + ` FROM astronaut
+  WHERE id<>$1 AND missions && (SELECT missions FROM astronaut WHERE id=$1) ORDER BY name ASC;`
+	rows, err := s.db.Query(ctx, query, id)
+	if err != nil {
+		return nil, storeError(err, "astronaut")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		a, err := fromRowToAstronaut(rows)
+		if err != nil {
+			return nil, storeError(err, "astronaut")
+		}
+		astronauts = append(astronauts, a)
+	}
+
+	return astronauts, storeError(rows.Err(), "astronaut")
+}
+
+// LastModified returns when any astronaut was last created, changed or
+// removed, or the zero time for an untouched table
+func (s *astronautStore) LastModified(ctx context.Context) (time.Time, error) {
+	var t *time.Time
+
+	query := `SELECT MAX(recorded_at) FROM astronaut_history;`
+	if err := s.db.QueryRow(ctx, query).Scan(&t); err != nil {
+		return time.Time{}, err
+	}
+
+	if t == nil {
+		return time.Time{}, nil
+	}
+	return *t, nil
+}
+
+func fromRowToAstronaut(r pgx.Row) (*model.Astronaut, error) {
 	a := new(model.Astronaut)
 	err := r.Scan(&a.ID, &a.Name, &a.Year, &a.Group, &a.Status, &a.BirthDate, &a.BirthPlace,
 		&a.Gender, &a.AlmaMater, &a.UndergraduateMajor, &a.GraduateMajor, &a.MilitaryRank, &a.MilitaryBranch, &a.SpaceFlights,
-		&a.SpaceFlightHours, &a.SpaceWalks, &a.SpaceWalkHours, &a.Missions, &a.DeathDate, &a.DeathMission)
+		&a.SpaceFlightHours, &a.SpaceWalks, &a.SpaceWalkHours, &a.Missions, &a.DeathDate, &a.DeathMission, &a.Version, &a.UpdatedAt)
 	if err != nil {
-		return nil, err
+		return nil, storeError(err, "astronaut")
 	}
 
 	return a, nil
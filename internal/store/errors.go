@@ -0,0 +1,61 @@
+package store
+
+import (
+	"context"
+	"errors"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// postgres error codes, https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	uniqueViolation     = "23505"
+	foreignKeyViolation = "23503"
+	queryCanceled       = "57014"
+)
+
+// constraints whose violation has a dedicated error code
+var constraintCodes = map[string]struct{ code, msg string }{
+	"user_email_key": {"email_taken", "email address is already registered"},
+}
+
+// storeError translates database errors into typed api errors, resource names
+// the record kind in the error code (e.g. astronaut_not_found)
+func storeError(err error, resource string) error {
+	if err == nil {
+		return nil
+	}
+
+	if errors.As(err, new(*model.ApiError)) {
+		return err
+	}
+
+	var (
+		pgErr      *pgconn.PgError
+		connectErr *pgconn.ConnectError
+	)
+
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return model.WrapError(model.KindNotFound, resource+"_not_found", resource+" not found", err)
+
+	case errors.As(err, &pgErr) && pgErr.Code == uniqueViolation:
+		if c, ok := constraintCodes[pgErr.ConstraintName]; ok {
+			return model.WrapError(model.KindConflict, c.code, c.msg, err)
+		}
+		return model.WrapError(model.KindConflict, resource+"_exists", resource+" already exists", err)
+
+	case errors.As(err, &pgErr) && pgErr.Code == foreignKeyViolation:
+		return model.WrapError(model.KindConflict, resource+"_referenced", resource+" is referenced by another record", err)
+
+	case errors.Is(err, context.DeadlineExceeded), pgconn.Timeout(err), errors.As(err, &pgErr) && pgErr.Code == queryCanceled:
+		return model.WrapError(model.KindTimeout, "database_timeout", "database did not respond in time", err)
+
+	case errors.As(err, &connectErr):
+		return model.WrapError(model.KindUnavailable, "database_unavailable", "database is unavailable", err)
+	}
+
+	return model.WrapError(model.KindInternal, "internal_error", "internal error", err)
+}
@@ -0,0 +1,72 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type IdempotencyStore struct {
+	db *pgxpool.Pool
+}
+
+func NewIdempotencyStore(db *pgxpool.Pool) *IdempotencyStore {
+	return &IdempotencyStore{
+		db: db,
+	}
+}
+
+func (s *IdempotencyStore) Claim(ctx context.Context, rec *model.IdempotencyRecord, expiry time.Time) (*model.IdempotencyRecord, error) {
+	query := `INSERT INTO idempotency_key (user_id, key, request_hash) VALUES ($1, $2, $3)
+  ON CONFLICT (user_id, key) DO UPDATE SET request_hash=EXCLUDED.request_hash, status=NULL, headers=NULL, body=NULL,
+  created_at=(now() AT TIME ZONE 'utc') WHERE idempotency_key.created_at < $4
+  RETURNING created_at;`
+
+	err := s.db.QueryRow(ctx, query, rec.UserID, rec.Key, rec.RequestHash, expiry).Scan(&rec.CreatedAt)
+	if err == nil {
+		return nil, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, storeError(err, "idempotency_key")
+	}
+
+	existing := &model.IdempotencyRecord{UserID: rec.UserID, Key: rec.Key}
+	var status *int
+
+	query = `SELECT request_hash, status, headers, body, created_at FROM idempotency_key WHERE user_id=$1 AND key=$2;`
+	err = s.db.QueryRow(ctx, query, rec.UserID, rec.Key).Scan(&existing.RequestHash, &status, &existing.Headers, &existing.Body, &existing.CreatedAt)
+	if err != nil {
+		return nil, storeError(err, "idempotency_key")
+	}
+
+	if status != nil {
+		existing.Status = *status
+	}
+	return existing, nil
+}
+
+func (s *IdempotencyStore) Complete(ctx context.Context, rec *model.IdempotencyRecord) error {
+	query := `UPDATE idempotency_key SET status=$1, headers=$2, body=$3 WHERE user_id=$4 AND key=$5;`
+	_, err := s.db.Exec(ctx, query, rec.Status, rec.Headers, rec.Body, rec.UserID, rec.Key)
+	return storeError(err, "idempotency_key")
+}
+
+// Release drops a pending record so the key can be retried
+func (s *IdempotencyStore) Release(ctx context.Context, userID int, key string) error {
+	query := `DELETE FROM idempotency_key WHERE user_id=$1 AND key=$2 AND status IS NULL;`
+	_, err := s.db.Exec(ctx, query, userID, key)
+	return storeError(err, "idempotency_key")
+}
+
+func (s *IdempotencyStore) DeleteExpired(ctx context.Context, expiry time.Time) (int64, error) {
+	query := `DELETE FROM idempotency_key WHERE created_at < $1;`
+	tag, err := s.db.Exec(ctx, query, expiry)
+	if err != nil {
+		return 0, storeError(err, "idempotency_key")
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,78 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type InvitationStore struct {
+	db *pgxpool.Pool
+}
+
+func NewInvitationStore(db *pgxpool.Pool) *InvitationStore {
+	return &InvitationStore{
+		db: db,
+	}
+}
+
+func (s *InvitationStore) Create(ctx context.Context, inv *model.Invitation) error {
+	return pgx.BeginFunc(ctx, s.db, func(tx pgx.Tx) error {
+		query := `DELETE FROM invitation WHERE email=$1 AND accepted_at IS NULL;`
+		if _, err := tx.Exec(ctx, query, inv.Email); err != nil {
+			return storeError(err, "invitation")
+		}
+
+		query = `INSERT INTO invitation (email, token_hash, invited_by, expires_at) VALUES ($1, $2, $3, $4)
+  RETURNING id, created_at;`
+		err := tx.QueryRow(ctx, query, inv.Email, inv.TokenHash, inv.InvitedBy, inv.ExpiresAt).Scan(&inv.ID, &inv.CreatedAt)
+		return storeError(err, "invitation")
+	})
+}
+
+func (s *InvitationStore) GetByHash(ctx context.Context, hash string) (*model.Invitation, error) {
+	inv := new(model.Invitation)
+
+	query := `SELECT id, email, token_hash, invited_by, expires_at, accepted_at, created_at FROM invitation WHERE token_hash=$1;`
+	err := s.db.QueryRow(ctx, query, hash).Scan(&inv.ID, &inv.Email, &inv.TokenHash, &inv.InvitedBy, &inv.ExpiresAt, &inv.AcceptedAt, &inv.CreatedAt)
+	if err != nil {
+		return nil, storeError(err, "invitation")
+	}
+	return inv, nil
+}
+
+func (s *InvitationStore) Accept(ctx context.Context, id int, u *model.User, k *model.APIKey, now time.Time) (int, error) {
+	var userID int
+
+	err := pgx.BeginFunc(ctx, s.db, func(tx pgx.Tx) error {
+		query := `UPDATE invitation SET accepted_at=$1 WHERE id=$2 AND accepted_at IS NULL AND expires_at > $1;`
+		tag, err := tx.Exec(ctx, query, now, id)
+		if err != nil {
+			return storeError(err, "invitation")
+		}
+		if tag.RowsAffected() == 0 {
+			return storeError(pgx.ErrNoRows, "invitation")
+		}
+
+		userID, err = createUser(ctx, tx, u, k)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return userID, nil
+}
+
+// DeleteExpired removes invitations that expired before the given time,
+// accepted ones too as their admin exists by then
+func (s *InvitationStore) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	query := `DELETE FROM invitation WHERE expires_at < $1;`
+	tag, err := s.db.Exec(ctx, query, before)
+	if err != nil {
+		return 0, storeError(err, "invitation")
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,89 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type LockoutStore struct {
+	db *pgxpool.Pool
+}
+
+func NewLockoutStore(db *pgxpool.Pool) *LockoutStore {
+	return &LockoutStore{
+		db: db,
+	}
+}
+
+func (s *LockoutStore) Get(ctx context.Context, key string) (*model.AuthFailure, error) {
+	f := &model.AuthFailure{Key: key}
+
+	query := `SELECT failures, last_failure_at FROM auth_failure WHERE key=$1;`
+	err := s.db.QueryRow(ctx, query, key).Scan(&f.Failures, &f.LastFailureAt)
+	if err != nil {
+		return nil, storeError(err, "auth_failure")
+	}
+	return f, nil
+}
+
+func (s *LockoutStore) Fail(ctx context.Context, key string, now, since time.Time) (int, error) {
+	var failures int
+
+	query := `INSERT INTO auth_failure (key, failures, last_failure_at) VALUES ($1, 1, $2)
+  ON CONFLICT (key) DO UPDATE SET last_failure_at=EXCLUDED.last_failure_at,
+  failures=CASE WHEN auth_failure.last_failure_at < $3 THEN 1 ELSE auth_failure.failures+1 END
+  RETURNING failures;`
+
+	err := s.db.QueryRow(ctx, query, key, now, since).Scan(&failures)
+	return failures, storeError(err, "auth_failure")
+}
+
+// Delete is a no-op for a key without failures
+func (s *LockoutStore) Delete(ctx context.Context, key string) error {
+	query := `DELETE FROM auth_failure WHERE key=$1;`
+
+	_, err := s.db.Exec(ctx, query, key)
+	return storeError(err, "auth_failure")
+}
+
+func (s *LockoutStore) DeleteStale(ctx context.Context, before time.Time) (int64, error) {
+	query := `DELETE FROM auth_failure WHERE last_failure_at < $1;`
+
+	tag, err := s.db.Exec(ctx, query, before)
+	if err != nil {
+		return 0, storeError(err, "auth_failure")
+	}
+	return tag.RowsAffected(), nil
+}
+
+func (s *LockoutStore) CreateEvent(ctx context.Context, e *model.SecurityEvent) error {
+	query := `INSERT INTO security_event (type, user_id, ip, detail) VALUES ($1, $2, $3, $4) RETURNING id, created_at;`
+
+	err := s.db.QueryRow(ctx, query, e.Type, e.UserID, e.IP, e.Detail).Scan(&e.ID, &e.CreatedAt)
+	return storeError(err, "security_event")
+}
+
+// ListEvents returns the newest events first
+func (s *LockoutStore) ListEvents(ctx context.Context, limit, offset int) ([]*model.SecurityEvent, error) {
+	events := make([]*model.SecurityEvent, 0)
+
+	query := `SELECT id, type, user_id, ip, detail, created_at FROM security_event ORDER BY id DESC LIMIT $1 OFFSET $2;`
+	rows, err := s.db.Query(ctx, query, limit, offset)
+	if err != nil {
+		return nil, storeError(err, "security_event")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		e := new(model.SecurityEvent)
+		if err := rows.Scan(&e.ID, &e.Type, &e.UserID, &e.IP, &e.Detail, &e.CreatedAt); err != nil {
+			return nil, storeError(err, "security_event")
+		}
+		events = append(events, e)
+	}
+
+	return events, storeError(rows.Err(), "security_event")
+}
@@ -0,0 +1,103 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// RateLimitStore keeps buckets in postgres so every instance shares them
+type RateLimitStore struct {
+	db *pgxpool.Pool
+}
+
+func NewRateLimitStore(db *pgxpool.Pool) *RateLimitStore {
+	return &RateLimitStore{
+		db: db,
+	}
+}
+
+func (s *RateLimitStore) Take(ctx context.Context, key string, l model.RateLimit, now time.Time) (*model.RateLimitBucket, bool, error) {
+	b := new(model.RateLimitBucket)
+	var taken bool
+
+	err := pgx.BeginFunc(ctx, s.db, func(tx pgx.Tx) error {
+		// the row lock serialises concurrent requests of the same key
+		query := `SELECT tokens, updated_at FROM rate_limit_bucket WHERE key=$1 FOR UPDATE;`
+
+		err := tx.QueryRow(ctx, query, key).Scan(&b.Tokens, &b.UpdatedAt)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return storeError(err, "rate_limit_bucket")
+		}
+
+		taken = b.Take(l, now)
+
+		query = `INSERT INTO rate_limit_bucket (key, tokens, updated_at) VALUES ($1, $2, $3)
+  ON CONFLICT (key) DO UPDATE SET tokens=EXCLUDED.tokens, updated_at=EXCLUDED.updated_at;`
+
+		_, err = tx.Exec(ctx, query, key, b.Tokens, b.UpdatedAt)
+		return storeError(err, "rate_limit_bucket")
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	return b, taken, nil
+}
+
+func (s *RateLimitStore) DeleteIdle(ctx context.Context, before time.Time) (int64, error) {
+	query := `DELETE FROM rate_limit_bucket WHERE updated_at < $1;`
+
+	tag, err := s.db.Exec(ctx, query, before)
+	if err != nil {
+		return 0, storeError(err, "rate_limit_bucket")
+	}
+	return tag.RowsAffected(), nil
+}
+
+// MemoryRateLimitStore keeps buckets in process, each instance of the api
+// limits on its own
+type MemoryRateLimitStore struct {
+	mu      sync.Mutex
+	buckets map[string]*model.RateLimitBucket
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		buckets: make(map[string]*model.RateLimitBucket),
+	}
+}
+
+func (s *MemoryRateLimitStore) Take(ctx context.Context, key string, l model.RateLimit, now time.Time) (*model.RateLimitBucket, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = new(model.RateLimitBucket)
+		s.buckets[key] = b
+	}
+
+	taken := b.Take(l, now)
+	copied := *b
+	return &copied, taken, nil
+}
+
+func (s *MemoryRateLimitStore) DeleteIdle(ctx context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var n int64
+	for key, b := range s.buckets {
+		if b.UpdatedAt.Before(before) {
+			delete(s.buckets, key)
+			n++
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,99 @@
+package store
+
+import (
+	"context"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const roleColumns = `name, description, permissions, builtin, created_at, updated_at, version`
+
+type RoleStore struct {
+	db *pgxpool.Pool
+}
+
+func NewRoleStore(db *pgxpool.Pool) *RoleStore {
+	return &RoleStore{
+		db: db,
+	}
+}
+
+func (s *RoleStore) List(ctx context.Context) ([]*model.Role, error) {
+	roles := make([]*model.Role, 0)
+
+	query := `SELECT ` + roleColumns + ` FROM role ORDER BY name ASC;`
+	rows, err := s.db.Query(ctx, query)
+	if err != nil {
+		return nil, storeError(err, "role")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		r, err := fromRowToRole(rows)
+		if err != nil {
+			return nil, err
+		}
+		roles = append(roles, r)
+	}
+
+	return roles, storeError(rows.Err(), "role")
+}
+
+func (s *RoleStore) Get(ctx context.Context, name string) (*model.Role, error) {
+	query := `SELECT ` + roleColumns + ` FROM role WHERE name=$1;`
+
+	return fromRowToRole(s.db.QueryRow(ctx, query, name))
+}
+
+func (s *RoleStore) Create(ctx context.Context, r *model.Role) error {
+	query := `INSERT INTO role (name, description, permissions, created_at, updated_at) VALUES ($1, $2, $3, $4, $5)
+  RETURNING version;`
+
+	err := s.db.QueryRow(ctx, query, r.Name, r.Description, r.Permissions, r.CreatedAt, r.UpdatedAt).Scan(&r.Version)
+	return storeError(err, "role")
+}
+
+// Update only matches the expected version, a version of 0 skips the check
+func (s *RoleStore) Update(ctx context.Context, r *model.Role) error {
+	query := `UPDATE role SET description=$1, permissions=$2, updated_at=$3, version=version+1
+  WHERE name=$4 AND ($5=0 OR version=$5) RETURNING version;`
+
+	row := s.db.QueryRow(ctx, query, r.Description, r.Permissions, r.UpdatedAt, r.Name, r.Version)
+	return storeError(scanUpdate(row, &r.Version), "role")
+}
+
+func (s *RoleStore) Delete(ctx context.Context, name string, version int) error {
+	query := `DELETE FROM role WHERE name=$1 AND ($2=0 OR version=$2);`
+
+	tag, err := s.db.Exec(ctx, query, name, version)
+	if err != nil {
+		return storeError(err, "role")
+	}
+
+	if tag.RowsAffected() == 0 {
+		var exists bool
+
+		query = `SELECT EXISTS (SELECT 1 FROM role WHERE name=$1);`
+		if err := s.db.QueryRow(ctx, query, name).Scan(&exists); err != nil {
+			return storeError(err, "role")
+		}
+
+		if !exists {
+			return storeError(pgx.ErrNoRows, "role")
+		}
+		return model.ErrVersionConflict
+	}
+	return nil
+}
+
+func fromRowToRole(r pgx.Row) (*model.Role, error) {
+	role := new(model.Role)
+
+	err := r.Scan(&role.Name, &role.Description, &role.Permissions, &role.Builtin, &role.CreatedAt, &role.UpdatedAt, &role.Version)
+	if err != nil {
+		return nil, storeError(err, "role")
+	}
+	return role, nil
+}
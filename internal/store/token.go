@@ -0,0 +1,74 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type RefreshTokenStore struct {
+	db *pgxpool.Pool
+}
+
+func NewRefreshTokenStore(db *pgxpool.Pool) *RefreshTokenStore {
+	return &RefreshTokenStore{
+		db: db,
+	}
+}
+
+func (s *RefreshTokenStore) Create(ctx context.Context, t *model.RefreshToken) error {
+	query := `INSERT INTO refresh_token (user_id, token_hash, family, expires_at) VALUES ($1, $2, $3, $4)
+  RETURNING id, created_at;`
+
+	err := s.db.QueryRow(ctx, query, t.UserID, t.TokenHash, t.Family, t.ExpiresAt).Scan(&t.ID, &t.CreatedAt)
+	return storeError(err, "refresh_token")
+}
+
+func (s *RefreshTokenStore) GetByHash(ctx context.Context, hash string) (*model.RefreshToken, error) {
+	t := new(model.RefreshToken)
+
+	query := `SELECT id, user_id, token_hash, family, expires_at, revoked_at, created_at FROM refresh_token WHERE token_hash=$1;`
+	err := s.db.QueryRow(ctx, query, hash).Scan(&t.ID, &t.UserID, &t.TokenHash, &t.Family, &t.ExpiresAt, &t.RevokedAt, &t.CreatedAt)
+	if err != nil {
+		return nil, storeError(err, "refresh_token")
+	}
+	return t, nil
+}
+
+func (s *RefreshTokenStore) Rotate(ctx context.Context, old, next *model.RefreshToken) error {
+	return pgx.BeginFunc(ctx, s.db, func(tx pgx.Tx) error {
+		query := `UPDATE refresh_token SET revoked_at=(now() AT TIME ZONE 'utc') WHERE id=$1 AND revoked_at IS NULL;`
+		tag, err := tx.Exec(ctx, query, old.ID)
+		if err != nil {
+			return storeError(err, "refresh_token")
+		}
+		if tag.RowsAffected() == 0 {
+			return model.NewError(model.KindConflict, "refresh_token_revoked", "refresh token was already used")
+		}
+
+		query = `INSERT INTO refresh_token (user_id, token_hash, family, expires_at) VALUES ($1, $2, $3, $4)
+  RETURNING id, created_at;`
+		err = tx.QueryRow(ctx, query, next.UserID, next.TokenHash, next.Family, next.ExpiresAt).Scan(&next.ID, &next.CreatedAt)
+		return storeError(err, "refresh_token")
+	})
+}
+
+func (s *RefreshTokenStore) RevokeFamily(ctx context.Context, family string) error {
+	query := `UPDATE refresh_token SET revoked_at=(now() AT TIME ZONE 'utc') WHERE family=$1 AND revoked_at IS NULL;`
+	_, err := s.db.Exec(ctx, query, family)
+	return storeError(err, "refresh_token")
+}
+
+// DeleteExpired removes tokens that expired before the given time, revoked
+// ones are kept until then so reuse is still detected
+func (s *RefreshTokenStore) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	query := `DELETE FROM refresh_token WHERE expires_at < $1;`
+	tag, err := s.db.Exec(ctx, query, before)
+	if err != nil {
+		return 0, storeError(err, "refresh_token")
+	}
+	return tag.RowsAffected(), nil
+}
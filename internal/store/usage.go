@@ -0,0 +1,90 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type UsageStore struct {
+	db *pgxpool.Pool
+}
+
+func NewUsageStore(db *pgxpool.Pool) *UsageStore {
+	return &UsageStore{
+		db: db,
+	}
+}
+
+func (s *UsageStore) Add(ctx context.Context, records []*model.UsageRecord) error {
+	return pgx.BeginFunc(ctx, s.db, func(tx pgx.Tx) error {
+		// users deleted since the request was counted would fail the whole
+		// flush on their foreign key
+		query := `INSERT INTO usage (user_id, day, endpoint, requests, bytes)
+  SELECT $1, $2, $3, $4, $5 WHERE EXISTS (SELECT 1 FROM "user" WHERE id=$1)
+  ON CONFLICT (user_id, day, endpoint) DO UPDATE SET requests=usage.requests+EXCLUDED.requests, bytes=usage.bytes+EXCLUDED.bytes;`
+
+		batch := new(pgx.Batch)
+		for _, rec := range records {
+			batch.Queue(query, rec.UserID, rec.Day, rec.Endpoint, rec.Requests, rec.Bytes)
+		}
+
+		return storeError(tx.SendBatch(ctx, batch).Close(), "usage")
+	})
+}
+
+func (s *UsageStore) List(ctx context.Context, userID int, from, to time.Time) ([]*model.UsageRecord, error) {
+	records := make([]*model.UsageRecord, 0)
+
+	query := `SELECT user_id, day, endpoint, requests, bytes FROM usage WHERE user_id=$1 AND day BETWEEN $2 AND $3
+  ORDER BY day ASC, endpoint ASC;`
+	rows, err := s.db.Query(ctx, query, userID, from, to)
+	if err != nil {
+		return nil, storeError(err, "usage")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		rec := new(model.UsageRecord)
+		if err := rows.Scan(&rec.UserID, &rec.Day, &rec.Endpoint, &rec.Requests, &rec.Bytes); err != nil {
+			return nil, storeError(err, "usage")
+		}
+		records = append(records, rec)
+	}
+
+	return records, storeError(rows.Err(), "usage")
+}
+
+// Report returns the heaviest users first
+func (s *UsageStore) Report(ctx context.Context, from, to time.Time) ([]*model.UsageTotal, error) {
+	totals := make([]*model.UsageTotal, 0)
+
+	query := `SELECT user_id, SUM(requests)::BIGINT, SUM(bytes)::BIGINT FROM usage WHERE day BETWEEN $1 AND $2
+  GROUP BY user_id ORDER BY SUM(requests) DESC, user_id ASC;`
+	rows, err := s.db.Query(ctx, query, from, to)
+	if err != nil {
+		return nil, storeError(err, "usage")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		t := new(model.UsageTotal)
+		if err := rows.Scan(&t.UserID, &t.Requests, &t.Bytes); err != nil {
+			return nil, storeError(err, "usage")
+		}
+		totals = append(totals, t)
+	}
+
+	return totals, storeError(rows.Err(), "usage")
+}
+
+func (s *UsageStore) Requests(ctx context.Context, userID int, from time.Time) (int64, error) {
+	var n int64
+
+	query := `SELECT COALESCE(SUM(requests), 0)::BIGINT FROM usage WHERE user_id=$1 AND day >= $2;`
+	err := s.db.QueryRow(ctx, query, userID, from).Scan(&n)
+	return n, storeError(err, "usage")
+}
@@ -8,6 +8,11 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// userColumns load the permissions of the user's role along with the user,
+// the password hash is only loaded by SearchEmail to check logins
+const userColumns = `id, first_name, surname, email, role, email_verified_at, created_at, updated_at, version,
+  (SELECT permissions FROM role WHERE role.name = "user".role)`
+
 type UserStore struct {
 	db *pgxpool.Pool
 }
@@ -18,34 +23,49 @@ func NewUserStore(db *pgxpool.Pool) *UserStore {
 	}
 }
 
-func (s *UserStore) Create(ctx context.Context, u *model.User) (int, error) {
+func (s *UserStore) Create(ctx context.Context, u *model.User, k *model.APIKey) (int, error) {
 	var id int
 
-	query := ` INSERT INTO "user" (first_name, surname, email, password, api_key, role, created_at, updated_at)
-  VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id;`
-
-	err := s.db.QueryRow(ctx, query, u.FirstName, u.Surename, u.Email, u.Password, u.ApiKey, u.Role, u.CreatedAt, u.UpdatedAt).Scan(&id)
+	err := pgx.BeginFunc(ctx, s.db, func(tx pgx.Tx) error {
+		var err error
+		id, err = createUser(ctx, tx, u, k)
+		return err
+	})
 	if err != nil {
-		// check for postgres unique constraint error (code = 23505)
 		return 0, err
 	}
 	return id, nil
 }
 
+// createUser stores u and its first key k in tx
+func createUser(ctx context.Context, tx pgx.Tx, u *model.User, k *model.APIKey) (int, error) {
+	var id int
+
+	query := ` INSERT INTO "user" (first_name, surname, email, password, role, email_verified_at, created_at, updated_at)
+  VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id, version;`
+
+	if err := tx.QueryRow(ctx, query, u.FirstName, u.Surename, u.Email, u.Password, u.Role, u.EmailVerifiedAt, u.CreatedAt, u.UpdatedAt).Scan(&id, &u.Version); err != nil {
+		return 0, storeError(err, "user")
+	}
+
+	k.UserID = id
+	return id, createAPIKey(ctx, tx, k)
+}
+
 func (s *UserStore) List(ctx context.Context, limt, offset int) ([]*model.User, error) {
 	users := make([]*model.User, 0)
 
-	query := `SELECT * FROM "user" ORDER BY surname ASC LIMIT $1 OFFSET $2;`
+	query := `SELECT ` + userColumns + ` FROM "user" ORDER BY surname ASC LIMIT $1 OFFSET $2;`
 	rows, err := s.db.Query(ctx, query, limt, offset)
 	if err != nil {
-		return nil, err
+		return nil, storeError(err, "user")
 	}
 	defer rows.Close()
 
 	for rows.Next() {
 		u, err := fromRowToUser(rows)
 		if err != nil {
-			return nil, err
+			return nil, storeError(err, "user")
 		}
 		users = append(users, u)
 	}
@@ -53,73 +73,111 @@ func (s *UserStore) List(ctx context.Context, limt, offset int) ([]*model.User,
 	return users, nil
 }
 
-func (s *UserStore) Get(ctx context.Context, id int) (*model.User, error) {
-	u := new(model.User)
-	query := `SELECT * FROM "user" WHERE id=$1;`
+func (s *UserStore) Count(ctx context.Context) (int, error) {
+	var count int
 
-	if err := s.db.QueryRow(ctx, query, id).Scan(&u.ID, &u.FirstName, &u.Surename, &u.Email, &u.Password, &u.ApiKey, &u.Role, &u.CreatedAt, &u.UpdatedAt); err != nil {
-		return nil, err
+	query := `SELECT COUNT(*) FROM "user";`
+	if err := s.db.QueryRow(ctx, query).Scan(&count); err != nil {
+		return 0, storeError(err, "user")
 	}
-	return u, nil
+
+	return count, nil
 }
 
-func (s *UserStore) Update(ctx context.Context, u *model.User) error {
-	query := `UPDATE "user" SET first_name=$1, surname=$2, email=$3, role=$4, updated_at=$5
-  WHERE id=$8;`
+func (s *UserStore) CountRole(ctx context.Context, role string) (int, error) {
+	var count int
 
-	_, err := s.db.Exec(ctx, query, u.FirstName, u.Surename, u.Email, u.Role, u.UpdatedAt, u.ID)
-	if err != nil {
-		return err
+	query := `SELECT COUNT(*) FROM "user" WHERE role=$1;`
+	if err := s.db.QueryRow(ctx, query, role).Scan(&count); err != nil {
+		return 0, storeError(err, "user")
 	}
-	return nil
+
+	return count, nil
+}
+
+func (s *UserStore) Get(ctx context.Context, id int) (*model.User, error) {
+	query := `SELECT ` + userColumns + ` FROM "user" WHERE id=$1;`
+
+	return fromRowToUser(s.db.QueryRow(ctx, query, id))
+}
+
+// Update only matches the expected version, a version of 0 skips the check
+func (s *UserStore) Update(ctx context.Context, u *model.User) error {
+	err := pgx.BeginFunc(ctx, s.db, func(tx pgx.Tx) error {
+		// tokens mailed to a previous email stop working, whoever reads that
+		// mailbox may no longer be the user
+		query := `DELETE FROM user_token WHERE user_id=$1 AND email<>$2 AND used_at IS NULL;`
+		if _, err := tx.Exec(ctx, query, u.ID, u.Email); err != nil {
+			return err
+		}
+
+		// a new email is unverified until its owner confirms it
+		query = `UPDATE "user" SET first_name=$1, surname=$2, email=$3, role=$4, updated_at=$5, version=version+1,
+  email_verified_at=CASE WHEN email=$3 THEN email_verified_at ELSE NULL END
+  WHERE id=$6 AND ($7=0 OR version=$7) RETURNING version;`
+
+		row := tx.QueryRow(ctx, query, u.FirstName, u.Surename, u.Email, u.Role, u.UpdatedAt, u.ID, u.Version)
+		return scanUpdate(row, &u.Version)
+	})
+	return storeError(err, "user")
 }
 
-func (s *UserStore) Delete(ctx context.Context, id int) error {
-	query := `DELETE FROM "user" WHERE id=$1;`
+func (s *UserStore) Delete(ctx context.Context, id, version int) error {
+	query := `DELETE FROM "user" WHERE id=$1 AND ($2=0 OR version=$2);`
 
-	_, err := s.db.Exec(ctx, query, id)
+	tag, err := s.db.Exec(ctx, query, id, version)
 	if err != nil {
-		return err
+		return storeError(err, "user")
+	}
+
+	if tag.RowsAffected() == 0 {
+		return s.missingOrStale(ctx, id)
 	}
 	return nil
 }
 
-func (s *UserStore) SearchApiKey(ctx context.Context, key string) (*model.User, error) {
-	u := new(model.User)
-	query := `SELECT * FROM "user" WHERE api_key=$1;`
+// missingOrStale explains why a versioned write matched no row
+func (s *UserStore) missingOrStale(ctx context.Context, id int) error {
+	var exists bool
 
-	if err := s.db.QueryRow(ctx, query, key).Scan(&u.ID, &u.FirstName, &u.Surename, &u.Email, &u.Password, &u.ApiKey, &u.Role, &u.CreatedAt, &u.UpdatedAt); err != nil {
-		return nil, err
+	query := `SELECT EXISTS (SELECT 1 FROM "user" WHERE id=$1);`
+	if err := s.db.QueryRow(ctx, query, id).Scan(&exists); err != nil {
+		return storeError(err, "user")
 	}
-	return u, nil
+
+	if !exists {
+		return storeError(pgx.ErrNoRows, "user")
+	}
+	return model.ErrVersionConflict
 }
 
-func (s *UserStore) UpdatePassword(ctx context.Context, u *model.User) error {
-	query := `UPDATE "user" SET password=$1, updated_at=$2 WHERE id=$3;`
+func (s *UserStore) SearchEmail(ctx context.Context, email string) (*model.User, error) {
+	u := new(model.User)
 
-	_, err := s.db.Exec(ctx, query, u.Password, u.UpdatedAt, u.ID)
-	if err != nil {
-		return err
+	query := `SELECT ` + userColumns + `, password FROM "user" WHERE email=$1;`
+	if err := s.db.QueryRow(ctx, query, email).Scan(append(userFields(u), &u.Password)...); err != nil {
+		return nil, storeError(err, "user")
 	}
-	return nil
+	return u, nil
 }
 
-func (s *UserStore) UpdateAPIKey(ctx context.Context, u *model.User) error {
-	query := `UPDATE "user" SET api_key=$1, updated_at=$2 WHERE id=$3;`
+func (s *UserStore) UpdatePassword(ctx context.Context, u *model.User) error {
+	query := `UPDATE "user" SET password=$1, updated_at=$2, version=version+1 WHERE id=$3 RETURNING version;`
 
-	_, err := s.db.Exec(ctx, query, u.ApiKey, u.UpdatedAt, u.ID)
-	if err != nil {
-		return err
-	}
-	return nil
+	err := s.db.QueryRow(ctx, query, u.Password, u.UpdatedAt, u.ID).Scan(&u.Version)
+	return storeError(err, "user")
 }
 
-func fromRowToUser(r pgx.Rows) (*model.User, error) {
+func fromRowToUser(r pgx.Row) (*model.User, error) {
 	u := new(model.User)
 
-	err := r.Scan(&u.ID, &u.FirstName, &u.Surename, &u.Email, &u.Password, &u.ApiKey, &u.Role, &u.CreatedAt, &u.UpdatedAt)
-	if err != nil {
-		return nil, err
+	if err := r.Scan(userFields(u)...); err != nil {
+		return nil, storeError(err, "user")
 	}
 	return u, nil
 }
+
+// userFields are the scan destinations of userColumns
+func userFields(u *model.User) []any {
+	return []any{&u.ID, &u.FirstName, &u.Surename, &u.Email, &u.Role, &u.EmailVerifiedAt, &u.CreatedAt, &u.UpdatedAt, &u.Version, &u.Permissions}
+}
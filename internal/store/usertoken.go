@@ -0,0 +1,109 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type UserTokenStore struct {
+	db *pgxpool.Pool
+}
+
+func NewUserTokenStore(db *pgxpool.Pool) *UserTokenStore {
+	return &UserTokenStore{
+		db: db,
+	}
+}
+
+func (s *UserTokenStore) Create(ctx context.Context, t *model.UserToken) error {
+	return pgx.BeginFunc(ctx, s.db, func(tx pgx.Tx) error {
+		query := `DELETE FROM user_token WHERE user_id=$1 AND purpose=$2 AND used_at IS NULL;`
+		if _, err := tx.Exec(ctx, query, t.UserID, t.Purpose); err != nil {
+			return storeError(err, "user_token")
+		}
+
+		query = `INSERT INTO user_token (user_id, email, purpose, token_hash, expires_at) VALUES ($1, $2, $3, $4, $5)
+  RETURNING id, created_at;`
+		err := tx.QueryRow(ctx, query, t.UserID, t.Email, t.Purpose, t.TokenHash, t.ExpiresAt).Scan(&t.ID, &t.CreatedAt)
+		return storeError(err, "user_token")
+	})
+}
+
+func (s *UserTokenStore) ResetPassword(ctx context.Context, hash, password string, now time.Time) (int, error) {
+	var id int
+
+	err := pgx.BeginFunc(ctx, s.db, func(tx pgx.Tx) error {
+		userID, email, err := useToken(ctx, tx, hash, model.TokenPasswordReset, now)
+		if err != nil {
+			return err
+		}
+		id = userID
+
+		// the reset proves the user reads the address the token was mailed
+		// to, so it verifies that address if the user still has it
+		query := `UPDATE "user" SET password=$1, updated_at=$2, version=version+1,
+  email_verified_at=CASE WHEN email=$4 THEN COALESCE(email_verified_at, $2) ELSE email_verified_at END
+  WHERE id=$3;`
+		if _, err := tx.Exec(ctx, query, password, now, userID, email); err != nil {
+			return storeError(err, "user")
+		}
+
+		query = `UPDATE refresh_token SET revoked_at=$1 WHERE user_id=$2 AND revoked_at IS NULL;`
+		_, err = tx.Exec(ctx, query, now, userID)
+		return storeError(err, "refresh_token")
+	})
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func (s *UserTokenStore) VerifyEmail(ctx context.Context, hash string, now time.Time) (int, error) {
+	var id int
+
+	err := pgx.BeginFunc(ctx, s.db, func(tx pgx.Tx) error {
+		userID, email, err := useToken(ctx, tx, hash, model.TokenEmailVerification, now)
+		if err != nil {
+			return err
+		}
+		id = userID
+
+		query := `UPDATE "user" SET email_verified_at=COALESCE(email_verified_at, $1) WHERE id=$2 AND email=$3;`
+		_, err = tx.Exec(ctx, query, now, userID, email)
+		return storeError(err, "user")
+	})
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// DeleteExpired removes tokens that expired before the given time, used or
+// not
+func (s *UserTokenStore) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	query := `DELETE FROM user_token WHERE expires_at < $1;`
+	tag, err := s.db.Exec(ctx, query, before)
+	if err != nil {
+		return 0, storeError(err, "user_token")
+	}
+	return tag.RowsAffected(), nil
+}
+
+// useToken marks the unused, unexpired token with the hash and purpose used
+// and returns its user and the email it was mailed to, a token used
+// concurrently matches no row
+func useToken(ctx context.Context, tx pgx.Tx, hash, purpose string, now time.Time) (int, string, error) {
+	var (
+		userID int
+		email  string
+	)
+
+	query := `UPDATE user_token SET used_at=$1
+  WHERE token_hash=$2 AND purpose=$3 AND used_at IS NULL AND expires_at > $1 RETURNING user_id, email;`
+	err := tx.QueryRow(ctx, query, now, hash, purpose).Scan(&userID, &email)
+	return userID, email, storeError(err, "user_token")
+}
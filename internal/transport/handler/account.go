@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+	"github.com/LaQuannT/astronaut-data-api/internal/transport/middleware"
+	"github.com/LaQuannT/astronaut-data-api/internal/transport/util"
+	"github.com/gorilla/mux"
+)
+
+type (
+	accountHandler struct {
+		service model.AccountUsecase
+		log     *slog.Logger
+	}
+
+	emailRequest struct {
+		Email string `json:"email"`
+	}
+
+	passwordResetRequest struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+
+	tokenRequest struct {
+		Token string `json:"token"`
+	}
+)
+
+// RegisterAccountHandlers serves the password reset and email verification
+// endpoints, they are public and limited by client IP
+func RegisterAccountHandlers(s model.AccountUsecase, rl model.RateLimitUsecase, r *mux.Router, l *slog.Logger) {
+	handler := &accountHandler{
+		service: s,
+		log:     l,
+	}
+
+	sr := r.PathPrefix("/auth").Subrouter()
+	sr.Use(middleware.RateLimit(rl, l), middleware.Validation)
+
+	sr.HandleFunc("/password/forgot", handler.ForgotPassword).Methods("POST")
+	sr.HandleFunc("/password/reset", handler.ResetPassword).Methods("POST")
+	sr.HandleFunc("/email/verify", handler.VerifyEmail).Methods("POST")
+	sr.HandleFunc("/email/resend", handler.ResendVerification).Methods("POST")
+}
+
+// ForgotPassword answers the same whether or not the email is registered
+func (h *accountHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	in := new(emailRequest)
+
+	if err := json.NewDecoder(r.Body).Decode(in); err != nil {
+		util.WriteError(w, r, errInvalidBody)
+		h.log.Warn("error decoding json request body", slog.Any("error", err))
+		return
+	}
+
+	if err := h.service.ForgotPassword(r.Context(), in.Email); err != nil {
+		util.WriteError(w, r, err)
+		h.log.Warn("error requesting password reset", slog.Any("error", err))
+		return
+	}
+
+	util.WriteJSON(w, http.StatusAccepted, model.JSONResponse{Message: "If the email is registered, a reset token was sent to it"})
+}
+
+func (h *accountHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	in := new(passwordResetRequest)
+
+	if err := json.NewDecoder(r.Body).Decode(in); err != nil {
+		util.WriteError(w, r, errInvalidBody)
+		h.log.Warn("error decoding json request body", slog.Any("error", err))
+		return
+	}
+
+	if err := h.service.ResetPassword(r.Context(), in.Token, in.Password); err != nil {
+		util.WriteError(w, r, err)
+		h.log.Warn("error resetting user password", slog.Any("error", err))
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, model.JSONResponse{Message: "User password reset"})
+}
+
+func (h *accountHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	in := new(tokenRequest)
+
+	if err := json.NewDecoder(r.Body).Decode(in); err != nil {
+		util.WriteError(w, r, errInvalidBody)
+		h.log.Warn("error decoding json request body", slog.Any("error", err))
+		return
+	}
+
+	if err := h.service.VerifyEmail(r.Context(), in.Token); err != nil {
+		util.WriteError(w, r, err)
+		h.log.Warn("error verifying email", slog.Any("error", err))
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, model.JSONResponse{Message: "Email verified"})
+}
+
+// ResendVerification answers the same whether or not the email is registered
+func (h *accountHandler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	in := new(emailRequest)
+
+	if err := json.NewDecoder(r.Body).Decode(in); err != nil {
+		util.WriteError(w, r, errInvalidBody)
+		h.log.Warn("error decoding json request body", slog.Any("error", err))
+		return
+	}
+
+	if err := h.service.ResendVerification(r.Context(), in.Email); err != nil {
+		util.WriteError(w, r, err)
+		h.log.Warn("error resending verification email", slog.Any("error", err))
+		return
+	}
+
+	util.WriteJSON(w, http.StatusAccepted, model.JSONResponse{Message: "If the email is registered and unverified, a verification token was sent to it"})
+}
@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+	"github.com/LaQuannT/astronaut-data-api/internal/transport/middleware"
+	"github.com/LaQuannT/astronaut-data-api/internal/transport/util"
+	"github.com/gorilla/mux"
+)
+
+// maxDatasetSize caps uploaded csv datasets at 10MB
+const maxDatasetSize = 10 << 20
+
+// defaultDuplicateThreshold needs a close name plus a matching birth date or place
+const defaultDuplicateThreshold = 0.85
+
+type mergeRequest struct {
+	WinnerID int `json:"winnerId"`
+	LoserID  int `json:"loserId"`
+}
+
+type adminHandler struct {
+	astronautService model.AstronautUsecase
+	reconcileService model.ReconcileUsecase
+	qualityService   model.DataQualityUsecase
+	links            linker
+	log              *slog.Logger
+}
+
+func RegisterAdminHandlers(as model.AstronautUsecase, rs model.ReconcileUsecase, qs model.DataQualityUsecase, us model.UserUsecase, rl model.RateLimitUsecase, ms model.UsageUsecase, r *mux.Router, l *slog.Logger) {
+	handler := &adminHandler{
+		astronautService: as,
+		reconcileService: rs,
+		qualityService:   qs,
+		links:            newLinker(r, "v1"),
+		log:              l,
+	}
+
+	sr := r.PathPrefix("/admin").Subrouter()
+	sr.Use(middleware.APIKeyValidation(us, l), middleware.RateLimit(rl, l), middleware.Metering(ms, l), middleware.Validation)
+
+	sr.HandleFunc("/reconcile", handler.DiffDataset).Methods("POST")
+	sr.HandleFunc("/reconcile/apply", handler.ApplyDataset).Methods("POST")
+	sr.HandleFunc("/data-quality", handler.DataQualityReport).Methods("GET")
+	sr.HandleFunc("/astronauts/duplicates", handler.ListDuplicates).Methods("GET")
+	sr.HandleFunc("/astronauts/merge", handler.MergeAstronauts).Methods("POST")
+}
+
+func (h *adminHandler) DiffDataset(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	body := http.MaxBytesReader(w, r.Body, maxDatasetSize)
+
+	diff, err := h.reconcileService.Diff(ctx, body)
+	if err != nil {
+		util.WriteError(w, r, err)
+		h.log.Warn("error comparing dataset", slog.Any("error", err))
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, model.JSONResponse{Diff: diff})
+}
+
+// ApplyDataset applies the changes selected by the repeatable 'key' query
+// parameter, or every change when none is given
+func (h *adminHandler) ApplyDataset(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	body := http.MaxBytesReader(w, r.Body, maxDatasetSize)
+
+	keys := r.URL.Query()["key"]
+
+	diff, err := h.reconcileService.Apply(ctx, body, keys)
+	if err != nil {
+		util.WriteError(w, r, err)
+		h.log.Warn("error applying dataset", slog.Any("error", err))
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, model.JSONResponse{Diff: diff, Message: "Dataset applied"})
+}
+
+// DataQualityReport lists inconsistent astronaut records, 'format=csv' exports
+// the findings for curators
+func (h *adminHandler) DataQualityReport(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	findings, err := h.qualityService.Report(ctx)
+	if err != nil {
+		util.WriteError(w, r, err)
+		h.log.Warn("error building data quality report", slog.Any("error", err))
+		return
+	}
+
+	if r.URL.Query().Get("format") == "csv" {
+		records := make([][]string, 0, len(findings))
+		for _, f := range findings {
+			records = append(records, []string{strconv.Itoa(f.AstronautID), f.Rule, f.Severity, f.Message})
+		}
+
+		w.Header().Set("Content-Disposition", `attachment; filename="data-quality.csv"`)
+		util.WriteCSV(w, http.StatusOK, []string{"astronautId", "rule", "severity", "message"}, records)
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, model.JSONResponse{Findings: findings})
+}
+
+func (h *adminHandler) ListDuplicates(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	threshold, err := strconv.ParseFloat(r.URL.Query().Get("threshold"), 64)
+	if err != nil || threshold <= 0 || threshold > 1 {
+		threshold = defaultDuplicateThreshold
+	}
+
+	duplicates, err := h.astronautService.Duplicates(ctx, threshold)
+	if err != nil {
+		util.WriteError(w, r, err)
+		h.log.Warn("error finding duplicate astronauts", slog.Any("error", err))
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, model.JSONResponse{Duplicates: duplicates})
+}
+
+// MergeAstronauts folds the loser into the winner, the loser's ID keeps
+// redirecting to the winner afterwards
+func (h *adminHandler) MergeAstronauts(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	req := new(mergeRequest)
+
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		util.WriteError(w, r, errInvalidBody)
+		h.log.Warn("error decoding request body to merge request", slog.Any("error", err))
+		return
+	}
+
+	a, err := h.astronautService.Merge(ctx, req.WinnerID, req.LoserID)
+	if err != nil {
+		util.WriteError(w, r, err)
+		h.log.Warn("error merging astronauts", slog.Any("error", err))
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, model.JSONResponse{Astronaut: h.links.astronaut(a), Message: "Astronauts merged"})
+}
@@ -2,36 +2,52 @@ package handler
 
 import (
 	"encoding/json"
+	"io"
 	"log/slog"
+	"mime"
 	"net/http"
 	"net/url"
 	"strconv"
 
 	"github.com/LaQuannT/astronaut-data-api/internal/model"
+	"github.com/LaQuannT/astronaut-data-api/internal/patch"
 	"github.com/LaQuannT/astronaut-data-api/internal/transport/middleware"
 	"github.com/LaQuannT/astronaut-data-api/internal/transport/util"
 	"github.com/gorilla/mux"
 )
 
+// astronautMediaTypes extends the generic response types with schema.org
+// json-ld
+var astronautMediaTypes = []string{util.JSONContentType, util.JSONLDContentType, util.XMLContentType, util.YAMLContentType, util.CSVContentType}
+
 type astronautHandler struct {
 	service model.AstronautUsecase
+	links   linker
 	log     *slog.Logger
 }
 
-func RegisterAstronautHandlers(s model.AstronautUsecase, us model.UserUsecase, r *mux.Router, l *slog.Logger) {
+func RegisterAstronautHandlers(s model.AstronautUsecase, us model.UserUsecase, is model.IdempotencyUsecase, rl model.RateLimitUsecase, ms model.UsageUsecase, r *mux.Router, l *slog.Logger) {
 	handler := &astronautHandler{
 		service: s,
+		links:   newLinker(r, "v1"),
 		log:     l,
 	}
 
 	sr := r.PathPrefix("/astronauts").Subrouter()
-	sr.Use(middleware.APIKeyValidation(us, l))
+	sr.Use(middleware.APIKeyValidation(us, l), middleware.RateLimit(rl, l), middleware.Metering(ms, l), middleware.Validation, middleware.Idempotency(is, l))
+
+	r.Handle("/catalog", middleware.Validation(http.HandlerFunc(handler.DataCatalog))).Methods("GET")
 
 	sr.HandleFunc("", handler.CreateAstronaut).Methods("POST")
-	sr.HandleFunc("", handler.ListAstronauts).Methods("GET")
-	sr.HandleFunc("/{astronautID}", handler.GetAstronaut).Methods("GET")
+	sr.HandleFunc("", handler.ListAstronauts).Methods("GET").Name(handler.links.name(routeAstronauts))
+	sr.HandleFunc("/batch", handler.BatchAstronauts).Methods("POST")
+	sr.HandleFunc("/{astronautID}", handler.GetAstronaut).Methods("GET").Name(handler.links.name(routeAstronaut))
 	sr.HandleFunc("/{astronautID}", handler.UpdateAstronaut).Methods("PUT")
+	sr.HandleFunc("/{astronautID}", handler.PatchAstronaut).Methods("PATCH")
 	sr.HandleFunc("/{astronautID}", handler.DeleteAstronaut).Methods("DELETE")
+	sr.HandleFunc("/{astronautID}/history", handler.AstronautHistory).Methods("GET").Name(handler.links.name(routeAstronautHistory))
+	sr.HandleFunc("/{astronautID}/missions", handler.AstronautMissions).Methods("GET").Name(handler.links.name(routeAstronautMissions))
+	sr.HandleFunc("/{astronautID}/crewmates", handler.AstronautCrewmates).Methods("GET").Name(handler.links.name(routeAstronautCrewmates))
 }
 
 func (h *astronautHandler) CreateAstronaut(w http.ResponseWriter, r *http.Request) {
@@ -39,19 +55,20 @@ func (h *astronautHandler) CreateAstronaut(w http.ResponseWriter, r *http.Reques
 	ctx := r.Context()
 
 	if err := json.NewDecoder(r.Body).Decode(a); err != nil {
-		util.WriteJSON(w, http.StatusBadRequest, model.JSONResponse{Error: "Invalid request body"})
+		util.WriteError(w, r, errInvalidBody)
 		h.log.Warn("error decoding request body to astronaut", slog.Any("error", err))
 		return
 	}
 
 	a, err := h.service.Create(ctx, a)
 	if err != nil {
-		util.WriteJSON(w, http.StatusBadRequest, model.JSONResponse{Error: "Bad Request"})
+		util.WriteError(w, r, err)
 		h.log.Warn("error creating new astronaut", slog.Any("error", err))
 		return
 	}
 
-	util.WriteJSON(w, http.StatusCreated, model.JSONResponse{Astronaut: a})
+	util.SetETag(w, a.Version)
+	util.WriteJSON(w, http.StatusCreated, model.JSONResponse{Astronaut: h.links.astronaut(a)})
 }
 
 func (h *astronautHandler) ListAstronauts(w http.ResponseWriter, r *http.Request) {
@@ -59,7 +76,7 @@ func (h *astronautHandler) ListAstronauts(w http.ResponseWriter, r *http.Request
 
 	params, err := url.ParseQuery(r.URL.RawQuery)
 	if err != nil {
-		util.WriteJSON(w, http.StatusBadRequest, model.JSONResponse{Error: "invalid request query"})
+		util.WriteError(w, r, errInvalidQuery)
 		h.log.Warn("error parsing url request query", slog.Any("error", err))
 		return
 	}
@@ -81,12 +98,55 @@ func (h *astronautHandler) ListAstronauts(w http.ResponseWriter, r *http.Request
 
 	astronauts, err := h.service.List(ctx, limit, offset)
 	if err != nil {
-		util.WriteJSON(w, http.StatusBadRequest, model.JSONResponse{Error: "Bad Request"})
+		util.WriteError(w, r, err)
 		h.log.Warn("error listing astronauts", slog.Any("error", err))
 		return
 	}
 
-	util.WriteJSON(w, http.StatusOK, model.JSONResponse{Astronauts: astronauts})
+	lastModified, err := h.service.LastModified(ctx)
+	if err != nil {
+		util.WriteError(w, r, err)
+		h.log.Warn("error fetching astronaut modification time", slog.Any("error", err))
+		return
+	}
+
+	total, err := h.service.Count(ctx)
+	if err != nil {
+		util.WriteError(w, r, err)
+		h.log.Warn("error counting astronauts", slog.Any("error", err))
+		return
+	}
+
+	parts := []any{limit, offset, total}
+	for _, a := range astronauts {
+		parts = append(parts, a.ID, a.Version)
+	}
+
+	mediaType := util.Negotiate(w, r, astronautMediaTypes...)
+	if mediaType == "" {
+		util.WriteError(w, r, util.ErrNotAcceptable)
+		return
+	}
+
+	if util.NotModified(w, r, util.ListETag(mediaType, parts...), lastModified) {
+		return
+	}
+
+	if mediaType == util.JSONLDContentType {
+		util.WriteJSONLD(w, http.StatusOK, toLDItemList(h.links, util.BaseURL(r), astronauts))
+		return
+	}
+
+	res := model.JSONResponse{Astronauts: h.links.astronauts(astronauts)}
+
+	pages := h.links.pages(r, routeAstronauts, total, limit, offset)
+	linkHeader(w, pages)
+	// csv holds a single table, its page links are only in the header
+	if mediaType != util.CSVContentType {
+		res.Links = pages
+	}
+
+	util.Write(w, r, mediaType, http.StatusOK, res)
 }
 
 func (h *astronautHandler) GetAstronaut(w http.ResponseWriter, r *http.Request) {
@@ -95,18 +155,36 @@ func (h *astronautHandler) GetAstronaut(w http.ResponseWriter, r *http.Request)
 	astronautID := mux.Vars(r)["astronautID"]
 	id, err := strconv.Atoi(astronautID)
 	if err != nil {
-		util.WriteJSON(w, http.StatusBadRequest, model.JSONResponse{Error: "Invalid Astronaut ID"})
+		util.WriteError(w, r, errInvalidID)
 		return
 	}
 
 	a, err := h.service.Get(ctx, id)
+	if h.links.redirectMoved(w, r, routeAstronaut, err) {
+		return
+	}
 	if err != nil {
-		util.WriteJSON(w, http.StatusBadRequest, model.JSONResponse{Error: "Bad Request"})
+		util.WriteError(w, r, err)
 		h.log.Warn("error fetching a astronaut", slog.Any("error", err))
 		return
 	}
 
-	util.WriteJSON(w, http.StatusOK, model.JSONResponse{Astronaut: a})
+	mediaType := util.Negotiate(w, r, astronautMediaTypes...)
+	if mediaType == "" {
+		util.WriteError(w, r, util.ErrNotAcceptable)
+		return
+	}
+
+	if util.NotModified(w, r, util.ETag(a.Version, mediaType), a.UpdatedAt) {
+		return
+	}
+
+	if mediaType == util.JSONLDContentType {
+		util.WriteJSONLD(w, http.StatusOK, toLDPerson(h.links, util.BaseURL(r), a, true))
+		return
+	}
+
+	util.Write(w, r, mediaType, http.StatusOK, model.JSONResponse{Astronaut: h.links.astronaut(a)})
 }
 
 func (h *astronautHandler) UpdateAstronaut(w http.ResponseWriter, r *http.Request) {
@@ -116,26 +194,83 @@ func (h *astronautHandler) UpdateAstronaut(w http.ResponseWriter, r *http.Reques
 	astronautID := mux.Vars(r)["astronautID"]
 	id, err := strconv.Atoi(astronautID)
 	if err != nil {
-		util.WriteJSON(w, http.StatusBadRequest, model.JSONResponse{Error: "Invalid Astronaut ID"})
+		util.WriteError(w, r, errInvalidID)
+		return
+	}
+
+	version, err := util.IfMatchVersion(r)
+	if err != nil {
+		util.WriteError(w, r, err)
 		return
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(a); err != nil {
-		util.WriteJSON(w, http.StatusBadRequest, model.JSONResponse{Error: "Invalid request body"})
+		util.WriteError(w, r, errInvalidBody)
 		h.log.Warn("error decoding request body to astronaut", slog.Any("error", err))
 		return
 	}
 
 	a.ID = id
+	a.Version = version
 
 	a, err = h.service.Update(ctx, a)
 	if err != nil {
-		util.WriteJSON(w, http.StatusBadRequest, model.JSONResponse{Error: "Bad Request"})
+		util.WriteError(w, r, err)
 		h.log.Warn("error updating a astronaut", slog.Any("error", err))
 		return
 	}
 
-	util.WriteJSON(w, http.StatusOK, model.JSONResponse{Astronaut: a})
+	util.SetETag(w, a.Version)
+	util.WriteJSON(w, http.StatusOK, model.JSONResponse{Astronaut: h.links.astronaut(a)})
+}
+
+// PatchAstronaut accepts RFC 7396 merge patches and RFC 6902 json patches,
+// chosen by the request content type
+func (h *astronautHandler) PatchAstronaut(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	astronautID := mux.Vars(r)["astronautID"]
+	id, err := strconv.Atoi(astronautID)
+	if err != nil {
+		util.WriteError(w, r, errInvalidID)
+		return
+	}
+
+	version, err := util.IfMatchVersion(r)
+	if err != nil {
+		util.WriteError(w, r, err)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		util.WriteError(w, r, errInvalidBody)
+		h.log.Warn("error reading patch request body", slog.Any("error", err))
+		return
+	}
+
+	var apply model.PatchFunc
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case patch.MergePatchContentType, util.JSONContentType:
+		apply = func(doc []byte) ([]byte, error) { return patch.Merge(doc, body) }
+	case patch.JSONPatchContentType:
+		apply = func(doc []byte) ([]byte, error) { return patch.JSONPatch(doc, body) }
+	default:
+		util.WriteError(w, r, errUnsupportedPatch)
+		return
+	}
+
+	a, err := h.service.Patch(ctx, id, version, apply)
+	if err != nil {
+		util.WriteError(w, r, err)
+		h.log.Warn("error patching a astronaut", slog.Any("error", err))
+		return
+	}
+
+	util.SetETag(w, a.Version)
+	util.WriteJSON(w, http.StatusOK, model.JSONResponse{Astronaut: h.links.astronaut(a)})
 }
 
 func (h *astronautHandler) DeleteAstronaut(w http.ResponseWriter, r *http.Request) {
@@ -144,15 +279,110 @@ func (h *astronautHandler) DeleteAstronaut(w http.ResponseWriter, r *http.Reques
 	astronautID := mux.Vars(r)["astronautID"]
 	id, err := strconv.Atoi(astronautID)
 	if err != nil {
-		util.WriteJSON(w, http.StatusBadRequest, model.JSONResponse{Error: "Invalid Astronaut ID"})
+		util.WriteError(w, r, errInvalidID)
+		return
+	}
+
+	version, err := util.IfMatchVersion(r)
+	if err != nil {
+		util.WriteError(w, r, err)
 		return
 	}
 
-	if err := h.service.Delete(ctx, id); err != nil {
-		util.WriteJSON(w, http.StatusBadRequest, model.JSONResponse{Error: "Bad Request"})
+	err = h.service.Delete(ctx, id, version)
+	if err != nil {
+		util.WriteError(w, r, err)
 		h.log.Warn("error deleting an astronaut", slog.Any("error", err))
 		return
 	}
 
 	util.WriteJSON(w, http.StatusOK, model.JSONResponse{Message: "Astronaut Deleted"})
 }
+
+func (h *astronautHandler) AstronautHistory(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	astronautID := mux.Vars(r)["astronautID"]
+	id, err := strconv.Atoi(astronautID)
+	if err != nil {
+		util.WriteError(w, r, errInvalidID)
+		return
+	}
+
+	history, err := h.service.History(ctx, id)
+	if h.links.redirectMoved(w, r, routeAstronautHistory, err) {
+		return
+	}
+	if err != nil {
+		util.WriteError(w, r, err)
+		h.log.Warn("error fetching astronaut history", slog.Any("error", err))
+		return
+	}
+
+	links := model.Links{
+		"self":      h.links.link(routeAstronautHistory, "astronautID", astronautID),
+		"astronaut": h.links.link(routeAstronaut, "astronautID", astronautID),
+	}
+	util.WriteJSON(w, http.StatusOK, model.JSONResponse{History: history, Links: links})
+}
+
+// DataCatalog describes the astronaut dataset as a schema.org DataCatalog
+func (h *astronautHandler) DataCatalog(w http.ResponseWriter, r *http.Request) {
+	util.WriteJSONLD(w, http.StatusOK, newLDDataCatalog(h.links, util.BaseURL(r)))
+}
+
+func (h *astronautHandler) AstronautMissions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	astronautID := mux.Vars(r)["astronautID"]
+	id, err := strconv.Atoi(astronautID)
+	if err != nil {
+		util.WriteError(w, r, errInvalidID)
+		return
+	}
+
+	a, err := h.service.Get(ctx, id)
+	if h.links.redirectMoved(w, r, routeAstronautMissions, err) {
+		return
+	}
+	if err != nil {
+		util.WriteError(w, r, err)
+		h.log.Warn("error fetching astronaut missions", slog.Any("error", err))
+		return
+	}
+
+	missions := a.Missions
+	if missions == nil {
+		missions = []string{}
+	}
+
+	links := h.links.astronaut(a).Links
+	util.WriteJSON(w, http.StatusOK, model.JSONResponse{Missions: missions, Links: model.Links{"self": links["missions"], "astronaut": links["self"]}})
+}
+
+func (h *astronautHandler) AstronautCrewmates(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	astronautID := mux.Vars(r)["astronautID"]
+	id, err := strconv.Atoi(astronautID)
+	if err != nil {
+		util.WriteError(w, r, errInvalidID)
+		return
+	}
+
+	crewmates, err := h.service.Crewmates(ctx, id)
+	if h.links.redirectMoved(w, r, routeAstronautCrewmates, err) {
+		return
+	}
+	if err != nil {
+		util.WriteError(w, r, err)
+		h.log.Warn("error fetching astronaut crewmates", slog.Any("error", err))
+		return
+	}
+
+	links := model.Links{
+		"self":      h.links.link(routeAstronautCrewmates, "astronautID", astronautID),
+		"astronaut": h.links.link(routeAstronaut, "astronautID", astronautID),
+	}
+	util.WriteJSON(w, http.StatusOK, model.JSONResponse{Astronauts: h.links.astronauts(crewmates), Links: links})
+}
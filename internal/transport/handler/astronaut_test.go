@@ -0,0 +1,213 @@
+package handler
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+	"github.com/LaQuannT/astronaut-data-api/internal/transport/middleware"
+	"github.com/gorilla/mux"
+)
+
+var testUpdatedAt = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// fakeAstronauts serves astronauts from memory, methods the tests do not
+// need panic through the nil embedded usecase
+type fakeAstronauts struct {
+	model.AstronautUsecase
+
+	astronauts map[int]*model.Astronaut
+	// moved maps merged ids to their survivor
+	moved map[int]int
+}
+
+func newFakeAstronauts() *fakeAstronauts {
+	return &fakeAstronauts{
+		astronauts: map[int]*model.Astronaut{
+			1: {ID: 1, Name: "john glenn", Missions: []string{"mercury 6", "sts-95"}, Version: 3, UpdatedAt: testUpdatedAt},
+		},
+		moved: map[int]int{2: 1},
+	}
+}
+
+func (f *fakeAstronauts) Get(ctx context.Context, id int) (*model.Astronaut, error) {
+	if to, ok := f.moved[id]; ok {
+		return nil, &model.MovedError{ID: to}
+	}
+	a, ok := f.astronauts[id]
+	if !ok {
+		return nil, model.NewError(model.KindNotFound, "not_found", "astronaut not found")
+	}
+	c := *a
+	return &c, nil
+}
+
+func (f *fakeAstronauts) History(ctx context.Context, id int) ([]*model.HistoryEntry, error) {
+	if to, ok := f.moved[id]; ok {
+		return nil, &model.MovedError{ID: to}
+	}
+	return []*model.HistoryEntry{}, nil
+}
+
+func (f *fakeAstronauts) Crewmates(ctx context.Context, id int) ([]*model.Astronaut, error) {
+	if _, err := f.Get(ctx, id); err != nil {
+		return nil, err
+	}
+	return []*model.Astronaut{}, nil
+}
+
+func (f *fakeAstronauts) List(ctx context.Context, limit, offset int) ([]*model.Astronaut, error) {
+	a, _ := f.Get(ctx, 1)
+	return []*model.Astronaut{a}, nil
+}
+
+func (f *fakeAstronauts) Count(ctx context.Context) (int, error) {
+	return len(f.astronauts), nil
+}
+
+func (f *fakeAstronauts) LastModified(ctx context.Context) (time.Time, error) {
+	return testUpdatedAt, nil
+}
+
+func (f *fakeAstronauts) Update(ctx context.Context, a *model.Astronaut) (*model.Astronaut, error) {
+	old, ok := f.astronauts[a.ID]
+	if !ok {
+		return nil, model.NewError(model.KindNotFound, "not_found", "astronaut not found")
+	}
+	if a.Version != 0 && a.Version != old.Version {
+		return nil, model.ErrVersionConflict
+	}
+	a.Version = old.Version + 1
+	f.astronauts[a.ID] = a
+	return a, nil
+}
+
+// testAstronautRouter serves the v1 and v2 astronaut routes of s to an editor
+func testAstronautRouter(s model.AstronautUsecase) http.Handler {
+	r := mux.NewRouter()
+	l := slog.New(slog.NewTextHandler(io.Discard, nil))
+	RegisterAstronautHandlers(s, nil, nil, nil, nil, r.PathPrefix(APIPrefix).Subrouter(), l)
+	RegisterV2AstronautHandlers(s, nil, nil, nil, nil, r.PathPrefix(APIv2Prefix).Subrouter(), l)
+
+	editor := &model.User{ID: 1, Role: "editor", Permissions: model.Permissions}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), middleware.RequestUser, editor)))
+	})
+}
+
+func TestAstronautConditionalRequests(t *testing.T) {
+	tests := []struct {
+		name    string
+		method  string
+		target  string
+		headers map[string]string
+		status  int
+		etag    string
+	}{
+		{"json tag", http.MethodGet, "/api/v1/astronauts/1", nil, http.StatusOK, `"3"`},
+		{"fresh json copy", http.MethodGet, "/api/v1/astronauts/1", map[string]string{"If-None-Match": `"3"`}, http.StatusNotModified, `"3"`},
+		{"weak tag of a fresh copy", http.MethodGet, "/api/v1/astronauts/1", map[string]string{"If-None-Match": `W/"3"`}, http.StatusNotModified, `"3"`},
+		{"stale json copy", http.MethodGet, "/api/v1/astronauts/1", map[string]string{"If-None-Match": `"2"`}, http.StatusOK, `"3"`},
+		{"json copy does not match csv", http.MethodGet, "/api/v1/astronauts/1", map[string]string{"Accept": "text/csv", "If-None-Match": `"3"`}, http.StatusOK, `"3-csv"`},
+		{"fresh csv copy", http.MethodGet, "/api/v1/astronauts/1?format=csv", map[string]string{"If-None-Match": `"3-csv"`}, http.StatusNotModified, `"3-csv"`},
+		{"json-ld tag", http.MethodGet, "/api/v1/astronauts/1", map[string]string{"Accept": "application/ld+json"}, http.StatusOK, `"3-jsonld"`},
+		{"stale if-match", http.MethodPut, "/api/v1/astronauts/1", map[string]string{"If-Match": `"2"`}, http.StatusPreconditionFailed, ""},
+		{"weak if-match", http.MethodPut, "/api/v1/astronauts/1", map[string]string{"If-Match": `W/"3"`}, http.StatusPreconditionFailed, ""},
+		{"missing if-match", http.MethodPut, "/api/v1/astronauts/1", nil, http.StatusPreconditionRequired, ""},
+		{"malformed if-match", http.MethodPut, "/api/v1/astronauts/1", map[string]string{"If-Match": "abc"}, http.StatusBadRequest, ""},
+		{"if-match of two versions", http.MethodPut, "/api/v1/astronauts/1", map[string]string{"If-Match": `"3", "4"`}, http.StatusBadRequest, ""},
+		{"if-match list", http.MethodPut, "/api/v1/astronauts/1", map[string]string{"If-Match": `"3-csv", "3"`}, http.StatusOK, `"4"`},
+		{"current if-match", http.MethodPut, "/api/v1/astronauts/1", map[string]string{"If-Match": `"3"`}, http.StatusOK, `"4"`},
+		{"if-match of another representation", http.MethodPut, "/api/v1/astronauts/1", map[string]string{"If-Match": `"3-csv"`}, http.StatusOK, `"4"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := testAstronautRouter(newFakeAstronauts())
+
+			var body io.Reader
+			if tt.method == http.MethodPut {
+				body = strings.NewReader(`{"name": "john glenn"}`)
+			}
+			req := httptest.NewRequest(tt.method, tt.target, body)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if got := w.Header().Get("ETag"); got != tt.etag {
+				t.Fatalf("ETag = %s, want %s", got, tt.etag)
+			}
+		})
+	}
+}
+
+func TestAstronautListETag(t *testing.T) {
+	r := testAstronautRouter(newFakeAstronauts())
+
+	get := func(accept, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/astronauts", nil)
+		req.Header.Set("Accept", accept)
+		req.Header.Set("If-None-Match", ifNoneMatch)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	jsonTag := get("application/json", "").Header().Get("ETag")
+	csvTag := get("text/csv", "").Header().Get("ETag")
+	if jsonTag == "" || jsonTag == csvTag {
+		t.Fatalf("expected json and csv lists to have different tags, got %s and %s", jsonTag, csvTag)
+	}
+
+	if w := get("application/json", jsonTag); w.Code != http.StatusNotModified {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusNotModified)
+	}
+	if w := get("text/csv", jsonTag); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+}
+
+func TestMergedAstronautRedirects(t *testing.T) {
+	tests := []struct {
+		target   string
+		location string
+	}{
+		{"/api/v1/astronauts/2", "/api/v1/astronauts/1"},
+		{"/api/v1/astronauts/2?format=csv", "/api/v1/astronauts/1?format=csv"},
+		{"/api/v1/astronauts/2/missions", "/api/v1/astronauts/1/missions"},
+		{"/api/v1/astronauts/2/crewmates", "/api/v1/astronauts/1/crewmates"},
+		{"/api/v1/astronauts/2/history", "/api/v1/astronauts/1/history"},
+		{"/api/v2/astronauts/2", "/api/v2/astronauts/1"},
+		{"/api/v2/astronauts/2/missions", "/api/v2/astronauts/1/missions"},
+		{"/api/v2/astronauts/2/crewmates", "/api/v2/astronauts/1/crewmates"},
+		{"/api/v2/astronauts/2/history", "/api/v2/astronauts/1/history"},
+	}
+
+	r := testAstronautRouter(newFakeAstronauts())
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+			if w.Code != http.StatusPermanentRedirect {
+				t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusPermanentRedirect, w.Body)
+			}
+			if got := w.Header().Get("Location"); got != tt.location {
+				t.Fatalf("Location = %s, want %s", got, tt.location)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+	"github.com/LaQuannT/astronaut-data-api/internal/transport/middleware"
+	"github.com/LaQuannT/astronaut-data-api/internal/transport/util"
+	"github.com/gorilla/mux"
+)
+
+type (
+	authHandler struct {
+		service model.AuthUsecase
+		log     *slog.Logger
+	}
+
+	refreshRequest struct {
+		RefreshToken string `json:"refreshToken"`
+	}
+)
+
+// RegisterAuthHandlers serves the login, refresh and logout endpoints, they
+// are public as the request body carries the credentials
+func RegisterAuthHandlers(s model.AuthUsecase, r *mux.Router, l *slog.Logger) {
+	handler := &authHandler{
+		service: s,
+		log:     l,
+	}
+
+	sr := r.PathPrefix("/auth").Subrouter()
+	sr.Use(middleware.Validation)
+
+	sr.HandleFunc("/login", handler.Login).Methods("POST")
+	sr.HandleFunc("/refresh", handler.Refresh).Methods("POST")
+	sr.HandleFunc("/logout", handler.Logout).Methods("POST")
+}
+
+func (h *authHandler) Login(w http.ResponseWriter, r *http.Request) {
+	c := new(model.Credentials)
+
+	if err := json.NewDecoder(r.Body).Decode(c); err != nil {
+		util.WriteError(w, r, errInvalidBody)
+		h.log.Warn("error decoding json request body", slog.Any("error", err))
+		return
+	}
+
+	tokens, err := h.service.Login(r.Context(), c)
+	if err != nil {
+		util.WriteError(w, r, err)
+		h.log.Warn("error logging in", slog.Any("error", err))
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	util.WriteJSON(w, http.StatusOK, tokens)
+}
+
+func (h *authHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	in := new(refreshRequest)
+
+	if err := json.NewDecoder(r.Body).Decode(in); err != nil {
+		util.WriteError(w, r, errInvalidBody)
+		h.log.Warn("error decoding json request body", slog.Any("error", err))
+		return
+	}
+
+	tokens, err := h.service.Refresh(r.Context(), in.RefreshToken)
+	if err != nil {
+		util.WriteError(w, r, err)
+		h.log.Warn("error refreshing tokens", slog.Any("error", err))
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	util.WriteJSON(w, http.StatusOK, tokens)
+}
+
+func (h *authHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	in := new(refreshRequest)
+
+	if err := json.NewDecoder(r.Body).Decode(in); err != nil {
+		util.WriteError(w, r, errInvalidBody)
+		h.log.Warn("error decoding json request body", slog.Any("error", err))
+		return
+	}
+
+	if err := h.service.Logout(r.Context(), in.RefreshToken); err != nil {
+		util.WriteError(w, r, err)
+		h.log.Warn("error logging out", slog.Any("error", err))
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, model.JSONResponse{Message: "Logged out"})
+}
@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+	"github.com/LaQuannT/astronaut-data-api/internal/transport/util"
+)
+
+// maxBatchSize caps batch request bodies at 1MB
+const maxBatchSize = 1 << 20
+
+type (
+	batchRequest struct {
+		Mode       string                  `json:"mode,omitempty"`
+		Operations []*model.BatchOperation `json:"operations"`
+	}
+
+	// batchResult carries the status the operation would have answered with
+	// on its own
+	batchResult struct {
+		Index     int              `json:"index"`
+		Op        string           `json:"op"`
+		Status    int              `json:"status"`
+		ID        int              `json:"id,omitempty"`
+		Astronaut *model.Astronaut `json:"astronaut,omitempty"`
+		Error     *batchError      `json:"error,omitempty"`
+	}
+
+	batchError struct {
+		Code   string              `json:"code"`
+		Detail string              `json:"detail,omitempty"`
+		Errors []*model.FieldError `json:"errors,omitempty"`
+	}
+
+	batchResponse struct {
+		Mode      string         `json:"mode"`
+		Committed bool           `json:"committed"`
+		Results   []*batchResult `json:"results"`
+	}
+)
+
+// BatchAstronauts answers 200 when every operation was applied, 409 when an
+// atomic batch was rolled back and 207 when a best-effort batch applied only
+// some operations
+func (h *astronautHandler) BatchAstronauts(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	req := new(batchRequest)
+
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchSize)).Decode(req); err != nil {
+		util.WriteError(w, r, errInvalidBody)
+		h.log.Warn("error decoding batch request body", slog.Any("error", err))
+		return
+	}
+
+	results, err := h.service.Batch(ctx, req.Mode, req.Operations)
+	if err != nil {
+		util.WriteError(w, r, err)
+		h.log.Warn("error running astronaut batch", slog.Any("error", err))
+		return
+	}
+
+	res := batchResponse{Mode: req.Mode, Committed: true, Results: make([]*batchResult, 0, len(results))}
+	if res.Mode == "" {
+		res.Mode = model.BatchAtomic
+	}
+
+	failed := 0
+	for _, br := range results {
+		out := &batchResult{Index: br.Index, Op: br.Op, Status: batchStatus(br.Op), ID: br.ID, Astronaut: br.Astronaut}
+		if br.Astronaut != nil {
+			h.links.astronaut(br.Astronaut)
+		}
+		if br.Err != nil {
+			pe := util.Public(br.Err)
+			out.Status = pe.Status
+			out.Error = &batchError{Code: pe.Code, Detail: pe.Detail, Errors: pe.Fields}
+			failed++
+		}
+		res.Results = append(res.Results, out)
+	}
+
+	status := http.StatusOK
+	switch {
+	case failed > 0 && res.Mode == model.BatchAtomic:
+		status = http.StatusConflict
+		res.Committed = false
+	case failed > 0:
+		status = http.StatusMultiStatus
+	}
+
+	util.WriteJSON(w, status, res)
+}
+
+func batchStatus(op string) int {
+	if op == model.BatchCreate {
+		return http.StatusCreated
+	}
+	return http.StatusOK
+}
@@ -0,0 +1,11 @@
+package handler
+
+import "github.com/LaQuannT/astronaut-data-api/internal/model"
+
+var (
+	errInvalidID        = model.NewError(model.KindInvalid, "invalid_id", "resource id must be an integer")
+	errInvalidBody      = model.NewError(model.KindInvalid, "invalid_body", "request body is not valid json")
+	errInvalidQuery     = model.NewError(model.KindInvalid, "invalid_query", "request query could not be parsed")
+	errInvalidDate      = model.NewError(model.KindInvalid, "invalid_date", "from and to must be dates such as 2006-01-02")
+	errUnsupportedPatch = model.NewError(model.KindUnsupported, "unsupported_patch_format", "patch content type must be merge-patch+json or json-patch+json")
+)
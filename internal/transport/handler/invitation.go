@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+	"github.com/LaQuannT/astronaut-data-api/internal/transport/middleware"
+	"github.com/LaQuannT/astronaut-data-api/internal/transport/util"
+	"github.com/gorilla/mux"
+)
+
+type (
+	invitationHandler struct {
+		service model.InvitationUsecase
+		links   linker
+		log     *slog.Logger
+	}
+
+	// acceptInvitationRequest names the invited admin, their email is the
+	// one the invitation was mailed to
+	acceptInvitationRequest struct {
+		Token     string `json:"token"`
+		FirstName string `json:"firstName"`
+		Surename  string `json:"surename"`
+		Password  string `json:"password"`
+	}
+)
+
+// RegisterInvitationHandlers lets admins invite other admins, accepting an
+// invitation is public and limited by client IP
+func RegisterInvitationHandlers(s model.InvitationUsecase, us model.UserUsecase, rl model.RateLimitUsecase, ms model.UsageUsecase, r *mux.Router, l *slog.Logger) {
+	handler := &invitationHandler{
+		service: s,
+		links:   newLinker(r, "v1"),
+		log:     l,
+	}
+
+	sr := r.PathPrefix("/admin").Subrouter()
+	sr.Use(middleware.APIKeyValidation(us, l), middleware.RateLimit(rl, l), middleware.Metering(ms, l), middleware.Validation)
+
+	sr.HandleFunc("/invitations", handler.Invite).Methods("POST")
+	r.Handle("/auth/invitations/accept", middleware.RateLimit(rl, l)(middleware.Validation(http.HandlerFunc(handler.Accept)))).Methods("POST")
+}
+
+func (h *invitationHandler) Invite(w http.ResponseWriter, r *http.Request) {
+	in := new(emailRequest)
+
+	if err := json.NewDecoder(r.Body).Decode(in); err != nil {
+		util.WriteError(w, r, errInvalidBody)
+		h.log.Warn("error decoding json request body", slog.Any("error", err))
+		return
+	}
+
+	inv, err := h.service.Invite(r.Context(), in.Email)
+	if err != nil {
+		util.WriteError(w, r, err)
+		h.log.Warn("error inviting admin", slog.Any("error", err))
+		return
+	}
+
+	util.WriteJSON(w, http.StatusCreated, model.JSONResponse{Invitation: inv})
+}
+
+func (h *invitationHandler) Accept(w http.ResponseWriter, r *http.Request) {
+	in := new(acceptInvitationRequest)
+
+	if err := json.NewDecoder(r.Body).Decode(in); err != nil {
+		util.WriteError(w, r, errInvalidBody)
+		h.log.Warn("error decoding json request body", slog.Any("error", err))
+		return
+	}
+
+	u, err := h.service.Accept(r.Context(), in.Token, &model.User{FirstName: in.FirstName, Surename: in.Surename, Password: in.Password})
+	if err != nil {
+		util.WriteError(w, r, err)
+		h.log.Warn("error accepting invitation", slog.Any("error", err))
+		return
+	}
+
+	// the password holds the new admin's hash by now
+	u.Password = ""
+
+	util.SetETag(w, u.Version)
+	util.WriteJSON(w, http.StatusCreated, model.JSONResponse{User: h.links.user(u)})
+}
@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+	"github.com/gorilla/mux"
+)
+
+// fakeInvitations accepts every token the way the usecase does, leaving
+// the password hash in the user
+type fakeInvitations struct {
+	model.InvitationUsecase
+}
+
+func (fakeInvitations) Accept(ctx context.Context, token string, u *model.User) (*model.User, error) {
+	u.ID = 2
+	u.Email = "ada@example.com"
+	u.Role = model.AdminUser
+	u.Password = "$2a$12$hash"
+	u.Version = 1
+	return u, nil
+}
+
+func TestAcceptInvitationHidesPassword(t *testing.T) {
+	r := mux.NewRouter()
+	sr := r.PathPrefix(APIPrefix).Subrouter()
+	l := slog.New(slog.NewTextHandler(io.Discard, nil))
+	RegisterUserHandlers(nil, nil, nil, nil, sr, l)
+	RegisterInvitationHandlers(fakeInvitations{}, nil, nil, nil, sr, l)
+
+	body := `{"token": "abc", "firstName": "Ada", "surename": "Lovelace", "password": "Passw0rd!"}`
+	req := httptest.NewRequest(http.MethodPost, APIPrefix+"/auth/invitations/accept", strings.NewReader(body))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusCreated, w.Body)
+	}
+	var res struct {
+		User map[string]any `json:"user"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	if p, ok := res.User["password"]; ok {
+		t.Errorf("response carries the password %q", p)
+	}
+}
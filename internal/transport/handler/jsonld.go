@@ -0,0 +1,199 @@
+package handler
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+)
+
+const schemaContext = "https://schema.org"
+
+type (
+	ldThing struct {
+		Type string `json:"@type"`
+		Name string `json:"name"`
+	}
+
+	// ldEvent is a mission node, its @id points into the astronaut's
+	// missions resource
+	ldEvent struct {
+		ID   string `json:"@id"`
+		Type string `json:"@type"`
+		Name string `json:"name"`
+		URL  string `json:"url"`
+	}
+
+	ldPerson struct {
+		Context     string    `json:"@context,omitempty"`
+		ID          string    `json:"@id"`
+		Type        string    `json:"@type"`
+		Identifier  int       `json:"identifier"`
+		URL         string    `json:"url"`
+		Name        string    `json:"name"`
+		JobTitle    string    `json:"jobTitle"`
+		Gender      string    `json:"gender,omitempty"`
+		BirthDate   string    `json:"birthDate,omitempty"`
+		DeathDate   string    `json:"deathDate,omitempty"`
+		BirthPlace  *ldThing  `json:"birthPlace,omitempty"`
+		AlumniOf    []ldThing `json:"alumniOf,omitempty"`
+		MemberOf    []ldThing `json:"memberOf,omitempty"`
+		PerformerIn []ldEvent `json:"performerIn,omitempty"`
+	}
+
+	ldListItem struct {
+		Type     string    `json:"@type"`
+		Position int       `json:"position"`
+		Item     *ldPerson `json:"item"`
+	}
+
+	ldItemList struct {
+		Context         string       `json:"@context"`
+		Type            string       `json:"@type"`
+		NumberOfItems   int          `json:"numberOfItems"`
+		ItemListElement []ldListItem `json:"itemListElement"`
+	}
+
+	ldDataDownload struct {
+		Type           string `json:"@type"`
+		EncodingFormat string `json:"encodingFormat"`
+		ContentURL     string `json:"contentUrl"`
+	}
+
+	ldDataset struct {
+		Type         string           `json:"@type"`
+		Name         string           `json:"name"`
+		Description  string           `json:"description"`
+		URL          string           `json:"url"`
+		Distribution []ldDataDownload `json:"distribution"`
+	}
+
+	ldDataCatalog struct {
+		Context     string      `json:"@context"`
+		Type        string      `json:"@type"`
+		Name        string      `json:"name"`
+		Description string      `json:"description"`
+		URL         string      `json:"url"`
+		Dataset     []ldDataset `json:"dataset"`
+	}
+)
+
+// toLDPerson maps an astronaut onto a schema.org Person, its @id and those
+// of its missions are the routes of links under baseURL, the scheme and host
+// of the request
+func toLDPerson(links linker, baseURL string, a *model.Astronaut, withContext bool) *ldPerson {
+	id := strconv.Itoa(a.ID)
+	self := baseURL + links.href(routeAstronaut, "astronautID", id)
+
+	p := &ldPerson{
+		ID:          self,
+		Type:        "Person",
+		Identifier:  a.ID,
+		URL:         self,
+		Name:        a.Name,
+		JobTitle:    "astronaut",
+		Gender:      a.Gender,
+		BirthDate:   isoDate(a.BirthDate),
+		DeathDate:   isoDate(a.DeathDate),
+		AlumniOf:    things("CollegeOrUniversity", a.AlmaMater),
+		PerformerIn: events(baseURL+links.href(routeAstronautMissions, "astronautID", id), a.Missions),
+	}
+
+	if withContext {
+		p.Context = schemaContext
+	}
+
+	if a.BirthPlace != "" {
+		p.BirthPlace = &ldThing{Type: "Place", Name: a.BirthPlace}
+	}
+
+	if a.Group != 0 {
+		p.MemberOf = append(p.MemberOf, ldThing{Type: "Organization", Name: fmt.Sprintf("nasa astronaut group %d", a.Group)})
+	}
+	if a.MilitaryBranch != "" {
+		p.MemberOf = append(p.MemberOf, ldThing{Type: "Organization", Name: a.MilitaryBranch})
+	}
+
+	return p
+}
+
+func toLDItemList(links linker, baseURL string, astronauts []*model.Astronaut) *ldItemList {
+	list := &ldItemList{
+		Context:         schemaContext,
+		Type:            "ItemList",
+		NumberOfItems:   len(astronauts),
+		ItemListElement: make([]ldListItem, 0, len(astronauts)),
+	}
+
+	for i, a := range astronauts {
+		list.ItemListElement = append(list.ItemListElement, ldListItem{Type: "ListItem", Position: i + 1, Item: toLDPerson(links, baseURL, a, false)})
+	}
+
+	return list
+}
+
+func newLDDataCatalog(links linker, baseURL string) *ldDataCatalog {
+	api := baseURL + APIPrefix
+	astronauts := baseURL + links.href(routeAstronauts)
+
+	return &ldDataCatalog{
+		Context:     schemaContext,
+		Type:        "DataCatalog",
+		Name:        "astronaut data api",
+		Description: "Biographical and flight records of NASA astronauts.",
+		URL:         api,
+		Dataset: []ldDataset{
+			{
+				Type:        "Dataset",
+				Name:        "nasa astronauts",
+				Description: "NASA astronauts with their selection group, education, military service, space flights and missions.",
+				URL:         astronauts,
+				Distribution: []ldDataDownload{
+					{Type: "DataDownload", EncodingFormat: "application/ld+json", ContentURL: astronauts + "?format=jsonld"},
+					{Type: "DataDownload", EncodingFormat: "application/json", ContentURL: astronauts + "?format=json"},
+				},
+			},
+		},
+	}
+}
+
+// things skips the empty and padded values the csv seed leaves in lists
+func things(typ string, names []string) []ldThing {
+	var ts []ldThing
+	for _, n := range names {
+		n = strings.TrimSpace(n)
+		if n == "" {
+			continue
+		}
+		ts = append(ts, ldThing{Type: typ, Name: n})
+	}
+	return ts
+}
+
+// events links each mission to a fragment of the missions resource, so the
+// nodes can be referenced without a resource of their own
+func events(missionsURL string, missions []string) []ldEvent {
+	var es []ldEvent
+	for _, t := range things("Event", missions) {
+		es = append(es, ldEvent{
+			ID:   missionsURL + "#" + url.PathEscape(t.Name),
+			Type: t.Type,
+			Name: t.Name,
+			URL:  missionsURL,
+		})
+	}
+	return es
+}
+
+// isoDate converts the dataset's M/D/YYYY dates into ISO 8601, anything else
+// is returned untouched
+func isoDate(date string) string {
+	t, err := time.Parse("1/2/2006", date)
+	if err != nil {
+		return date
+	}
+	return t.Format(time.DateOnly)
+}
@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestJSONLDPerson(t *testing.T) {
+	r := testAstronautRouter(newFakeAstronauts())
+
+	for _, target := range []string{"/api/v1/astronauts/1?format=jsonld", "/api/v1/astronauts/1"} {
+		t.Run(target, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, target, nil)
+			req.Header.Set("Accept", "application/ld+json")
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+			}
+			if ct := w.Header().Get("Content-Type"); ct != "application/ld+json" {
+				t.Fatalf("Content-Type = %s, want application/ld+json", ct)
+			}
+
+			var p ldPerson
+			if err := json.NewDecoder(w.Body).Decode(&p); err != nil {
+				t.Fatal(err)
+			}
+
+			if p.Context != schemaContext || p.Type != "Person" || p.ID != "http://example.com/api/v1/astronauts/1" {
+				t.Fatalf("expected Person http://example.com/api/v1/astronauts/1, got %+v", p)
+			}
+
+			missions := "http://example.com/api/v1/astronauts/1/missions"
+			want := []ldEvent{
+				{ID: missions + "#mercury%206", Type: "Event", Name: "mercury 6", URL: missions},
+				{ID: missions + "#sts-95", Type: "Event", Name: "sts-95", URL: missions},
+			}
+			if len(p.PerformerIn) != len(want) {
+				t.Fatalf("performerIn = %+v, want %+v", p.PerformerIn, want)
+			}
+			for i := range want {
+				if p.PerformerIn[i] != want[i] {
+					t.Errorf("performerIn[%d] = %+v, want %+v", i, p.PerformerIn[i], want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestJSONLDNegotiation(t *testing.T) {
+	r := testAstronautRouter(newFakeAstronauts())
+
+	tests := []struct {
+		accept      string
+		contentType string
+	}{
+		{"application/ld+json", "application/ld+json"},
+		{"application/ld+json;q=0.5, application/json", "application/json"},
+		{"application/json;q=0.5, application/ld+json", "application/ld+json"},
+		{"", "application/json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/astronauts", nil)
+			req.Header.Set("Accept", tt.accept)
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if ct := w.Header().Get("Content-Type"); ct != tt.contentType {
+				t.Fatalf("Content-Type = %s, want %s", ct, tt.contentType)
+			}
+		})
+	}
+}
+
+func TestJSONLDDataCatalog(t *testing.T) {
+	r := testAstronautRouter(nil)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/catalog", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+
+	var c ldDataCatalog
+	if err := json.NewDecoder(w.Body).Decode(&c); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Context != schemaContext || c.Type != "DataCatalog" || c.URL != "http://example.com/api/v1" {
+		t.Fatalf("expected DataCatalog http://example.com/api/v1, got %+v", c)
+	}
+	if len(c.Dataset) != 1 || c.Dataset[0].URL != "http://example.com/api/v1/astronauts" {
+		t.Fatalf("expected the astronauts dataset, got %+v", c.Dataset)
+	}
+	for _, d := range c.Dataset[0].Distribution {
+		if d.Type != "DataDownload" || d.ContentURL == "" {
+			t.Errorf("distribution %+v has no download", d)
+		}
+	}
+}
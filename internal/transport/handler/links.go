@@ -0,0 +1,151 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+	"github.com/gorilla/mux"
+)
+
+// route names, prefixed with the api version since every subrouter shares
+// the named routes of the root router
+const (
+	routeAstronauts         = "astronauts"
+	routeAstronaut          = "astronaut"
+	routeAstronautHistory   = "astronaut.history"
+	routeAstronautMissions  = "astronaut.missions"
+	routeAstronautCrewmates = "astronaut.crewmates"
+	routeUsers              = "users"
+	routeUser               = "user"
+	routeUserPassword       = "user.password"
+	routeUserAPIKey         = "user.apikey"
+	routeUserAPIKeys        = "user.apikeys"
+)
+
+// linker builds links from the named routes of one api version, so clients
+// never have to assemble urls themselves
+type linker struct {
+	router  *mux.Router
+	version string
+}
+
+func newLinker(r *mux.Router, version string) linker {
+	return linker{router: r, version: version}
+}
+
+// name is the versioned name a route is registered under
+func (l linker) name(route string) string {
+	return l.version + "." + route
+}
+
+// href returns the path of a named route, names are constants so a missing
+// route is a programming error
+func (l linker) href(route string, pairs ...string) string {
+	u, err := l.router.Get(l.name(route)).URL(pairs...)
+	if err != nil {
+		panic("handler: unable to build link to " + l.name(route) + ": " + err.Error())
+	}
+	return u.String()
+}
+
+func (l linker) link(route string, pairs ...string) *model.Link {
+	return &model.Link{Href: l.href(route, pairs...)}
+}
+
+func (l linker) astronaut(a *model.Astronaut) *model.Astronaut {
+	id := strconv.Itoa(a.ID)
+	a.Links = model.Links{
+		"self":      l.link(routeAstronaut, "astronautID", id),
+		"missions":  l.link(routeAstronautMissions, "astronautID", id),
+		"crewmates": l.link(routeAstronautCrewmates, "astronautID", id),
+		"history":   l.link(routeAstronautHistory, "astronautID", id),
+	}
+	return a
+}
+
+func (l linker) astronauts(astronauts []*model.Astronaut) []*model.Astronaut {
+	for _, a := range astronauts {
+		l.astronaut(a)
+	}
+	return astronauts
+}
+
+// user links to the account and the actions its owner can take on it
+func (l linker) user(u *model.User) *model.User {
+	id := strconv.Itoa(u.ID)
+	u.Links = model.Links{
+		"self":     l.link(routeUser, "userID", id),
+		"owner":    l.link(routeUser, "userID", id),
+		"password": l.link(routeUserPassword, "userID", id),
+		"apiKey":   l.link(routeUserAPIKey, "userID", id),
+		"apiKeys":  l.link(routeUserAPIKeys, "userID", id),
+	}
+	return u
+}
+
+func (l linker) users(users []*model.User) []*model.User {
+	for _, u := range users {
+		l.user(u)
+	}
+	return users
+}
+
+// pages links the neighbouring pages of a list of total items, keeping the
+// request's other query parameters
+func (l linker) pages(r *http.Request, route string, total, limit, offset int) model.Links {
+	page := func(offset int) *model.Link {
+		q := r.URL.Query()
+		q.Set("limit", strconv.Itoa(limit))
+		q.Set("offset", strconv.Itoa(offset))
+		return &model.Link{Href: l.href(route) + "?" + q.Encode()}
+	}
+
+	limit = max(limit, 1)
+
+	last := 0
+	if total > 0 {
+		last = (total - 1) / limit * limit
+	}
+
+	links := model.Links{
+		"self":  page(offset),
+		"first": page(0),
+		"last":  page(last),
+	}
+	if offset > 0 {
+		links["prev"] = page(max(min(offset-limit, last), 0))
+	}
+	if offset+limit < total {
+		links["next"] = page(offset + limit)
+	}
+	return links
+}
+
+// redirectMoved answers a request for an astronaut merged into another with
+// a permanent redirect to route of the survivor, keeping the query so the
+// client gets the same representation. It reports whether err was a move.
+func (l linker) redirectMoved(w http.ResponseWriter, r *http.Request, route string, err error) bool {
+	moved := new(model.MovedError)
+	if !errors.As(err, &moved) {
+		return false
+	}
+
+	target := l.href(route, "astronautID", strconv.Itoa(moved.ID))
+	if r.URL.RawQuery != "" {
+		target += "?" + r.URL.RawQuery
+	}
+	http.Redirect(w, r, target, http.StatusPermanentRedirect)
+	return true
+}
+
+// linkHeader repeats page links in an RFC 8288 Link header, for formats that
+// cannot carry them in the body
+func linkHeader(w http.ResponseWriter, links model.Links) {
+	for _, rel := range []string{"first", "prev", "next", "last"} {
+		if link, ok := links[rel]; ok {
+			w.Header().Add("Link", "<"+link.Href+">; rel=\""+rel+"\"")
+		}
+	}
+}
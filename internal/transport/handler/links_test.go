@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+	"github.com/gorilla/mux"
+)
+
+func TestLinks(t *testing.T) {
+	r := mux.NewRouter()
+	sr := r.PathPrefix(APIPrefix).Subrouter()
+	RegisterAstronautHandlers(nil, nil, nil, nil, nil, sr, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	links := newLinker(sr, "v1")
+
+	a := links.astronaut(&model.Astronaut{ID: 7})
+	for rel, want := range map[string]string{
+		"self":      "/api/v1/astronauts/7",
+		"missions":  "/api/v1/astronauts/7/missions",
+		"crewmates": "/api/v1/astronauts/7/crewmates",
+		"history":   "/api/v1/astronauts/7/history",
+	} {
+		if got := a.Links[rel].Href; got != want {
+			t.Errorf("%s = %q, want %q", rel, got, want)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/astronauts?format=yaml&limit=10&offset=20", nil)
+	pages := links.pages(req, routeAstronauts, 45, 10, 20)
+	for rel, want := range map[string]string{
+		"self":  "/api/v1/astronauts?format=yaml&limit=10&offset=20",
+		"first": "/api/v1/astronauts?format=yaml&limit=10&offset=0",
+		"prev":  "/api/v1/astronauts?format=yaml&limit=10&offset=10",
+		"next":  "/api/v1/astronauts?format=yaml&limit=10&offset=30",
+		"last":  "/api/v1/astronauts?format=yaml&limit=10&offset=40",
+	} {
+		if pages[rel] == nil || pages[rel].Href != want {
+			t.Errorf("%s = %v, want %q", rel, pages[rel], want)
+		}
+	}
+
+	if last := links.pages(req, routeAstronauts, 45, 10, 40); last["next"] != nil {
+		t.Errorf("last page has next link %q", last["next"].Href)
+	}
+}
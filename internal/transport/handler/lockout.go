@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+	"github.com/LaQuannT/astronaut-data-api/internal/transport/middleware"
+	"github.com/LaQuannT/astronaut-data-api/internal/transport/util"
+	"github.com/gorilla/mux"
+)
+
+type lockoutHandler struct {
+	service model.LockoutUsecase
+	log     *slog.Logger
+}
+
+// RegisterLockoutHandlers lets admins unlock accounts and read the security
+// events of lockouts
+func RegisterLockoutHandlers(s model.LockoutUsecase, us model.UserUsecase, rl model.RateLimitUsecase, ms model.UsageUsecase, r *mux.Router, l *slog.Logger) {
+	handler := &lockoutHandler{
+		service: s,
+		log:     l,
+	}
+
+	sr := r.PathPrefix("/admin").Subrouter()
+	sr.Use(middleware.APIKeyValidation(us, l), middleware.RateLimit(rl, l), middleware.Metering(ms, l), middleware.Validation)
+
+	sr.HandleFunc("/users/{userID}/unlock", handler.UnlockUser).Methods("POST")
+	sr.HandleFunc("/security-events", handler.ListSecurityEvents).Methods("GET")
+}
+
+func (h *lockoutHandler) UnlockUser(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["userID"])
+	if err != nil {
+		util.WriteError(w, r, errInvalidID)
+		return
+	}
+
+	if err := h.service.Unlock(r.Context(), id); err != nil {
+		util.WriteError(w, r, err)
+		h.log.Warn("error unlocking user", slog.Any("error", err))
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, model.JSONResponse{Message: "User Unlocked"})
+}
+
+func (h *lockoutHandler) ListSecurityEvents(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+
+	limit, err := strconv.Atoi(params.Get("limit"))
+	if err != nil {
+		limit = 30
+	}
+
+	offset, err := strconv.Atoi(params.Get("offset"))
+	if err != nil {
+		offset = 0
+	}
+
+	events, err := h.service.Events(r.Context(), limit, offset)
+	if err != nil {
+		util.WriteError(w, r, err)
+		h.log.Warn("error listing security events", slog.Any("error", err))
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, model.JSONResponse{SecurityEvents: events})
+}
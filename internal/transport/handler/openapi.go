@@ -0,0 +1,165 @@
+package handler
+
+import (
+	_ "embed"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+	"github.com/LaQuannT/astronaut-data-api/internal/patch"
+	"github.com/LaQuannT/astronaut-data-api/internal/transport/middleware"
+	"github.com/LaQuannT/astronaut-data-api/internal/transport/openapi"
+	"github.com/LaQuannT/astronaut-data-api/internal/transport/util"
+	"github.com/gorilla/mux"
+)
+
+// APIPrefix is the path every v1 route is registered under
+const APIPrefix = "/api/v1"
+
+// V1Spec documents the v1 api
+var V1Spec = openapi.Spec{
+	Info:     apiInfo,
+	Prefix:   APIPrefix,
+	Routes:   routeDocs,
+	Envelope: model.JSONResponse{},
+}
+
+//go:embed docs.html
+var docsPage []byte
+
+var apiInfo = openapi.Info{
+	Title:       "Astronaut Data API",
+	Version:     "1.0.0",
+	Description: "NASA astronaut records with user accounts and curation tools for admins.",
+}
+
+// jsonPatchOperation documents one RFC 6902 operation
+type jsonPatchOperation struct {
+	Op    string `json:"op"`
+	Path  string `json:"path"`
+	From  string `json:"from,omitempty"`
+	Value any    `json:"value,omitempty"`
+}
+
+var (
+	limitParam   = openapi.Query("limit", "Page size, defaults to 30", &openapi.Schema{Type: "integer"})
+	offsetParam  = openapi.Query("offset", "Number of records to skip", &openapi.Schema{Type: "integer"})
+	formatParam  = openapi.Query("format", "Overrides the Accept header", &openapi.Schema{Type: "string", Enum: []string{"json", "jsonld", "xml", "yaml", "csv"}})
+	ifMatchParam = openapi.Header("If-Match", "ETags of the version being replaced, '*' skips the check", true)
+	noneMatch    = openapi.Header("If-None-Match", "ETag of a cached representation", false)
+	modSince     = openapi.Header("If-Modified-Since", "Time of a cached representation", false)
+	idempotency  = openapi.Header("Idempotency-Key", "Replays the first response when the request is retried with the same key", false)
+	fromParam    = openapi.Query("from", "First day, defaults to the start of the month", &openapi.Schema{Type: "string", Format: "date"})
+	toParam      = openapi.Query("to", "Last day, defaults to today", &openapi.Schema{Type: "string", Format: "date"})
+
+	astronautProduces = []string{util.JSONContentType, util.JSONLDContentType, util.XMLContentType, util.YAMLContentType, util.CSVContentType}
+	userProduces      = util.ResponseTypes
+)
+
+// routeDocs documents every route on the v1 router, a route registered
+// without an entry here fails the openapi test
+var routeDocs = map[string]openapi.Route{
+	"GET " + APIPrefix + "/openapi.json": {Summary: "This OpenAPI document", Tags: []string{"docs"}, Public: true, Response: map[string]any{}},
+	"GET " + APIPrefix + "/docs":         {Summary: "Offline API documentation page", Tags: []string{"docs"}, Public: true, Response: "", Produces: []string{"text/html"}},
+
+	"POST " + APIPrefix + "/auth/login":   {Summary: "Exchange an email and password for access and refresh tokens", Tags: []string{"auth"}, Public: true, Body: model.Credentials{}, Response: model.TokenPair{}},
+	"POST " + APIPrefix + "/auth/refresh": {Summary: "Rotate a refresh token for a new token pair", Tags: []string{"auth"}, Public: true, Body: refreshRequest{}, Response: model.TokenPair{}},
+	"POST " + APIPrefix + "/auth/logout":  {Summary: "Revoke a refresh token and every token rotated from its login", Tags: []string{"auth"}, Public: true, Body: refreshRequest{}, Envelope: []string{"message"}},
+
+	"POST " + APIPrefix + "/auth/password/forgot":    {Summary: "Mail a password reset token to a registered email", Tags: []string{"auth"}, Public: true, Body: emailRequest{}, Status: http.StatusAccepted, Envelope: []string{"message"}},
+	"POST " + APIPrefix + "/auth/password/reset":     {Summary: "Set a new password with a mailed reset token", Tags: []string{"auth"}, Public: true, Body: passwordResetRequest{}, Envelope: []string{"message"}},
+	"POST " + APIPrefix + "/auth/email/verify":       {Summary: "Verify an email with a mailed verification token", Tags: []string{"auth"}, Public: true, Body: tokenRequest{}, Envelope: []string{"message"}},
+	"POST " + APIPrefix + "/auth/email/resend":       {Summary: "Mail a new verification token to an unverified email", Tags: []string{"auth"}, Public: true, Body: emailRequest{}, Status: http.StatusAccepted, Envelope: []string{"message"}},
+	"POST " + APIPrefix + "/auth/invitations/accept": {Summary: "Create an admin account from a mailed invitation", Tags: []string{"auth"}, Public: true, Body: acceptInvitationRequest{}, Status: http.StatusCreated, Envelope: []string{"user"}},
+
+	"POST " + APIPrefix + "/users":                            {Summary: "Sign up as a new user, always with the user role", Tags: []string{"users"}, Public: true, Body: model.User{}, Status: http.StatusCreated, Envelope: []string{"user"}},
+	"GET " + APIPrefix + "/users":                             {Summary: "List users (admin)", Tags: []string{"users"}, Params: []*openapi.Parameter{limitParam, offsetParam, formatParam}, Envelope: []string{"users", "_links"}, Produces: userProduces},
+	"GET " + APIPrefix + "/users/{userID}":                    {Summary: "Fetch a user", Tags: []string{"users"}, Params: []*openapi.Parameter{formatParam}, Envelope: []string{"user"}, Produces: userProduces},
+	"PUT " + APIPrefix + "/users/{userID}":                    {Summary: "Update a user", Tags: []string{"users"}, Params: []*openapi.Parameter{ifMatchParam}, Body: model.User{}, Envelope: []string{"user"}},
+	"DELETE " + APIPrefix + "/users/{userID}":                 {Summary: "Delete a user", Tags: []string{"users"}, Params: []*openapi.Parameter{ifMatchParam}, Envelope: []string{"message"}},
+	"PATCH " + APIPrefix + "/users/password/{userID}":         {Summary: "Reset your password", Tags: []string{"users"}, Body: model.User{}, Envelope: []string{"message"}},
+	"PATCH " + APIPrefix + "/users/apikey/{userID}":           {Summary: "Replace the API key used for the request", Tags: []string{"users"}, Envelope: []string{"user"}},
+	"POST " + APIPrefix + "/users/{userID}/apikeys":           {Summary: "Create a named, scoped API key", Tags: []string{"users"}, Body: apiKeyInput{}, Status: http.StatusCreated, Envelope: []string{"apiKey"}},
+	"GET " + APIPrefix + "/users/{userID}/apikeys":            {Summary: "List a user's API keys, without the keys themselves", Tags: []string{"users"}, Envelope: []string{"apiKeys"}},
+	"DELETE " + APIPrefix + "/users/{userID}/apikeys/{keyID}": {Summary: "Revoke an API key", Tags: []string{"users"}, Envelope: []string{"message"}},
+	"GET " + APIPrefix + "/users/{userID}/usage":              {Summary: "Daily usage of a user and their monthly quota", Tags: []string{"users"}, Params: []*openapi.Parameter{fromParam, toParam}, Envelope: []string{"usage"}},
+
+	"GET " + APIPrefix + "/catalog":                            {Summary: "schema.org DataCatalog describing the dataset", Tags: []string{"astronauts"}, Public: true, Response: ldDataCatalog{}, Produces: []string{util.JSONLDContentType}},
+	"POST " + APIPrefix + "/astronauts":                        {Summary: "Create an astronaut", Tags: []string{"astronauts"}, Params: []*openapi.Parameter{idempotency}, Body: model.Astronaut{}, Status: http.StatusCreated, Envelope: []string{"astronaut"}},
+	"GET " + APIPrefix + "/astronauts":                         {Summary: "List astronauts", Tags: []string{"astronauts"}, Params: []*openapi.Parameter{limitParam, offsetParam, formatParam, noneMatch, modSince}, Envelope: []string{"astronauts", "_links"}, Produces: astronautProduces, Alternates: map[string]any{util.JSONLDContentType: ldItemList{}}},
+	"POST " + APIPrefix + "/astronauts/batch":                  {Summary: "Create, update and delete astronauts in one transaction (admin)", Tags: []string{"astronauts"}, Params: []*openapi.Parameter{idempotency}, Body: batchRequest{}, Response: batchResponse{}, Also: []int{http.StatusMultiStatus, http.StatusConflict}},
+	"GET " + APIPrefix + "/astronauts/{astronautID}":           {Summary: "Fetch an astronaut, merged IDs redirect with 308", Tags: []string{"astronauts"}, Params: []*openapi.Parameter{formatParam, noneMatch, modSince}, Envelope: []string{"astronaut"}, Produces: astronautProduces, Alternates: map[string]any{util.JSONLDContentType: ldPerson{}}},
+	"PUT " + APIPrefix + "/astronauts/{astronautID}":           {Summary: "Replace an astronaut", Tags: []string{"astronauts"}, Params: []*openapi.Parameter{ifMatchParam}, Body: model.Astronaut{}, Envelope: []string{"astronaut"}},
+	"PATCH " + APIPrefix + "/astronauts/{astronautID}":         {Summary: "Patch an astronaut", Tags: []string{"astronauts"}, Params: []*openapi.Parameter{ifMatchParam}, Bodies: map[string]any{patch.MergePatchContentType: model.Astronaut{}, util.JSONContentType: model.Astronaut{}, patch.JSONPatchContentType: []jsonPatchOperation{}}, Envelope: []string{"astronaut"}},
+	"DELETE " + APIPrefix + "/astronauts/{astronautID}":        {Summary: "Delete an astronaut", Tags: []string{"astronauts"}, Params: []*openapi.Parameter{ifMatchParam}, Envelope: []string{"message"}},
+	"GET " + APIPrefix + "/astronauts/{astronautID}/history":   {Summary: "Change history of an astronaut", Tags: []string{"astronauts"}, Envelope: []string{"history", "_links"}},
+	"GET " + APIPrefix + "/astronauts/{astronautID}/missions":  {Summary: "Missions an astronaut flew", Tags: []string{"astronauts"}, Envelope: []string{"missions", "_links"}},
+	"GET " + APIPrefix + "/astronauts/{astronautID}/crewmates": {Summary: "Astronauts who shared a mission with an astronaut", Tags: []string{"astronauts"}, Envelope: []string{"astronauts", "_links"}},
+	"POST " + APIPrefix + "/admin/reconcile":                   {Summary: "Diff a csv dataset against the stored astronauts", Tags: []string{"admin"}, Bodies: map[string]any{util.CSVContentType: ""}, Envelope: []string{"diff"}},
+	"POST " + APIPrefix + "/admin/reconcile/apply":             {Summary: "Apply a csv dataset, optionally only the selected keys", Tags: []string{"admin"}, Params: []*openapi.Parameter{openapi.Query("key", "Natural key of a change to apply, repeatable", &openapi.Schema{Type: "string"})}, Bodies: map[string]any{util.CSVContentType: ""}, Envelope: []string{"diff", "message"}},
+	"GET " + APIPrefix + "/admin/data-quality":                 {Summary: "Data quality findings", Tags: []string{"admin"}, Params: []*openapi.Parameter{openapi.Query("format", "csv exports the findings", &openapi.Schema{Type: "string", Enum: []string{"csv"}})}, Envelope: []string{"findings"}, Produces: []string{util.JSONContentType, util.CSVContentType}},
+	"GET " + APIPrefix + "/admin/astronauts/duplicates":        {Summary: "Likely duplicate astronauts", Tags: []string{"admin"}, Params: []*openapi.Parameter{openapi.Query("threshold", "Minimum score between 0 and 1", &openapi.Schema{Type: "number"})}, Envelope: []string{"duplicates"}},
+	"POST " + APIPrefix + "/admin/astronauts/merge":            {Summary: "Merge a duplicate astronaut into another", Tags: []string{"admin"}, Body: mergeRequest{}, Envelope: []string{"astronaut", "message"}},
+	"GET " + APIPrefix + "/admin/roles":                        {Summary: "List roles and their permissions", Tags: []string{"admin"}, Envelope: []string{"roles"}},
+	"POST " + APIPrefix + "/admin/roles":                       {Summary: "Create a role", Tags: []string{"admin"}, Body: model.Role{}, Status: http.StatusCreated, Envelope: []string{"role"}},
+	"GET " + APIPrefix + "/admin/roles/{name}":                 {Summary: "Fetch a role", Tags: []string{"admin"}, Envelope: []string{"role"}},
+	"PUT " + APIPrefix + "/admin/roles/{name}":                 {Summary: "Replace the description and permissions of a role", Tags: []string{"admin"}, Params: []*openapi.Parameter{ifMatchParam}, Body: model.Role{}, Envelope: []string{"role"}},
+	"DELETE " + APIPrefix + "/admin/roles/{name}":              {Summary: "Delete a role no user has", Tags: []string{"admin"}, Params: []*openapi.Parameter{ifMatchParam}, Envelope: []string{"message"}},
+	"GET " + APIPrefix + "/admin/usage":                        {Summary: "Usage of every user, heaviest first", Tags: []string{"admin"}, Params: []*openapi.Parameter{fromParam, toParam}, Envelope: []string{"report"}},
+	"POST " + APIPrefix + "/admin/users/{userID}/unlock":       {Summary: "Clear the failed logins locking out a user", Tags: []string{"admin"}, Envelope: []string{"message"}},
+	"POST " + APIPrefix + "/admin/invitations":                 {Summary: "Mail an invitation to become an admin", Tags: []string{"admin"}, Body: emailRequest{}, Status: http.StatusCreated, Envelope: []string{"invitation"}},
+	"GET " + APIPrefix + "/admin/security-events":              {Summary: "Lockouts and unlocks, newest first", Tags: []string{"admin"}, Params: []*openapi.Parameter{limitParam, offsetParam}, Envelope: []string{"securityEvents"}},
+}
+
+type docsHandler struct {
+	spec openapi.Spec
+	root *mux.Router
+	log  *slog.Logger
+
+	once     sync.Once
+	document []byte
+}
+
+// RegisterDocsHandlers serves the openapi document for the routes spec
+// describes on root and an offline page rendering it
+func RegisterDocsHandlers(spec openapi.Spec, root, r *mux.Router, l *slog.Logger) {
+	handler := &docsHandler{
+		spec: spec,
+		root: root,
+		log:  l,
+	}
+
+	r.Handle("/openapi.json", middleware.Validation(http.HandlerFunc(handler.OpenAPI))).Methods("GET")
+	r.Handle("/docs", middleware.Validation(http.HandlerFunc(handler.Docs))).Methods("GET")
+}
+
+func (h *docsHandler) OpenAPI(w http.ResponseWriter, r *http.Request) {
+	// routes are all registered before the server starts, so the document
+	// is built once on first request
+	h.once.Do(func() {
+		doc, err := openapi.Build(h.root, h.spec)
+		if err != nil {
+			h.log.Warn("openapi document is incomplete", slog.Any("error", err))
+		}
+		if doc != nil {
+			h.document, _ = json.Marshal(doc)
+		}
+	})
+
+	if h.document == nil {
+		util.WriteError(w, r, model.NewError(model.KindInternal, "internal_error", "openapi document unavailable"))
+		return
+	}
+
+	w.Header().Set("Content-Type", util.JSONContentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(h.document)
+}
+
+func (h *docsHandler) Docs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write(docsPage)
+}
@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+	"github.com/LaQuannT/astronaut-data-api/internal/transport/middleware"
+	"github.com/LaQuannT/astronaut-data-api/internal/transport/util"
+	"github.com/gorilla/mux"
+)
+
+type roleHandler struct {
+	service model.RoleUsecase
+	log     *slog.Logger
+}
+
+// RegisterRoleHandlers serves the role management endpoints, every one of
+// them needs the roles:admin permission
+func RegisterRoleHandlers(s model.RoleUsecase, us model.UserUsecase, rl model.RateLimitUsecase, ms model.UsageUsecase, r *mux.Router, l *slog.Logger) {
+	handler := &roleHandler{
+		service: s,
+		log:     l,
+	}
+
+	sr := r.PathPrefix("/admin/roles").Subrouter()
+	sr.Use(middleware.APIKeyValidation(us, l), middleware.RateLimit(rl, l), middleware.Metering(ms, l), middleware.Validation)
+
+	sr.HandleFunc("", handler.ListRoles).Methods("GET")
+	sr.HandleFunc("", handler.CreateRole).Methods("POST")
+	sr.HandleFunc("/{name}", handler.GetRole).Methods("GET")
+	sr.HandleFunc("/{name}", handler.UpdateRole).Methods("PUT")
+	sr.HandleFunc("/{name}", handler.DeleteRole).Methods("DELETE")
+}
+
+func (h *roleHandler) ListRoles(w http.ResponseWriter, r *http.Request) {
+	roles, err := h.service.List(r.Context())
+	if err != nil {
+		util.WriteError(w, r, err)
+		h.log.Warn("error listing roles", slog.Any("error", err))
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, model.JSONResponse{Roles: roles})
+}
+
+func (h *roleHandler) CreateRole(w http.ResponseWriter, r *http.Request) {
+	role := new(model.Role)
+
+	if err := json.NewDecoder(r.Body).Decode(role); err != nil {
+		util.WriteError(w, r, errInvalidBody)
+		h.log.Warn("error decoding json request body", slog.Any("error", err))
+		return
+	}
+
+	role, err := h.service.Create(r.Context(), role)
+	if err != nil {
+		util.WriteError(w, r, err)
+		h.log.Warn("error creating role", slog.Any("error", err))
+		return
+	}
+
+	util.SetETag(w, role.Version)
+	util.WriteJSON(w, http.StatusCreated, model.JSONResponse{Role: role})
+}
+
+func (h *roleHandler) GetRole(w http.ResponseWriter, r *http.Request) {
+	role, err := h.service.Get(r.Context(), mux.Vars(r)["name"])
+	if err != nil {
+		util.WriteError(w, r, err)
+		h.log.Warn("error fetching role", slog.Any("error", err))
+		return
+	}
+
+	util.SetETag(w, role.Version)
+	util.WriteJSON(w, http.StatusOK, model.JSONResponse{Role: role})
+}
+
+func (h *roleHandler) UpdateRole(w http.ResponseWriter, r *http.Request) {
+	role := new(model.Role)
+
+	version, err := util.IfMatchVersion(r)
+	if err != nil {
+		util.WriteError(w, r, err)
+		return
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(role); err != nil {
+		util.WriteError(w, r, errInvalidBody)
+		h.log.Warn("error decoding json request body", slog.Any("error", err))
+		return
+	}
+
+	role.Name = mux.Vars(r)["name"]
+	role.Version = version
+
+	role, err = h.service.Update(r.Context(), role)
+	if err != nil {
+		util.WriteError(w, r, err)
+		h.log.Warn("error updating role", slog.Any("error", err))
+		return
+	}
+
+	util.SetETag(w, role.Version)
+	util.WriteJSON(w, http.StatusOK, model.JSONResponse{Role: role})
+}
+
+func (h *roleHandler) DeleteRole(w http.ResponseWriter, r *http.Request) {
+	version, err := util.IfMatchVersion(r)
+	if err != nil {
+		util.WriteError(w, r, err)
+		return
+	}
+
+	if err := h.service.Delete(r.Context(), mux.Vars(r)["name"], version); err != nil {
+		util.WriteError(w, r, err)
+		h.log.Warn("error deleting role", slog.Any("error", err))
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, model.JSONResponse{Message: "Role Deleted"})
+}
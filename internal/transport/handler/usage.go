@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+	"github.com/LaQuannT/astronaut-data-api/internal/transport/middleware"
+	"github.com/LaQuannT/astronaut-data-api/internal/transport/util"
+	"github.com/gorilla/mux"
+)
+
+const dateLayout = "2006-01-02"
+
+type usageHandler struct {
+	service model.UsageUsecase
+	log     *slog.Logger
+}
+
+// RegisterUsageHandlers serves a user's own usage and the admin report of
+// every user
+func RegisterUsageHandlers(s model.UsageUsecase, us model.UserUsecase, rl model.RateLimitUsecase, r *mux.Router, l *slog.Logger) {
+	handler := &usageHandler{
+		service: s,
+		log:     l,
+	}
+
+	sr := r.NewRoute().Subrouter()
+	sr.Use(middleware.APIKeyValidation(us, l), middleware.RateLimit(rl, l), middleware.Metering(s, l), middleware.Validation)
+
+	sr.HandleFunc("/users/{userID}/usage", handler.GetUsage).Methods("GET")
+	sr.HandleFunc("/admin/usage", handler.UsageReport).Methods("GET")
+}
+
+func (h *usageHandler) GetUsage(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["userID"])
+	if err != nil {
+		util.WriteError(w, r, errInvalidID)
+		return
+	}
+
+	from, to, err := usageRange(r)
+	if err != nil {
+		util.WriteError(w, r, err)
+		return
+	}
+
+	usage, err := h.service.Get(r.Context(), id, from, to)
+	if err != nil {
+		util.WriteError(w, r, err)
+		h.log.Warn("error fetching usage", slog.Any("error", err))
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, model.JSONResponse{Usage: usage})
+}
+
+func (h *usageHandler) UsageReport(w http.ResponseWriter, r *http.Request) {
+	from, to, err := usageRange(r)
+	if err != nil {
+		util.WriteError(w, r, err)
+		return
+	}
+
+	report, err := h.service.Report(r.Context(), from, to)
+	if err != nil {
+		util.WriteError(w, r, err)
+		h.log.Warn("error reporting usage", slog.Any("error", err))
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, model.JSONResponse{Report: report})
+}
+
+// usageRange reads the 'from' and 'to' days of a usage query, they default to
+// the current month so far
+func usageRange(r *http.Request) (time.Time, time.Time, error) {
+	now := time.Now().UTC()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	q := r.URL.Query()
+	for param, day := range map[string]*time.Time{"from": &from, "to": &to} {
+		if v := q.Get(param); v != "" {
+			t, err := time.Parse(dateLayout, v)
+			if err != nil {
+				return time.Time{}, time.Time{}, errInvalidDate
+			}
+			*day = t
+		}
+	}
+
+	return from, to, nil
+}
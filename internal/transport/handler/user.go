@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/url"
 	"strconv"
+	"time"
 
 	"github.com/LaQuannT/astronaut-data-api/internal/model"
 	"github.com/LaQuannT/astronaut-data-api/internal/transport/middleware"
@@ -14,26 +15,47 @@ import (
 )
 
 type userHandler struct {
-	service model.UserUsecase
-	log     *slog.Logger
+	service  model.UserUsecase
+	accounts model.AccountUsecase
+	links    linker
+	log      *slog.Logger
 }
 
-func RegisterUserHandlers(s model.UserUsecase, r *mux.Router, l *slog.Logger) {
+// apiKeyInput is the body creating an API key, a key without expiresAt
+// never expires
+type apiKeyInput struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+func (in *apiKeyInput) toModel() *model.APIKey {
+	return &model.APIKey{Name: in.Name, Scopes: in.Scopes, ExpiresAt: in.ExpiresAt}
+}
+
+func RegisterUserHandlers(s model.UserUsecase, as model.AccountUsecase, rl model.RateLimitUsecase, ms model.UsageUsecase, r *mux.Router, l *slog.Logger) {
 	handler := userHandler{
-		service: s,
-		log:     l,
+		service:  s,
+		accounts: as,
+		links:    newLinker(r, "v1"),
+		log:      l,
 	}
 
 	sr := r.PathPrefix("/users").Subrouter()
-	sr.Use(middleware.APIKeyValidation(s, l))
+	sr.Use(middleware.APIKeyValidation(s, l), middleware.RateLimit(rl, l), middleware.Metering(ms, l), middleware.Validation)
 
-	r.HandleFunc("/users", handler.CreateUser).Methods("POST")
-	sr.HandleFunc("", handler.ListUsers).Methods("GET")
-	sr.HandleFunc("/{userID}", handler.GetUser).Methods("GET")
+	// sign ups are not idempotent, a replay would show the API key again, and
+	// are limited by client IP
+	r.Handle("/users", middleware.RateLimit(rl, l)(middleware.Validation(http.HandlerFunc(handler.CreateUser)))).Methods("POST")
+	sr.HandleFunc("", handler.ListUsers).Methods("GET").Name(handler.links.name(routeUsers))
+	sr.HandleFunc("/{userID}", handler.GetUser).Methods("GET").Name(handler.links.name(routeUser))
 	sr.HandleFunc("/{userID}", handler.UpdateUser).Methods("PUT")
 	sr.HandleFunc("/{userID}", handler.DeleteUser).Methods("DELETE")
-	sr.HandleFunc("/password/{userID}", handler.PasswordReset).Methods("PATCH")
-	sr.HandleFunc("/apikey/{userID}", handler.APIKeyReset).Methods("PATCH")
+	sr.HandleFunc("/password/{userID}", handler.PasswordReset).Methods("PATCH").Name(handler.links.name(routeUserPassword))
+	sr.HandleFunc("/apikey/{userID}", handler.APIKeyReset).Methods("PATCH").Name(handler.links.name(routeUserAPIKey))
+	sr.HandleFunc("/{userID}/apikeys", handler.CreateAPIKey).Methods("POST")
+	sr.HandleFunc("/{userID}/apikeys", handler.ListAPIKeys).Methods("GET").Name(handler.links.name(routeUserAPIKeys))
+	sr.HandleFunc("/{userID}/apikeys/{keyID}", handler.RevokeAPIKey).Methods("DELETE")
 }
 
 func (h *userHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
@@ -41,19 +63,30 @@ func (h *userHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
 	if err := json.NewDecoder(r.Body).Decode(u); err != nil {
-		util.WriteJSON(w, http.StatusBadRequest, model.JSONResponse{Error: "Invalid request body"})
+		util.WriteError(w, r, errInvalidBody)
 		h.log.Warn("error decoding json request body", slog.Any("error", err))
 		return
 	}
 
-	u, errs := h.service.Create(ctx, u)
-	if errs != nil {
-		util.WriteJSON(w, http.StatusBadRequest, model.JSONResponse{Error: "Bad Request"})
-		h.log.Warn("error creating new user", slog.Any("error", errs))
+	u, err := h.service.Create(ctx, u)
+	if err != nil {
+		util.WriteError(w, r, err)
+		h.log.Warn("error creating new user", slog.Any("error", err))
 		return
 	}
 
-	util.WriteJSON(w, http.StatusCreated, model.JSONResponse{User: u})
+	// the account exists either way, the user can ask for the mail again
+	if h.accounts != nil {
+		if err := h.accounts.SendVerification(ctx, u); err != nil {
+			h.log.Warn("error sending verification email", slog.Any("error", err))
+		}
+	}
+
+	// the password holds the hash by now
+	u.Password = ""
+
+	util.SetETag(w, u.Version)
+	util.WriteJSON(w, http.StatusCreated, model.JSONResponse{User: h.links.user(u)})
 }
 
 func (h *userHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
@@ -61,7 +94,7 @@ func (h *userHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
 
 	params, err := url.ParseQuery(r.URL.RawQuery)
 	if err != nil {
-		util.WriteJSON(w, http.StatusBadRequest, model.JSONResponse{Error: "Invalid request query"})
+		util.WriteError(w, r, errInvalidQuery)
 		h.log.Warn("error parsing url request query", slog.Any("error", err))
 		return
 	}
@@ -83,12 +116,34 @@ func (h *userHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
 
 	users, err := h.service.List(ctx, limit, offset)
 	if err != nil {
-		util.WriteJSON(w, http.StatusBadRequest, model.JSONResponse{Error: "Bad Request"})
+		util.WriteError(w, r, err)
 		h.log.Warn("error listing users", slog.Any("error", err))
 		return
 	}
 
-	util.WriteJSON(w, http.StatusOK, model.JSONResponse{Users: users})
+	total, err := h.service.Count(ctx)
+	if err != nil {
+		util.WriteError(w, r, err)
+		h.log.Warn("error counting users", slog.Any("error", err))
+		return
+	}
+
+	res := model.JSONResponse{Users: h.links.users(users)}
+
+	mediaType := util.Negotiate(w, r, util.ResponseTypes...)
+	if mediaType == "" {
+		util.WriteError(w, r, util.ErrNotAcceptable)
+		return
+	}
+
+	pages := h.links.pages(r, routeUsers, total, limit, offset)
+	linkHeader(w, pages)
+	// csv holds a single table, its page links are only in the header
+	if mediaType != util.CSVContentType {
+		res.Links = pages
+	}
+
+	util.Write(w, r, mediaType, http.StatusOK, res)
 }
 
 func (h *userHandler) GetUser(w http.ResponseWriter, r *http.Request) {
@@ -98,18 +153,19 @@ func (h *userHandler) GetUser(w http.ResponseWriter, r *http.Request) {
 
 	id, err := strconv.Atoi(userID)
 	if err != nil {
-		util.WriteJSON(w, http.StatusBadRequest, model.JSONResponse{Error: "Invalid User ID"})
+		util.WriteError(w, r, errInvalidID)
 		return
 	}
 
 	u, err := h.service.Get(ctx, id)
 	if err != nil {
-		util.WriteJSON(w, http.StatusBadRequest, model.JSONResponse{Error: "Bad Request"})
+		util.WriteError(w, r, err)
 		h.log.Warn("error fetching a user", slog.Any("error", err))
 		return
 	}
 
-	util.WriteJSON(w, http.StatusOK, model.JSONResponse{User: u})
+	util.SetETag(w, u.Version)
+	util.Respond(w, r, http.StatusOK, model.JSONResponse{User: h.links.user(u)})
 }
 
 func (h *userHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
@@ -120,26 +176,34 @@ func (h *userHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 
 	id, err := strconv.Atoi(userID)
 	if err != nil {
-		util.WriteJSON(w, http.StatusBadRequest, model.JSONResponse{Error: "Invalid User ID"})
+		util.WriteError(w, r, errInvalidID)
+		return
+	}
+
+	version, err := util.IfMatchVersion(r)
+	if err != nil {
+		util.WriteError(w, r, err)
 		return
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(u); err != nil {
-		util.WriteJSON(w, http.StatusBadRequest, model.JSONResponse{Error: "Invalid request body"})
+		util.WriteError(w, r, errInvalidBody)
 		h.log.Warn("error decoding json request body", slog.Any("error", err))
 		return
 	}
 
 	u.ID = id
+	u.Version = version
 
-	u, errs := h.service.Update(ctx, u)
-	if errs != nil {
-		util.WriteJSON(w, http.StatusBadRequest, model.JSONResponse{Error: "Bad Request"})
-		h.log.Warn("error updating a user", slog.Any("errors", errs))
+	u, err = h.service.Update(ctx, u)
+	if err != nil {
+		util.WriteError(w, r, err)
+		h.log.Warn("error updating a user", slog.Any("error", err))
 		return
 	}
 
-	util.WriteJSON(w, http.StatusOK, model.JSONResponse{User: u})
+	util.SetETag(w, u.Version)
+	util.WriteJSON(w, http.StatusOK, model.JSONResponse{User: h.links.user(u)})
 }
 
 func (h *userHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
@@ -149,12 +213,19 @@ func (h *userHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 
 	id, err := strconv.Atoi(userID)
 	if err != nil {
-		util.WriteJSON(w, http.StatusBadRequest, model.JSONResponse{Error: "Invalid User ID"})
+		util.WriteError(w, r, errInvalidID)
+		return
+	}
+
+	version, err := util.IfMatchVersion(r)
+	if err != nil {
+		util.WriteError(w, r, err)
 		return
 	}
 
-	if err := h.service.Delete(ctx, id); err != nil {
-		util.WriteJSON(w, http.StatusBadRequest, model.JSONResponse{Error: "Bad Request"})
+	err = h.service.Delete(ctx, id, version)
+	if err != nil {
+		util.WriteError(w, r, err)
 		h.log.Warn("error deleting a user", slog.Any("error", err))
 		return
 	}
@@ -170,21 +241,21 @@ func (h *userHandler) PasswordReset(w http.ResponseWriter, r *http.Request) {
 
 	id, err := strconv.Atoi(userID)
 	if err != nil {
-		util.WriteJSON(w, http.StatusBadRequest, model.JSONResponse{Error: "Invalid User ID"})
+		util.WriteError(w, r, errInvalidID)
 		return
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(u); err != nil {
-		util.WriteJSON(w, http.StatusBadRequest, model.JSONResponse{Error: "Invalid request body"})
+		util.WriteError(w, r, errInvalidBody)
 		h.log.Warn("error decoding json request body", slog.Any("error", err))
 		return
 	}
 
 	u.ID = id
 
-	if errs := h.service.ResetPassword(ctx, u); err != nil {
-		util.WriteJSON(w, http.StatusBadRequest, model.JSONResponse{Error: "Bad Request"})
-		h.log.Warn("error reseting user password", slog.Any("errors", errs))
+	if err := h.service.ResetPassword(ctx, u); err != nil {
+		util.WriteError(w, r, err)
+		h.log.Warn("error reseting user password", slog.Any("error", err))
 		return
 	}
 
@@ -198,16 +269,86 @@ func (h *userHandler) APIKeyReset(w http.ResponseWriter, r *http.Request) {
 
 	id, err := strconv.Atoi(userID)
 	if err != nil {
-		util.WriteJSON(w, http.StatusBadRequest, model.JSONResponse{Error: "Invalid User ID"})
+		util.WriteError(w, r, errInvalidID)
 		return
 	}
 
 	u, err := h.service.GenerateNewAPIKey(ctx, id)
 	if err != nil {
-		util.WriteJSON(w, http.StatusBadRequest, model.JSONResponse{Error: "Bad Request"})
+		util.WriteError(w, r, err)
 		h.log.Warn("error resetting API key", slog.Any("error", err))
 		return
 	}
 
-	util.WriteJSON(w, http.StatusOK, model.JSONResponse{User: u})
+	util.WriteJSON(w, http.StatusOK, model.JSONResponse{User: h.links.user(u)})
+}
+
+func (h *userHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	in := new(apiKeyInput)
+
+	id, err := strconv.Atoi(mux.Vars(r)["userID"])
+	if err != nil {
+		util.WriteError(w, r, errInvalidID)
+		return
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(in); err != nil {
+		util.WriteError(w, r, errInvalidBody)
+		h.log.Warn("error decoding json request body", slog.Any("error", err))
+		return
+	}
+
+	k, err := h.service.CreateAPIKey(ctx, id, in.toModel())
+	if err != nil {
+		util.WriteError(w, r, err)
+		h.log.Warn("error creating API key", slog.Any("error", err))
+		return
+	}
+
+	util.WriteJSON(w, http.StatusCreated, model.JSONResponse{APIKey: k})
+}
+
+func (h *userHandler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id, err := strconv.Atoi(mux.Vars(r)["userID"])
+	if err != nil {
+		util.WriteError(w, r, errInvalidID)
+		return
+	}
+
+	keys, err := h.service.ListAPIKeys(ctx, id)
+	if err != nil {
+		util.WriteError(w, r, err)
+		h.log.Warn("error listing API keys", slog.Any("error", err))
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, model.JSONResponse{APIKeys: keys})
+}
+
+func (h *userHandler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	vars := mux.Vars(r)
+
+	id, err := strconv.Atoi(vars["userID"])
+	if err != nil {
+		util.WriteError(w, r, errInvalidID)
+		return
+	}
+
+	keyID, err := strconv.Atoi(vars["keyID"])
+	if err != nil {
+		util.WriteError(w, r, errInvalidID)
+		return
+	}
+
+	if err := h.service.RevokeAPIKey(ctx, id, keyID); err != nil {
+		util.WriteError(w, r, err)
+		h.log.Warn("error revoking API key", slog.Any("error", err))
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, model.JSONResponse{Message: "API key revoked"})
 }
@@ -0,0 +1,350 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+	"github.com/LaQuannT/astronaut-data-api/internal/patch"
+	"github.com/LaQuannT/astronaut-data-api/internal/transport/openapi"
+	"github.com/LaQuannT/astronaut-data-api/internal/transport/util"
+)
+
+// APIv2Prefix is the path every v2 route is registered under
+const APIv2Prefix = "/api/v2"
+
+// v2 responses share one envelope, resources go in data and failures in
+// errors
+type (
+	v2Document struct {
+		Data   any        `json:"data"`
+		Meta   *v2Meta    `json:"meta,omitempty"`
+		Links  *v2Links   `json:"links,omitempty"`
+		Errors []*v2Error `json:"errors,omitempty"`
+	}
+
+	v2Meta struct {
+		Count  int `json:"count"`
+		Total  int `json:"total"`
+		Limit  int `json:"limit"`
+		Offset int `json:"offset"`
+	}
+
+	v2Links struct {
+		Self  string `json:"self"`
+		First string `json:"first,omitempty"`
+		Prev  string `json:"prev,omitempty"`
+		Next  string `json:"next,omitempty"`
+		Last  string `json:"last,omitempty"`
+	}
+
+	// Field names the request member or parameter a validation error is about
+	v2Error struct {
+		Status string `json:"status"`
+		Code   string `json:"code"`
+		Title  string `json:"title"`
+		Detail string `json:"detail,omitempty"`
+		Field  string `json:"field,omitempty"`
+	}
+
+	v2Astronaut struct {
+		ID                 string      `json:"id"`
+		Name               string      `json:"name"`
+		Year               int         `json:"year"`
+		Group              int         `json:"group"`
+		Status             string      `json:"status"`
+		BirthDate          string      `json:"birthDate"`
+		BirthPlace         string      `json:"birthPlace"`
+		Gender             string      `json:"gender"`
+		AlmaMater          []string    `json:"almaMater"`
+		UndergraduateMajor []string    `json:"undergraduateMajor"`
+		GraduateMajor      []string    `json:"graduateMajor"`
+		MilitaryRank       string      `json:"militaryRank"`
+		MilitaryBranch     string      `json:"militaryBranch"`
+		SpaceFlights       int         `json:"spaceFlights"`
+		SpaceFlightHours   int         `json:"spaceFlightHours"`
+		SpaceWalks         int         `json:"spaceWalks"`
+		SpaceWalkHours     int         `json:"spaceWalkHours"`
+		Missions           []string    `json:"missions"`
+		DeathDate          string      `json:"deathDate"`
+		DeathMission       string      `json:"deathMission"`
+		Version            int         `json:"version"`
+		UpdatedAt          time.Time   `json:"updatedAt"`
+		Links              model.Links `json:"_links,omitempty"`
+	}
+
+	v2HistoryEntry struct {
+		ID          string          `json:"id"`
+		AstronautID string          `json:"astronautId"`
+		Event       string          `json:"event"`
+		Snapshot    json.RawMessage `json:"snapshot"`
+		RecordedAt  time.Time       `json:"recordedAt"`
+	}
+
+	v2User struct {
+		ID              string      `json:"id"`
+		FirstName       string      `json:"firstName"`
+		Surname         string      `json:"surname"`
+		Email           string      `json:"email"`
+		Role            string      `json:"role"`
+		ApiKey          string      `json:"apiKey,omitempty"`
+		EmailVerifiedAt *time.Time  `json:"emailVerifiedAt,omitempty"`
+		CreatedAt       time.Time   `json:"createdAt"`
+		UpdatedAt       time.Time   `json:"updatedAt"`
+		Version         int         `json:"version"`
+		Links           model.Links `json:"_links,omitempty"`
+	}
+
+	v2UserInput struct {
+		FirstName string `json:"firstName"`
+		Surname   string `json:"surname"`
+		Email     string `json:"email"`
+		Password  string `json:"password,omitempty"`
+		Role      string `json:"role,omitempty"`
+	}
+
+	v2PasswordInput struct {
+		Password string `json:"password"`
+	}
+
+	// v2APIKey is a key's metadata, Key is only set when it was just issued
+	v2APIKey struct {
+		ID        string     `json:"id"`
+		Name      string     `json:"name"`
+		Key       string     `json:"key,omitempty"`
+		Prefix    string     `json:"prefix"`
+		Scopes    []string   `json:"scopes"`
+		ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+		RevokedAt *time.Time `json:"revokedAt,omitempty"`
+		CreatedAt time.Time  `json:"createdAt"`
+	}
+)
+
+func toV2Astronaut(a *model.Astronaut) *v2Astronaut {
+	return &v2Astronaut{
+		ID:                 strconv.Itoa(a.ID),
+		Name:               a.Name,
+		Year:               a.Year,
+		Group:              a.Group,
+		Status:             a.Status,
+		BirthDate:          a.BirthDate,
+		BirthPlace:         a.BirthPlace,
+		Gender:             a.Gender,
+		AlmaMater:          a.AlmaMater,
+		UndergraduateMajor: a.UndergraduateMajor,
+		GraduateMajor:      a.GraduateMajor,
+		MilitaryRank:       a.MilitaryRank,
+		MilitaryBranch:     a.MilitaryBranch,
+		SpaceFlights:       a.SpaceFlights,
+		SpaceFlightHours:   a.SpaceFlightHours,
+		SpaceWalks:         a.SpaceWalks,
+		SpaceWalkHours:     a.SpaceWalkHours,
+		Missions:           a.Missions,
+		DeathDate:          a.DeathDate,
+		DeathMission:       a.DeathMission,
+		Version:            a.Version,
+		UpdatedAt:          a.UpdatedAt,
+		Links:              a.Links,
+	}
+}
+
+// toModel ignores the read only id, version and updatedAt members
+func (v *v2Astronaut) toModel() *model.Astronaut {
+	return &model.Astronaut{
+		Name:               v.Name,
+		Year:               v.Year,
+		Group:              v.Group,
+		Status:             v.Status,
+		BirthDate:          v.BirthDate,
+		BirthPlace:         v.BirthPlace,
+		Gender:             v.Gender,
+		AlmaMater:          v.AlmaMater,
+		UndergraduateMajor: v.UndergraduateMajor,
+		GraduateMajor:      v.GraduateMajor,
+		MilitaryRank:       v.MilitaryRank,
+		MilitaryBranch:     v.MilitaryBranch,
+		SpaceFlights:       v.SpaceFlights,
+		SpaceFlightHours:   v.SpaceFlightHours,
+		SpaceWalks:         v.SpaceWalks,
+		SpaceWalkHours:     v.SpaceWalkHours,
+		Missions:           v.Missions,
+		DeathDate:          v.DeathDate,
+		DeathMission:       v.DeathMission,
+	}
+}
+
+func toV2Astronauts(astronauts []*model.Astronaut) []*v2Astronaut {
+	data := make([]*v2Astronaut, 0, len(astronauts))
+	for _, a := range astronauts {
+		data = append(data, toV2Astronaut(a))
+	}
+	return data
+}
+
+func toV2History(history []*model.HistoryEntry) []*v2HistoryEntry {
+	data := make([]*v2HistoryEntry, 0, len(history))
+	for _, h := range history {
+		data = append(data, &v2HistoryEntry{
+			ID:          strconv.Itoa(h.ID),
+			AstronautID: strconv.Itoa(h.AstronautID),
+			Event:       h.Event,
+			Snapshot:    h.Snapshot,
+			RecordedAt:  h.RecordedAt,
+		})
+	}
+	return data
+}
+
+// toV2User leaves out the password hash, the API key is only set when it was
+// just issued
+func toV2User(u *model.User) *v2User {
+	return &v2User{
+		ID:              strconv.Itoa(u.ID),
+		FirstName:       u.FirstName,
+		Surname:         u.Surename,
+		Email:           u.Email,
+		Role:            u.Role,
+		ApiKey:          u.ApiKey,
+		EmailVerifiedAt: u.EmailVerifiedAt,
+		CreatedAt:       u.CreatedAt,
+		UpdatedAt:       u.UpdatedAt,
+		Version:         u.Version,
+		Links:           u.Links,
+	}
+}
+
+func toV2APIKey(k *model.APIKey) *v2APIKey {
+	return &v2APIKey{
+		ID:        strconv.Itoa(k.ID),
+		Name:      k.Name,
+		Key:       k.Key,
+		Prefix:    k.Prefix,
+		Scopes:    k.Scopes,
+		ExpiresAt: k.ExpiresAt,
+		RevokedAt: k.RevokedAt,
+		CreatedAt: k.CreatedAt,
+	}
+}
+
+func toV2Users(users []*model.User) []*v2User {
+	data := make([]*v2User, 0, len(users))
+	for _, u := range users {
+		data = append(data, toV2User(u))
+	}
+	return data
+}
+
+func (v *v2UserInput) toModel() *model.User {
+	return &model.User{
+		FirstName: v.FirstName,
+		Surename:  v.Surname,
+		Email:     v.Email,
+		Password:  v.Password,
+		Role:      v.Role,
+	}
+}
+
+func writeV2(w http.ResponseWriter, status int, doc *v2Document) {
+	util.WriteJSON(w, status, doc)
+}
+
+// writeV2Error is the v2 util.ErrorWriter, each field error becomes its own
+// entry
+func writeV2Error(w http.ResponseWriter, r *http.Request, err error) {
+	pe := util.Public(err)
+
+	base := v2Error{
+		Status: strconv.Itoa(pe.Status),
+		Code:   pe.Code,
+		Title:  http.StatusText(pe.Status),
+		Detail: pe.Detail,
+	}
+
+	doc := &v2Document{Errors: make([]*v2Error, 0, 1)}
+	for _, f := range pe.Fields {
+		e := base
+		e.Detail, e.Field = f.Message, f.Field
+		doc.Errors = append(doc.Errors, &e)
+	}
+	if len(doc.Errors) == 0 {
+		doc.Errors = append(doc.Errors, &base)
+	}
+
+	writeV2(w, pe.Status, doc)
+}
+
+// pagination reads limit and offset, the schema validator has already
+// rejected malformed values
+func pagination(r *http.Request) (limit, offset int) {
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit < 1 {
+		limit = 30
+	}
+
+	offset, err = strconv.Atoi(r.URL.Query().Get("offset"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	return limit, offset
+}
+
+// toV2Links flattens the page links of a list
+func toV2Links(links model.Links) *v2Links {
+	href := func(rel string) string {
+		if l, ok := links[rel]; ok {
+			return l.Href
+		}
+		return ""
+	}
+
+	return &v2Links{Self: href("self"), First: href("first"), Prev: href("prev"), Next: href("next"), Last: href("last")}
+}
+
+var (
+	v2Resource = []string{"data", "links"}
+	v2List     = []string{"data", "meta", "links"}
+)
+
+// v2RouteDocs documents every route on the v2 router
+var v2RouteDocs = map[string]openapi.Route{
+	"GET " + APIv2Prefix + "/openapi.json": {Summary: "This OpenAPI document", Tags: []string{"docs"}, Public: true, Response: map[string]any{}},
+	"GET " + APIv2Prefix + "/docs":         {Summary: "Offline API documentation page", Tags: []string{"docs"}, Public: true, Response: "", Produces: []string{"text/html"}},
+
+	"POST " + APIv2Prefix + "/users":                            {Summary: "Sign up as a new user", Tags: []string{"users"}, Public: true, Body: v2UserInput{}, Status: http.StatusCreated, Envelope: v2Resource, Data: v2User{}},
+	"GET " + APIv2Prefix + "/users":                             {Summary: "List users (admin)", Tags: []string{"users"}, Params: []*openapi.Parameter{limitParam, offsetParam}, Envelope: v2List, Data: []v2User{}},
+	"GET " + APIv2Prefix + "/users/{userID}":                    {Summary: "Fetch a user", Tags: []string{"users"}, Envelope: v2Resource, Data: v2User{}},
+	"PUT " + APIv2Prefix + "/users/{userID}":                    {Summary: "Update a user", Tags: []string{"users"}, Params: []*openapi.Parameter{ifMatchParam}, Body: v2UserInput{}, Envelope: v2Resource, Data: v2User{}},
+	"DELETE " + APIv2Prefix + "/users/{userID}":                 {Summary: "Delete a user", Tags: []string{"users"}, Params: []*openapi.Parameter{ifMatchParam}, Status: http.StatusNoContent},
+	"PUT " + APIv2Prefix + "/users/{userID}/password":           {Summary: "Reset your password", Tags: []string{"users"}, Body: v2PasswordInput{}, Status: http.StatusNoContent},
+	"POST " + APIv2Prefix + "/users/{userID}/apikey":            {Summary: "Replace the API key used for the request", Tags: []string{"users"}, Envelope: v2Resource, Data: v2User{}},
+	"POST " + APIv2Prefix + "/users/{userID}/apikeys":           {Summary: "Create a named, scoped API key", Tags: []string{"users"}, Body: apiKeyInput{}, Status: http.StatusCreated, Envelope: v2Resource, Data: v2APIKey{}},
+	"GET " + APIv2Prefix + "/users/{userID}/apikeys":            {Summary: "List a user's API keys, without the keys themselves", Tags: []string{"users"}, Envelope: v2Resource, Data: []v2APIKey{}},
+	"DELETE " + APIv2Prefix + "/users/{userID}/apikeys/{keyID}": {Summary: "Revoke an API key", Tags: []string{"users"}, Status: http.StatusNoContent},
+
+	"POST " + APIv2Prefix + "/astronauts":                        {Summary: "Create an astronaut", Tags: []string{"astronauts"}, Params: []*openapi.Parameter{idempotency}, Body: v2Astronaut{}, Status: http.StatusCreated, Envelope: v2Resource, Data: v2Astronaut{}},
+	"GET " + APIv2Prefix + "/astronauts":                         {Summary: "List astronauts", Tags: []string{"astronauts"}, Params: []*openapi.Parameter{limitParam, offsetParam, noneMatch, modSince}, Envelope: v2List, Data: []v2Astronaut{}},
+	"GET " + APIv2Prefix + "/astronauts/{astronautID}":           {Summary: "Fetch an astronaut, merged IDs redirect with 308", Tags: []string{"astronauts"}, Params: []*openapi.Parameter{noneMatch, modSince}, Envelope: v2Resource, Data: v2Astronaut{}},
+	"PUT " + APIv2Prefix + "/astronauts/{astronautID}":           {Summary: "Replace an astronaut", Tags: []string{"astronauts"}, Params: []*openapi.Parameter{ifMatchParam}, Body: v2Astronaut{}, Envelope: v2Resource, Data: v2Astronaut{}},
+	"PATCH " + APIv2Prefix + "/astronauts/{astronautID}":         {Summary: "Patch an astronaut", Tags: []string{"astronauts"}, Params: []*openapi.Parameter{ifMatchParam}, Bodies: map[string]any{patch.MergePatchContentType: v2Astronaut{}, util.JSONContentType: v2Astronaut{}, patch.JSONPatchContentType: []jsonPatchOperation{}}, Envelope: v2Resource, Data: v2Astronaut{}},
+	"DELETE " + APIv2Prefix + "/astronauts/{astronautID}":        {Summary: "Delete an astronaut", Tags: []string{"astronauts"}, Params: []*openapi.Parameter{ifMatchParam}, Status: http.StatusNoContent},
+	"GET " + APIv2Prefix + "/astronauts/{astronautID}/history":   {Summary: "Change history of an astronaut", Tags: []string{"astronauts"}, Envelope: v2Resource, Data: []v2HistoryEntry{}},
+	"GET " + APIv2Prefix + "/astronauts/{astronautID}/missions":  {Summary: "Missions an astronaut flew", Tags: []string{"astronauts"}, Envelope: v2Resource, Data: []string{}},
+	"GET " + APIv2Prefix + "/astronauts/{astronautID}/crewmates": {Summary: "Astronauts who shared a mission with an astronaut", Tags: []string{"astronauts"}, Envelope: v2Resource, Data: []v2Astronaut{}},
+}
+
+// V2Spec documents the v2 api
+var V2Spec = openapi.Spec{
+	Info:         openapi.Info{Title: apiInfo.Title, Version: "2.0.0", Description: apiInfo.Description},
+	Prefix:       APIv2Prefix,
+	Routes:       v2RouteDocs,
+	Envelope:     v2Document{},
+	Error:        v2Document{},
+	ErrorContent: util.JSONContentType,
+}
+
+// V2Errors makes errors on the v2 router, including those of shared
+// middleware, answer with the v2 envelope
+var V2Errors = util.WithErrorWriter(writeV2Error)
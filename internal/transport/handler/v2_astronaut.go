@@ -0,0 +1,313 @@
+package handler
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+	"github.com/LaQuannT/astronaut-data-api/internal/patch"
+	"github.com/LaQuannT/astronaut-data-api/internal/transport/middleware"
+	"github.com/LaQuannT/astronaut-data-api/internal/transport/util"
+	"github.com/gorilla/mux"
+)
+
+type v2AstronautHandler struct {
+	service model.AstronautUsecase
+	links   linker
+	log     *slog.Logger
+}
+
+func RegisterV2AstronautHandlers(s model.AstronautUsecase, us model.UserUsecase, is model.IdempotencyUsecase, rl model.RateLimitUsecase, ms model.UsageUsecase, r *mux.Router, l *slog.Logger) {
+	handler := &v2AstronautHandler{
+		service: s,
+		links:   newLinker(r, "v2"),
+		log:     l,
+	}
+
+	sr := r.PathPrefix("/astronauts").Subrouter()
+	sr.Use(middleware.APIKeyValidation(us, l), middleware.RateLimit(rl, l), middleware.Metering(ms, l), middleware.Validation, middleware.Idempotency(is, l))
+
+	sr.HandleFunc("", handler.CreateAstronaut).Methods("POST")
+	sr.HandleFunc("", handler.ListAstronauts).Methods("GET").Name(handler.links.name(routeAstronauts))
+	sr.HandleFunc("/{astronautID}", handler.GetAstronaut).Methods("GET").Name(handler.links.name(routeAstronaut))
+	sr.HandleFunc("/{astronautID}", handler.UpdateAstronaut).Methods("PUT")
+	sr.HandleFunc("/{astronautID}", handler.PatchAstronaut).Methods("PATCH")
+	sr.HandleFunc("/{astronautID}", handler.DeleteAstronaut).Methods("DELETE")
+	sr.HandleFunc("/{astronautID}/history", handler.AstronautHistory).Methods("GET").Name(handler.links.name(routeAstronautHistory))
+	sr.HandleFunc("/{astronautID}/missions", handler.AstronautMissions).Methods("GET").Name(handler.links.name(routeAstronautMissions))
+	sr.HandleFunc("/{astronautID}/crewmates", handler.AstronautCrewmates).Methods("GET").Name(handler.links.name(routeAstronautCrewmates))
+}
+
+func (h *v2AstronautHandler) CreateAstronaut(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	in := new(v2Astronaut)
+
+	if err := json.NewDecoder(r.Body).Decode(in); err != nil {
+		util.WriteError(w, r, errInvalidBody)
+		h.log.Warn("error decoding request body to astronaut", slog.Any("error", err))
+		return
+	}
+
+	a, err := h.service.Create(ctx, in.toModel())
+	if err != nil {
+		util.WriteError(w, r, err)
+		h.log.Warn("error creating new astronaut", slog.Any("error", err))
+		return
+	}
+
+	a = h.links.astronaut(a)
+	w.Header().Set("Location", a.Links["self"].Href)
+	util.SetETag(w, a.Version)
+	writeV2(w, http.StatusCreated, &v2Document{Data: toV2Astronaut(a), Links: &v2Links{Self: a.Links["self"].Href}})
+}
+
+func (h *v2AstronautHandler) ListAstronauts(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	limit, offset := pagination(r)
+
+	astronauts, err := h.service.List(ctx, limit, offset)
+	if err != nil {
+		util.WriteError(w, r, err)
+		h.log.Warn("error listing astronauts", slog.Any("error", err))
+		return
+	}
+
+	lastModified, err := h.service.LastModified(ctx)
+	if err != nil {
+		util.WriteError(w, r, err)
+		h.log.Warn("error fetching astronaut modification time", slog.Any("error", err))
+		return
+	}
+
+	total, err := h.service.Count(ctx)
+	if err != nil {
+		util.WriteError(w, r, err)
+		h.log.Warn("error counting astronauts", slog.Any("error", err))
+		return
+	}
+
+	parts := []any{limit, offset, total}
+	for _, a := range astronauts {
+		parts = append(parts, a.ID, a.Version)
+	}
+
+	if util.NotModified(w, r, util.ListETag(util.JSONContentType, parts...), lastModified) {
+		return
+	}
+
+	writeV2(w, http.StatusOK, &v2Document{
+		Data:  toV2Astronauts(h.links.astronauts(astronauts)),
+		Meta:  &v2Meta{Count: len(astronauts), Total: total, Limit: limit, Offset: offset},
+		Links: toV2Links(h.links.pages(r, routeAstronauts, total, limit, offset)),
+	})
+}
+
+func (h *v2AstronautHandler) GetAstronaut(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id, err := strconv.Atoi(mux.Vars(r)["astronautID"])
+	if err != nil {
+		util.WriteError(w, r, errInvalidID)
+		return
+	}
+
+	a, err := h.service.Get(ctx, id)
+	if h.links.redirectMoved(w, r, routeAstronaut, err) {
+		return
+	}
+	if err != nil {
+		util.WriteError(w, r, err)
+		h.log.Warn("error fetching a astronaut", slog.Any("error", err))
+		return
+	}
+
+	if util.NotModified(w, r, util.ETag(a.Version, util.JSONContentType), a.UpdatedAt) {
+		return
+	}
+
+	writeV2(w, http.StatusOK, &v2Document{Data: toV2Astronaut(h.links.astronaut(a)), Links: &v2Links{Self: r.URL.Path}})
+}
+
+func (h *v2AstronautHandler) UpdateAstronaut(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	in := new(v2Astronaut)
+
+	id, err := strconv.Atoi(mux.Vars(r)["astronautID"])
+	if err != nil {
+		util.WriteError(w, r, errInvalidID)
+		return
+	}
+
+	version, err := util.IfMatchVersion(r)
+	if err != nil {
+		util.WriteError(w, r, err)
+		return
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(in); err != nil {
+		util.WriteError(w, r, errInvalidBody)
+		h.log.Warn("error decoding request body to astronaut", slog.Any("error", err))
+		return
+	}
+
+	a := in.toModel()
+	a.ID = id
+	a.Version = version
+
+	a, err = h.service.Update(ctx, a)
+	if err != nil {
+		util.WriteError(w, r, err)
+		h.log.Warn("error updating a astronaut", slog.Any("error", err))
+		return
+	}
+
+	util.SetETag(w, a.Version)
+	writeV2(w, http.StatusOK, &v2Document{Data: toV2Astronaut(h.links.astronaut(a)), Links: &v2Links{Self: r.URL.Path}})
+}
+
+// PatchAstronaut accepts the same patch formats as v1, the patched document
+// uses the shared astronaut field names
+func (h *v2AstronautHandler) PatchAstronaut(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id, err := strconv.Atoi(mux.Vars(r)["astronautID"])
+	if err != nil {
+		util.WriteError(w, r, errInvalidID)
+		return
+	}
+
+	version, err := util.IfMatchVersion(r)
+	if err != nil {
+		util.WriteError(w, r, err)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		util.WriteError(w, r, errInvalidBody)
+		h.log.Warn("error reading patch request body", slog.Any("error", err))
+		return
+	}
+
+	var apply model.PatchFunc
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case patch.MergePatchContentType, util.JSONContentType:
+		apply = func(doc []byte) ([]byte, error) { return patch.Merge(doc, body) }
+	case patch.JSONPatchContentType:
+		apply = func(doc []byte) ([]byte, error) { return patch.JSONPatch(doc, body) }
+	default:
+		util.WriteError(w, r, errUnsupportedPatch)
+		return
+	}
+
+	a, err := h.service.Patch(ctx, id, version, apply)
+	if err != nil {
+		util.WriteError(w, r, err)
+		h.log.Warn("error patching a astronaut", slog.Any("error", err))
+		return
+	}
+
+	util.SetETag(w, a.Version)
+	writeV2(w, http.StatusOK, &v2Document{Data: toV2Astronaut(h.links.astronaut(a)), Links: &v2Links{Self: r.URL.Path}})
+}
+
+func (h *v2AstronautHandler) DeleteAstronaut(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id, err := strconv.Atoi(mux.Vars(r)["astronautID"])
+	if err != nil {
+		util.WriteError(w, r, errInvalidID)
+		return
+	}
+
+	version, err := util.IfMatchVersion(r)
+	if err != nil {
+		util.WriteError(w, r, err)
+		return
+	}
+
+	if err := h.service.Delete(ctx, id, version); err != nil {
+		util.WriteError(w, r, err)
+		h.log.Warn("error deleting an astronaut", slog.Any("error", err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *v2AstronautHandler) AstronautHistory(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id, err := strconv.Atoi(mux.Vars(r)["astronautID"])
+	if err != nil {
+		util.WriteError(w, r, errInvalidID)
+		return
+	}
+
+	history, err := h.service.History(ctx, id)
+	if h.links.redirectMoved(w, r, routeAstronautHistory, err) {
+		return
+	}
+	if err != nil {
+		util.WriteError(w, r, err)
+		h.log.Warn("error fetching astronaut history", slog.Any("error", err))
+		return
+	}
+
+	writeV2(w, http.StatusOK, &v2Document{Data: toV2History(history), Links: &v2Links{Self: r.URL.Path}})
+}
+
+func (h *v2AstronautHandler) AstronautMissions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id, err := strconv.Atoi(mux.Vars(r)["astronautID"])
+	if err != nil {
+		util.WriteError(w, r, errInvalidID)
+		return
+	}
+
+	a, err := h.service.Get(ctx, id)
+	if h.links.redirectMoved(w, r, routeAstronautMissions, err) {
+		return
+	}
+	if err != nil {
+		util.WriteError(w, r, err)
+		h.log.Warn("error fetching astronaut missions", slog.Any("error", err))
+		return
+	}
+
+	missions := a.Missions
+	if missions == nil {
+		missions = []string{}
+	}
+
+	writeV2(w, http.StatusOK, &v2Document{Data: missions, Links: &v2Links{Self: r.URL.Path}})
+}
+
+func (h *v2AstronautHandler) AstronautCrewmates(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id, err := strconv.Atoi(mux.Vars(r)["astronautID"])
+	if err != nil {
+		util.WriteError(w, r, errInvalidID)
+		return
+	}
+
+	crewmates, err := h.service.Crewmates(ctx, id)
+	if h.links.redirectMoved(w, r, routeAstronautCrewmates, err) {
+		return
+	}
+	if err != nil {
+		util.WriteError(w, r, err)
+		h.log.Warn("error fetching astronaut crewmates", slog.Any("error", err))
+		return
+	}
+
+	writeV2(w, http.StatusOK, &v2Document{Data: toV2Astronauts(h.links.astronauts(crewmates)), Links: &v2Links{Self: r.URL.Path}})
+}
@@ -0,0 +1,300 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+	"github.com/LaQuannT/astronaut-data-api/internal/transport/middleware"
+	"github.com/LaQuannT/astronaut-data-api/internal/transport/util"
+	"github.com/gorilla/mux"
+)
+
+type v2UserHandler struct {
+	service  model.UserUsecase
+	accounts model.AccountUsecase
+	links    linker
+	log      *slog.Logger
+}
+
+func RegisterV2UserHandlers(s model.UserUsecase, as model.AccountUsecase, rl model.RateLimitUsecase, ms model.UsageUsecase, r *mux.Router, l *slog.Logger) {
+	handler := &v2UserHandler{
+		service:  s,
+		accounts: as,
+		links:    newLinker(r, "v2"),
+		log:      l,
+	}
+
+	sr := r.PathPrefix("/users").Subrouter()
+	sr.Use(middleware.APIKeyValidation(s, l), middleware.RateLimit(rl, l), middleware.Metering(ms, l), middleware.Validation)
+
+	// sign ups are not idempotent, a replay would show the API key again, and
+	// are limited by client IP
+	r.Handle("/users", middleware.RateLimit(rl, l)(middleware.Validation(http.HandlerFunc(handler.CreateUser)))).Methods("POST")
+	sr.HandleFunc("", handler.ListUsers).Methods("GET").Name(handler.links.name(routeUsers))
+	sr.HandleFunc("/{userID}", handler.GetUser).Methods("GET").Name(handler.links.name(routeUser))
+	sr.HandleFunc("/{userID}", handler.UpdateUser).Methods("PUT")
+	sr.HandleFunc("/{userID}", handler.DeleteUser).Methods("DELETE")
+	sr.HandleFunc("/{userID}/password", handler.PasswordReset).Methods("PUT").Name(handler.links.name(routeUserPassword))
+	sr.HandleFunc("/{userID}/apikey", handler.APIKeyReset).Methods("POST").Name(handler.links.name(routeUserAPIKey))
+	sr.HandleFunc("/{userID}/apikeys", handler.CreateAPIKey).Methods("POST")
+	sr.HandleFunc("/{userID}/apikeys", handler.ListAPIKeys).Methods("GET").Name(handler.links.name(routeUserAPIKeys))
+	sr.HandleFunc("/{userID}/apikeys/{keyID}", handler.RevokeAPIKey).Methods("DELETE")
+}
+
+func (h *v2UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	in := new(v2UserInput)
+
+	if err := json.NewDecoder(r.Body).Decode(in); err != nil {
+		util.WriteError(w, r, errInvalidBody)
+		h.log.Warn("error decoding json request body", slog.Any("error", err))
+		return
+	}
+
+	u, err := h.service.Create(ctx, in.toModel())
+	if err != nil {
+		util.WriteError(w, r, err)
+		h.log.Warn("error creating new user", slog.Any("error", err))
+		return
+	}
+
+	// the account exists either way, the user can ask for the mail again
+	if h.accounts != nil {
+		if err := h.accounts.SendVerification(ctx, u); err != nil {
+			h.log.Warn("error sending verification email", slog.Any("error", err))
+		}
+	}
+
+	u = h.links.user(u)
+	w.Header().Set("Location", u.Links["self"].Href)
+	util.SetETag(w, u.Version)
+	writeV2(w, http.StatusCreated, &v2Document{Data: toV2User(u), Links: &v2Links{Self: u.Links["self"].Href}})
+}
+
+func (h *v2UserHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	limit, offset := pagination(r)
+
+	users, err := h.service.List(ctx, limit, offset)
+	if err != nil {
+		util.WriteError(w, r, err)
+		h.log.Warn("error listing users", slog.Any("error", err))
+		return
+	}
+
+	total, err := h.service.Count(ctx)
+	if err != nil {
+		util.WriteError(w, r, err)
+		h.log.Warn("error counting users", slog.Any("error", err))
+		return
+	}
+
+	writeV2(w, http.StatusOK, &v2Document{
+		Data:  toV2Users(h.links.users(users)),
+		Meta:  &v2Meta{Count: len(users), Total: total, Limit: limit, Offset: offset},
+		Links: toV2Links(h.links.pages(r, routeUsers, total, limit, offset)),
+	})
+}
+
+func (h *v2UserHandler) GetUser(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id, err := strconv.Atoi(mux.Vars(r)["userID"])
+	if err != nil {
+		util.WriteError(w, r, errInvalidID)
+		return
+	}
+
+	u, err := h.service.Get(ctx, id)
+	if err != nil {
+		util.WriteError(w, r, err)
+		h.log.Warn("error fetching a user", slog.Any("error", err))
+		return
+	}
+
+	util.SetETag(w, u.Version)
+	writeV2(w, http.StatusOK, &v2Document{Data: toV2User(h.links.user(u)), Links: &v2Links{Self: r.URL.Path}})
+}
+
+func (h *v2UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	in := new(v2UserInput)
+
+	id, err := strconv.Atoi(mux.Vars(r)["userID"])
+	if err != nil {
+		util.WriteError(w, r, errInvalidID)
+		return
+	}
+
+	version, err := util.IfMatchVersion(r)
+	if err != nil {
+		util.WriteError(w, r, err)
+		return
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(in); err != nil {
+		util.WriteError(w, r, errInvalidBody)
+		h.log.Warn("error decoding json request body", slog.Any("error", err))
+		return
+	}
+
+	u := in.toModel()
+	u.ID = id
+	u.Version = version
+
+	u, err = h.service.Update(ctx, u)
+	if err != nil {
+		util.WriteError(w, r, err)
+		h.log.Warn("error updating a user", slog.Any("error", err))
+		return
+	}
+
+	util.SetETag(w, u.Version)
+	writeV2(w, http.StatusOK, &v2Document{Data: toV2User(h.links.user(u)), Links: &v2Links{Self: r.URL.Path}})
+}
+
+func (h *v2UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id, err := strconv.Atoi(mux.Vars(r)["userID"])
+	if err != nil {
+		util.WriteError(w, r, errInvalidID)
+		return
+	}
+
+	version, err := util.IfMatchVersion(r)
+	if err != nil {
+		util.WriteError(w, r, err)
+		return
+	}
+
+	if err := h.service.Delete(ctx, id, version); err != nil {
+		util.WriteError(w, r, err)
+		h.log.Warn("error deleting a user", slog.Any("error", err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *v2UserHandler) PasswordReset(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	in := new(v2PasswordInput)
+
+	id, err := strconv.Atoi(mux.Vars(r)["userID"])
+	if err != nil {
+		util.WriteError(w, r, errInvalidID)
+		return
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(in); err != nil {
+		util.WriteError(w, r, errInvalidBody)
+		h.log.Warn("error decoding json request body", slog.Any("error", err))
+		return
+	}
+
+	if err := h.service.ResetPassword(ctx, &model.User{ID: id, Password: in.Password}); err != nil {
+		util.WriteError(w, r, err)
+		h.log.Warn("error reseting user password", slog.Any("error", err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *v2UserHandler) APIKeyReset(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id, err := strconv.Atoi(mux.Vars(r)["userID"])
+	if err != nil {
+		util.WriteError(w, r, errInvalidID)
+		return
+	}
+
+	u, err := h.service.GenerateNewAPIKey(ctx, id)
+	if err != nil {
+		util.WriteError(w, r, err)
+		h.log.Warn("error resetting API key", slog.Any("error", err))
+		return
+	}
+
+	writeV2(w, http.StatusOK, &v2Document{Data: toV2User(h.links.user(u)), Links: &v2Links{Self: r.URL.Path}})
+}
+
+func (h *v2UserHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	in := new(apiKeyInput)
+
+	id, err := strconv.Atoi(mux.Vars(r)["userID"])
+	if err != nil {
+		util.WriteError(w, r, errInvalidID)
+		return
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(in); err != nil {
+		util.WriteError(w, r, errInvalidBody)
+		h.log.Warn("error decoding json request body", slog.Any("error", err))
+		return
+	}
+
+	k, err := h.service.CreateAPIKey(ctx, id, in.toModel())
+	if err != nil {
+		util.WriteError(w, r, err)
+		h.log.Warn("error creating API key", slog.Any("error", err))
+		return
+	}
+
+	writeV2(w, http.StatusCreated, &v2Document{Data: toV2APIKey(k), Links: &v2Links{Self: r.URL.Path}})
+}
+
+func (h *v2UserHandler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id, err := strconv.Atoi(mux.Vars(r)["userID"])
+	if err != nil {
+		util.WriteError(w, r, errInvalidID)
+		return
+	}
+
+	keys, err := h.service.ListAPIKeys(ctx, id)
+	if err != nil {
+		util.WriteError(w, r, err)
+		h.log.Warn("error listing API keys", slog.Any("error", err))
+		return
+	}
+
+	data := make([]*v2APIKey, 0, len(keys))
+	for _, k := range keys {
+		data = append(data, toV2APIKey(k))
+	}
+
+	writeV2(w, http.StatusOK, &v2Document{Data: data, Links: &v2Links{Self: r.URL.Path}})
+}
+
+func (h *v2UserHandler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	vars := mux.Vars(r)
+
+	id, err := strconv.Atoi(vars["userID"])
+	if err != nil {
+		util.WriteError(w, r, errInvalidID)
+		return
+	}
+
+	keyID, err := strconv.Atoi(vars["keyID"])
+	if err != nil {
+		util.WriteError(w, r, errInvalidID)
+		return
+	}
+
+	if err := h.service.RevokeAPIKey(ctx, id, keyID); err != nil {
+		util.WriteError(w, r, err)
+		h.log.Warn("error revoking API key", slog.Any("error", err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
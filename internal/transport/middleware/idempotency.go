@@ -0,0 +1,132 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+	"github.com/LaQuannT/astronaut-data-api/internal/transport/util"
+)
+
+const (
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader is set on responses replayed from a stored key
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
+)
+
+// replayedHeaders are stored with a response, other headers describe the
+// connection rather than the result
+var replayedHeaders = []string{"Content-Type", "Location", "ETag", "Last-Modified"}
+
+var errInvalidIdempotencyKey = model.NewError(model.KindInvalid, "invalid_idempotency_key", "idempotency key must be between 1 and 255 characters")
+
+// Idempotency replays the stored response to a POST retried with the same
+// Idempotency-Key. Keys are scoped to the request user, run it after
+// APIKeyValidation. Server errors are not stored so the retry runs again.
+// A nil usecase disables it.
+func Idempotency(uc model.IdempotencyUsecase, log *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			key, ok := r.Header[IdempotencyKeyHeader]
+			if uc == nil || r.Method != http.MethodPost || !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if len(key[0]) == 0 || len(key[0]) > maxIdempotencyKeyLength {
+				util.WriteError(w, r, errInvalidIdempotencyKey)
+				return
+			}
+
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				util.WriteError(w, r, model.WrapError(model.KindInvalid, "invalid_body", "request body could not be read", err))
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			rec := &model.IdempotencyRecord{Key: key[0], RequestHash: requestHash(r, body)}
+			if u, ok := r.Context().Value(RequestUser).(*model.User); ok {
+				rec.UserID = u.ID
+			}
+
+			stored, err := uc.Begin(r.Context(), rec)
+			if err != nil {
+				log.Warn("idempotency key rejected", slog.Any("error", err))
+				util.WriteError(w, r, err)
+				return
+			}
+
+			if stored != nil {
+				for k, v := range stored.Headers {
+					w.Header().Set(k, v)
+				}
+				w.Header().Set(IdempotentReplayedHeader, "true")
+				w.WriteHeader(stored.Status)
+				w.Write(stored.Body)
+				return
+			}
+
+			rw := &recordingWriter{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rw, r)
+
+			// the request may have been cancelled, storing the outcome should not be
+			ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), 5*time.Second)
+			defer cancel()
+
+			if rw.status >= http.StatusInternalServerError {
+				if err := uc.Release(ctx, rec.UserID, rec.Key); err != nil {
+					log.Error("unable to release idempotency key", slog.Any("error", err))
+				}
+				return
+			}
+
+			rec.Status = rw.status
+			rec.Body = rw.body.Bytes()
+			rec.Headers = make(map[string]string)
+			for _, h := range replayedHeaders {
+				if v := w.Header().Get(h); v != "" {
+					rec.Headers[h] = v
+				}
+			}
+
+			if err := uc.Complete(ctx, rec); err != nil {
+				log.Error("unable to store idempotent response", slog.Any("error", err))
+			}
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// requestHash fingerprints what a retry has to repeat exactly
+func requestHash(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method+" "+r.URL.RequestURI()+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// recordingWriter passes a response through while keeping a copy of it
+type recordingWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rw *recordingWriter) WriteHeader(code int) {
+	rw.status = code
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *recordingWriter) Write(b []byte) (int, error) {
+	rw.body.Write(b)
+	return rw.ResponseWriter.Write(b)
+}
@@ -0,0 +1,89 @@
+package middleware_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+	usecase "github.com/LaQuannT/astronaut-data-api/internal/service"
+	"github.com/LaQuannT/astronaut-data-api/internal/transport/middleware"
+)
+
+type memoryIdempotencyStore struct {
+	mu      sync.Mutex
+	records map[string]*model.IdempotencyRecord
+}
+
+func (s *memoryIdempotencyStore) Claim(_ context.Context, rec *model.IdempotencyRecord, expiry time.Time) (*model.IdempotencyRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing, ok := s.records[rec.Key]; ok && !existing.CreatedAt.Before(expiry) {
+		return existing, nil
+	}
+	rec.CreatedAt = time.Now().UTC()
+	s.records[rec.Key] = rec
+	return nil, nil
+}
+
+func (s *memoryIdempotencyStore) Complete(context.Context, *model.IdempotencyRecord) error {
+	return nil
+}
+
+func (s *memoryIdempotencyStore) Release(_ context.Context, _ int, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.records, key)
+	return nil
+}
+
+func (s *memoryIdempotencyStore) DeleteExpired(context.Context, time.Time) (int64, error) {
+	return 0, nil
+}
+
+func TestIdempotency(t *testing.T) {
+	store := &memoryIdempotencyStore{records: make(map[string]*model.IdempotencyRecord)}
+	uc := usecase.NewIdempotencyUsecase(store, time.Hour)
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	calls := 0
+	h := middleware.Idempotency(uc, log)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Location", "/astronauts/1")
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, `{"id":1}`)
+	}))
+
+	send := func(body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/astronauts", strings.NewReader(body))
+		r.Header.Set(middleware.IdempotencyKeyHeader, "retry-1")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	first := send(`{"name":"a"}`)
+	retry := send(`{"name":"a"}`)
+
+	if calls != 1 {
+		t.Errorf("handler ran %d times, want 1", calls)
+	}
+	if retry.Code != http.StatusCreated || retry.Body.String() != first.Body.String() {
+		t.Errorf("retry = %d %q, want %d %q", retry.Code, retry.Body, first.Code, first.Body)
+	}
+	if retry.Header().Get("Location") != "/astronauts/1" || retry.Header().Get(middleware.IdempotentReplayedHeader) != "true" {
+		t.Errorf("retry headers = %v", retry.Header())
+	}
+
+	if w := send(`{"name":"b"}`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("different body status = %d, want %d", w.Code, http.StatusUnprocessableEntity)
+	}
+}
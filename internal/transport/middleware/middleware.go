@@ -3,8 +3,11 @@ package middleware
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/LaQuannT/astronaut-data-api/internal/model"
@@ -13,13 +16,23 @@ import (
 )
 
 type (
-	apikeyHeader string
-	requestUser  string
+	apikeyHeader  string
+	requestUser   string
+	requestScopes string
+	requestAPIKey string
+	requestIP     string
 )
 
 const (
 	APIKeyHeader apikeyHeader = "X-api-key"
 	RequestUser  requestUser  = "request-user"
+	// RequestScopes holds the []string scopes of the request's credential
+	RequestScopes requestScopes = "request-scopes"
+	// RequestAPIKey holds the *model.APIKey a request was authenticated
+	// with, it is unset for bearer tokens
+	RequestAPIKey requestAPIKey = "request-api-key"
+	// RequestIP holds the client IP set by ClientIP
+	RequestIP requestIP = "request-ip"
 )
 
 type responseWriter struct {
@@ -58,40 +71,72 @@ func HTTPLogger(log *slog.Logger) func(http.Handler) http.Handler {
 	}
 }
 
+// ClientIP sets the request's client IP, the address of the connection's
+// peer. Forwarding headers are ignored since any client can set them.
+func ClientIP(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), RequestIP, clientIP(r))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// errInvalidAPIKey is returned for a missing, malformed or unknown API key
+var errInvalidAPIKey = model.NewError(model.KindUnauthenticated, "invalid_api_key", "a valid API key is required")
+
+// APIKeyValidation requires an API key unless BearerAuthentication already
+// authenticated the request
 func APIKeyValidation(uc model.UserUsecase, log *slog.Logger) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		fn := func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := r.Context().Value(RequestUser).(*model.User); ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
 			key := r.Header.Get(string(APIKeyHeader))
 
 			if key == "" {
-				err := errors.New("invalid APIKey")
-				log.Warn("failed APIKey validation", slog.Any("error", err))
-				util.WriteJSON(w, http.StatusUnauthorized, model.JSONResponse{Error: "Permission denied"})
+				log.Warn("failed APIKey validation", slog.String("error", "missing APIKey"))
+				util.WriteError(w, r, errInvalidAPIKey)
 				return
 			}
 
 			_, err := uuid.Parse(key)
 			if err != nil {
 				log.Warn("faild APIKey validation", slog.Any("error", err))
-				util.WriteJSON(w, http.StatusUnauthorized, model.JSONResponse{Error: "Permission denied"})
+				util.WriteError(w, r, errInvalidAPIKey)
 				return
 			}
 
-			ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
-			defer cancel()
-
-			u, err := uc.SearchAPIKey(ctx, key)
+			// the timeout only bounds the lookup, handlers set their own
+			searchCtx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+			k, err := uc.SearchAPIKey(searchCtx, key)
+			cancel()
 			if err != nil {
 				log.Warn("failed APIKey validation user search", slog.Any("error", err))
-				util.WriteJSON(w, http.StatusUnauthorized, model.JSONResponse{Error: "Permission denied"})
+				if errors.Is(err, model.ErrNotFound) {
+					err = errInvalidAPIKey
+				}
+				util.WriteError(w, r, err)
 				return
 			}
 
-			if u == nil {
-				util.WriteJSON(w, http.StatusUnauthorized, model.JSONResponse{Error: "Permission denied"})
+			if k == nil || k.User == nil {
+				util.WriteError(w, r, errInvalidAPIKey)
 				return
 			} else {
-				ctx = context.WithValue(ctx, RequestUser, u)
+				ctx := context.WithValue(r.Context(), RequestUser, k.User)
+				ctx = context.WithValue(ctx, RequestScopes, k.Scopes)
+				ctx = context.WithValue(ctx, RequestAPIKey, k)
 				r = r.WithContext(ctx)
 			}
 			next.ServeHTTP(w, r)
@@ -99,3 +144,55 @@ func APIKeyValidation(uc model.UserUsecase, log *slog.Logger) func(http.Handler)
 		return http.HandlerFunc(fn)
 	}
 }
+
+// errInvalidAuthorization is returned for an Authorization header that does
+// not hold a bearer token
+var errInvalidAuthorization = model.NewError(model.KindUnauthenticated, "invalid_authorization", "authorization header must hold a bearer token")
+
+// BearerAuthentication sets the request user from an `Authorization: Bearer`
+// access token. Requests without the header are passed on for
+// APIKeyValidation, a nil uc disables bearer tokens.
+func BearerAuthentication(uc model.AuthUsecase, log *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			header := r.Header.Get("Authorization")
+			if uc == nil || header == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			scheme, token, ok := strings.Cut(header, " ")
+			if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+				util.WriteError(w, r, errInvalidAuthorization)
+				return
+			}
+
+			u, err := uc.Authenticate(r.Context(), strings.TrimSpace(token))
+			if err != nil {
+				log.Warn("failed bearer token authentication", slog.Any("error", err))
+				util.WriteError(w, r, err)
+				return
+			}
+
+			// bearer tokens act with every permission of the user's role
+			ctx := context.WithValue(r.Context(), RequestUser, u)
+			ctx = context.WithValue(ctx, RequestScopes, u.Permissions)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// Deprecated marks every response as coming from a deprecated api that stops
+// being served at sunset, pointing clients at its successor
+func Deprecated(deprecation, sunset time.Time, successor string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Deprecation", fmt.Sprintf("@%d", deprecation.Unix()))
+			w.Header().Set("Sunset", sunset.UTC().Format(http.TimeFormat))
+			w.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"successor-version\"", successor))
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
@@ -0,0 +1,53 @@
+package middleware_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+	"github.com/LaQuannT/astronaut-data-api/internal/transport/middleware"
+)
+
+const testAPIKey = "0b9a4a3e-2f4c-4c5e-9a63-5a8f1c3d7e21"
+
+// keyUsers knows the single test API key
+type keyUsers struct {
+	model.UserUsecase
+}
+
+func (keyUsers) SearchAPIKey(ctx context.Context, key string) (*model.APIKey, error) {
+	if key != testAPIKey {
+		return nil, model.ErrNotFound
+	}
+	return &model.APIKey{User: &model.User{ID: 1, Role: "user"}}, nil
+}
+
+func TestAPIKeyValidationDeadline(t *testing.T) {
+	var (
+		user        *model.User
+		hasDeadline bool
+	)
+	h := middleware.APIKeyValidation(keyUsers{}, slog.New(slog.NewTextHandler(io.Discard, nil)))(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, _ = r.Context().Value(middleware.RequestUser).(*model.User)
+			_, hasDeadline = r.Context().Deadline()
+		}))
+
+	req := httptest.NewRequest(http.MethodGet, "/astronauts", nil)
+	req.Header.Set(string(middleware.APIKeyHeader), testAPIKey)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK || user == nil || user.ID != 1 {
+		t.Fatalf("expected the key's user to reach the handler, got status %d and %+v", w.Code, user)
+	}
+	// bulk endpoints take longer than the key lookup is allowed
+	if hasDeadline {
+		t.Error("expected the handler to get no deadline from the key lookup")
+	}
+}
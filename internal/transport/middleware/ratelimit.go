@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+	"github.com/LaQuannT/astronaut-data-api/internal/transport/util"
+)
+
+var errRateLimited = model.NewError(model.KindTooManyRequests, "rate_limited", "too many requests, retry later")
+
+// RateLimit counts requests against a bucket per API key, per user for bearer
+// tokens or per client IP for requests without credentials, so run it after
+// APIKeyValidation. Store errors let the request through. A nil usecase
+// disables it.
+func RateLimit(uc model.RateLimitUsecase, log *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if uc == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key, role := rateLimitKey(r)
+
+			res, err := uc.Allow(r.Context(), key, role)
+			if err != nil {
+				log.Error("unable to rate limit request", slog.Any("error", err))
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+
+			if !res.Allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(res.RetryAfter.Seconds()))))
+				log.Warn("rate limited request", slog.String("key", key))
+				util.WriteError(w, r, errRateLimited)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+func rateLimitKey(r *http.Request) (string, string) {
+	u, ok := r.Context().Value(RequestUser).(*model.User)
+	if !ok {
+		return "ip:" + clientIP(r), ""
+	}
+
+	if k, ok := r.Context().Value(RequestAPIKey).(*model.APIKey); ok {
+		return "key:" + strconv.Itoa(k.ID), u.Role
+	}
+	return "user:" + strconv.Itoa(u.ID), u.Role
+}
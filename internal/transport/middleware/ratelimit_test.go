@@ -0,0 +1,73 @@
+package middleware_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+	usecase "github.com/LaQuannT/astronaut-data-api/internal/service"
+	"github.com/LaQuannT/astronaut-data-api/internal/store"
+	"github.com/LaQuannT/astronaut-data-api/internal/transport/middleware"
+)
+
+func TestRateLimit(t *testing.T) {
+	uc := usecase.NewRateLimitUsecase(store.NewMemoryRateLimitStore(), model.RateLimits{
+		Default:   model.RateLimit{Requests: 3, Per: time.Minute},
+		Anonymous: model.RateLimit{Requests: 2, Per: time.Minute},
+		Roles:     map[string]model.RateLimit{"admin": {Requests: 5, Per: time.Minute}},
+	})
+	h := middleware.RateLimit(uc, slog.New(slog.NewTextHandler(io.Discard, nil)))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	send := func(addr string, u *model.User) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/astronauts", nil)
+		r.RemoteAddr = addr
+		if u != nil {
+			r = r.WithContext(context.WithValue(r.Context(), middleware.RequestUser, u))
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	tests := []struct {
+		name    string
+		addr    string
+		user    *model.User
+		allowed int
+	}{
+		{"anonymous by ip", "192.0.2.1:1234", nil, 2},
+		{"other ip", "192.0.2.2:1234", nil, 2},
+		{"role without a limit", "192.0.2.1:1234", &model.User{ID: 1, Role: "user"}, 3},
+		{"role with a limit", "192.0.2.1:1234", &model.User{ID: 2, Role: "admin"}, 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < tt.allowed; i++ {
+				w := send(tt.addr, tt.user)
+				if w.Code != http.StatusNoContent {
+					t.Fatalf("request %d = %d, want %d", i+1, w.Code, http.StatusNoContent)
+				}
+				if got, want := w.Header().Get("RateLimit-Remaining"), tt.allowed-i-1; got != strconv.Itoa(want) {
+					t.Errorf("request %d RateLimit-Remaining = %s, want %d", i+1, got, want)
+				}
+			}
+
+			w := send(tt.addr, tt.user)
+			if w.Code != http.StatusTooManyRequests {
+				t.Fatalf("request over the limit = %d, want %d", w.Code, http.StatusTooManyRequests)
+			}
+			if w.Header().Get("RateLimit-Limit") != strconv.Itoa(tt.allowed) || w.Header().Get("Retry-After") == "" {
+				t.Errorf("headers = %v, want limit %d and Retry-After", w.Header(), tt.allowed)
+			}
+		})
+	}
+}
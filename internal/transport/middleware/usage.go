@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+	"github.com/LaQuannT/astronaut-data-api/internal/transport/util"
+	"github.com/gorilla/mux"
+)
+
+var errQuotaExceeded = model.NewError(model.KindTooManyRequests, "quota_exceeded", "monthly request quota is used up")
+
+// countingWriter counts the bytes of the response body
+type countingWriter struct {
+	http.ResponseWriter
+	bytes int64
+}
+
+func (w *countingWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Metering rejects requests of users whose monthly quota is used up and
+// counts the others per endpoint with the bytes served, so run it after
+// APIKeyValidation. Requests without a user are not metered and quota
+// lookup errors let the request through. A nil usecase disables it.
+func Metering(uc model.UsageUsecase, log *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			u, ok := r.Context().Value(RequestUser).(*model.User)
+			if uc == nil || !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			q, err := uc.Quota(r.Context(), u)
+			if err != nil {
+				log.Error("unable to check request quota", slog.Any("error", err))
+			} else if q.Exhausted() {
+				w.Header().Set("Retry-After", strconv.Itoa(int(time.Until(q.ResetsAt).Seconds())+1))
+				log.Warn("request quota exceeded", slog.Int("userId", u.ID))
+				util.WriteError(w, r, errQuotaExceeded)
+				return
+			}
+
+			cw := &countingWriter{ResponseWriter: w}
+			next.ServeHTTP(cw, r)
+
+			uc.Record(u.ID, endpoint(r), cw.bytes)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// endpoint names the route of r by its template, so requests for different
+// records count towards the same endpoint
+func endpoint(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if tpl, err := route.GetPathTemplate(); err == nil {
+			return r.Method + " " + tpl
+		}
+	}
+	return r.Method + " " + r.URL.Path
+}
@@ -0,0 +1,86 @@
+package middleware_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+	usecase "github.com/LaQuannT/astronaut-data-api/internal/service"
+	"github.com/LaQuannT/astronaut-data-api/internal/transport/middleware"
+	"github.com/gorilla/mux"
+)
+
+type memoryUsageStore struct {
+	records []*model.UsageRecord
+}
+
+func (s *memoryUsageStore) Add(_ context.Context, records []*model.UsageRecord) error {
+	s.records = append(s.records, records...)
+	return nil
+}
+
+func (s *memoryUsageStore) List(context.Context, int, time.Time, time.Time) ([]*model.UsageRecord, error) {
+	return s.records, nil
+}
+
+func (s *memoryUsageStore) Report(context.Context, time.Time, time.Time) ([]*model.UsageTotal, error) {
+	return nil, nil
+}
+
+func (s *memoryUsageStore) Requests(_ context.Context, userID int, _ time.Time) (int64, error) {
+	var n int64
+	for _, rec := range s.records {
+		if rec.UserID == userID {
+			n += rec.Requests
+		}
+	}
+	return n, nil
+}
+
+func TestMetering(t *testing.T) {
+	store := &memoryUsageStore{}
+	uc := usecase.NewUsageUsecase(store, nil, model.Quotas{Roles: map[string]int64{"user": 2}})
+
+	r := mux.NewRouter()
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), middleware.RequestUser, &model.User{ID: 1, Role: "user"})
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}, middleware.Metering(uc, slog.New(slog.NewTextHandler(io.Discard, nil))))
+	r.HandleFunc("/astronauts/{astronautID}", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "12345")
+	})
+
+	send := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	for _, path := range []string{"/astronauts/1", "/astronauts/2"} {
+		if w := send(path); w.Code != http.StatusOK {
+			t.Fatalf("GET %s = %d, want %d", path, w.Code, http.StatusOK)
+		}
+	}
+
+	w := send("/astronauts/3")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("request over quota = %d with Retry-After %q, want %d", w.Code, w.Header().Get("Retry-After"), http.StatusTooManyRequests)
+	}
+
+	if err := uc.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.records) != 1 {
+		t.Fatalf("flushed %d records, want 1 per endpoint", len(store.records))
+	}
+	if rec := store.records[0]; rec.Endpoint != "GET /astronauts/{astronautID}" || rec.Requests != 2 || rec.Bytes != 10 {
+		t.Errorf("record = %+v, want 2 requests and 10 bytes of the route template", rec)
+	}
+}
@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+)
+
+type requestValidator string
+
+// RequestValidator holds the schema validation middleware set by Validator
+const RequestValidator requestValidator = "request-validator"
+
+// Validator hands validate to Validation instead of running it, so a route
+// is authenticated, rate limited and metered before its body is checked
+func Validator(validate func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), RequestValidator, validate)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// Validation checks the request against the api schema, run it after the
+// middlewares that reject requests without reading them. Without a
+// Validator the request is not checked.
+func Validation(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		validate, ok := r.Context().Value(RequestValidator).(func(http.Handler) http.Handler)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		validate(next).ServeHTTP(w, r)
+	}
+	return http.HandlerFunc(fn)
+}
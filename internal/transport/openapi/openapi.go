@@ -0,0 +1,503 @@
+// Package openapi builds an OpenAPI 3.1 document from the routes registered
+// on a router and a table describing each of them
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	Version        = "3.1.0"
+	APIKeyScheme   = "apiKey"
+	BearerScheme   = "bearer"
+	problemContent = "application/problem+json"
+	jsonContent    = "application/json"
+)
+
+type (
+	Document struct {
+		OpenAPI    string                `json:"openapi"`
+		Info       Info                  `json:"info"`
+		Servers    []Server              `json:"servers"`
+		Security   []map[string][]string `json:"security"`
+		Paths      map[string]PathItem   `json:"paths"`
+		Components Components            `json:"components"`
+	}
+
+	Info struct {
+		Title       string `json:"title"`
+		Version     string `json:"version"`
+		Description string `json:"description,omitempty"`
+	}
+
+	Server struct {
+		URL string `json:"url"`
+	}
+
+	// PathItem maps lower case http methods to operations
+	PathItem map[string]*Operation
+
+	Operation struct {
+		OperationID string                `json:"operationId"`
+		Summary     string                `json:"summary"`
+		Tags        []string              `json:"tags,omitempty"`
+		Security    []map[string][]string `json:"security,omitempty"`
+		Parameters  []*Parameter          `json:"parameters,omitempty"`
+		RequestBody *RequestBody          `json:"requestBody,omitempty"`
+		Responses   map[string]*Response  `json:"responses"`
+	}
+
+	Parameter struct {
+		Name        string  `json:"name"`
+		In          string  `json:"in"`
+		Description string  `json:"description,omitempty"`
+		Required    bool    `json:"required,omitempty"`
+		Schema      *Schema `json:"schema"`
+	}
+
+	RequestBody struct {
+		Required bool                  `json:"required"`
+		Content  map[string]*MediaType `json:"content"`
+	}
+
+	Response struct {
+		Description string                `json:"description"`
+		Content     map[string]*MediaType `json:"content,omitempty"`
+	}
+
+	MediaType struct {
+		Schema *Schema `json:"schema"`
+	}
+
+	Components struct {
+		Schemas         map[string]*Schema         `json:"schemas"`
+		SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes"`
+	}
+
+	SecurityScheme struct {
+		Type         string `json:"type"`
+		In           string `json:"in,omitempty"`
+		Name         string `json:"name,omitempty"`
+		Scheme       string `json:"scheme,omitempty"`
+		BearerFormat string `json:"bearerFormat,omitempty"`
+		Description  string `json:"description,omitempty"`
+	}
+
+	// Schema is the JSON Schema subset the api needs. AdditionalProperties
+	// is either a *Schema or false, Nullable also accepts json null.
+	Schema struct {
+		Ref                  string             `json:"$ref,omitempty"`
+		Type                 string             `json:"type,omitempty"`
+		Format               string             `json:"format,omitempty"`
+		Description          string             `json:"description,omitempty"`
+		Enum                 []string           `json:"enum,omitempty"`
+		Items                *Schema            `json:"items,omitempty"`
+		Properties           map[string]*Schema `json:"properties,omitempty"`
+		AdditionalProperties any                `json:"additionalProperties,omitempty"`
+		Nullable             bool               `json:"-"`
+	}
+)
+
+// MarshalJSON writes nullable schemas the JSON Schema 2020-12 way, as a type
+// list or an anyOf around a reference
+func (s *Schema) MarshalJSON() ([]byte, error) {
+	type schema Schema
+
+	if !s.Nullable || (s.Ref == "" && s.Type == "") {
+		return json.Marshal((*schema)(s))
+	}
+
+	if s.Ref != "" {
+		return json.Marshal(map[string]any{"anyOf": []any{map[string]string{"$ref": s.Ref}, map[string]string{"type": "null"}}})
+	}
+
+	return json.Marshal(struct {
+		*schema
+		Type []string `json:"type"`
+	}{(*schema)(s), []string{s.Type, "null"}})
+}
+
+// Route documents one method and path template. Body, Bodies, Response and
+// Alternates are sample values whose types are reflected into schemas,
+// Bodies and Alternates are keyed by content type and Body is a json request
+// body. Envelope names the Spec.Envelope fields a json response holds and
+// Data describes its "data" member. Alternates describe produced types
+// shaped differently. Also lists further statuses answered with the same
+// body as Status.
+type Route struct {
+	Summary    string
+	Tags       []string
+	Public     bool
+	Params     []*Parameter
+	Body       any
+	Bodies     map[string]any
+	Status     int
+	Also       []int
+	Envelope   []string
+	Data       any
+	Response   any
+	Produces   []string
+	Alternates map[string]any
+}
+
+// Query and Header describe optional request parameters
+func Query(name, description string, schema *Schema) *Parameter {
+	return &Parameter{Name: name, In: "query", Description: description, Schema: schema}
+}
+
+func Header(name, description string, required bool) *Parameter {
+	return &Parameter{Name: name, In: "header", Description: description, Required: required, Schema: &Schema{Type: "string"}}
+}
+
+// Spec describes the routes of one api version. Envelope is the response
+// type whose json fields Route.Envelope picks. Error is the error body type
+// served as ErrorContent, RFC 7807 problem details when nil.
+type Spec struct {
+	Info         Info
+	Prefix       string
+	Routes       map[string]Route
+	Envelope     any
+	Error        any
+	ErrorContent string
+}
+
+var pathParam = regexp.MustCompile(`\{([^}:]+)(:[^}]*)?\}`)
+
+// Build walks the routes on r below the spec's prefix and documents them
+// from its table, keyed by "METHOD template". Routes missing from the
+// table, and table entries with no matching route, are returned in the
+// error alongside the document.
+func Build(r *mux.Router, spec Spec) (*Document, error) {
+	g := &generator{schemas: make(map[string]*Schema), envelopeType: reflect.TypeOf(spec.Envelope)}
+
+	prefix, routes := spec.Prefix, spec.Routes
+
+	doc := &Document{
+		OpenAPI:  Version,
+		Info:     spec.Info,
+		Servers:  []Server{{URL: prefix}},
+		Security: []map[string][]string{{APIKeyScheme: {}}, {BearerScheme: {}}},
+		Paths:    make(map[string]PathItem),
+		Components: Components{
+			Schemas: g.schemas,
+			SecuritySchemes: map[string]*SecurityScheme{
+				APIKeyScheme: {Type: "apiKey", In: "header", Name: "X-api-key", Description: "API key issued when a user signs up"},
+				BearerScheme: {Type: "http", Scheme: "bearer", BearerFormat: "JWT", Description: "access token issued by the login and refresh endpoints"},
+			},
+		},
+	}
+
+	g.errorContent = spec.ErrorContent
+	if spec.Error == nil {
+		g.schemas["Problem"] = problemSchema()
+		g.errorSchema = &Schema{Ref: "#/components/schemas/Problem"}
+		g.errorContent = problemContent
+	} else {
+		g.errorSchema = g.schema(reflect.TypeOf(spec.Error))
+	}
+
+	var undocumented []string
+	seen := make(map[string]bool)
+
+	err := r.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		tpl, err := route.GetPathTemplate()
+		if err != nil || !strings.HasPrefix(tpl, prefix) {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+
+		for _, method := range methods {
+			key := method + " " + tpl
+			rt, ok := routes[key]
+			if !ok {
+				undocumented = append(undocumented, key)
+				continue
+			}
+			seen[key] = true
+
+			path := docPath(tpl, prefix)
+			if doc.Paths[path] == nil {
+				doc.Paths[path] = make(PathItem)
+			}
+			doc.Paths[path][strings.ToLower(method)] = g.operation(method, path, tpl, rt)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error walking routes: %w", err)
+	}
+
+	for key := range routes {
+		if !seen[key] {
+			undocumented = append(undocumented, key+" (not registered)")
+		}
+	}
+
+	if len(undocumented) > 0 {
+		slices.Sort(undocumented)
+		return doc, fmt.Errorf("routes missing from the openapi table: %s", strings.Join(undocumented, ", "))
+	}
+	return doc, nil
+}
+
+// docPath turns a mux path template into an openapi path below prefix
+func docPath(tpl, prefix string) string {
+	path := pathParam.ReplaceAllString(strings.TrimPrefix(tpl, prefix), "{$1}")
+	if path == "" {
+		return "/"
+	}
+	return path
+}
+
+type generator struct {
+	schemas      map[string]*Schema
+	envelopeType reflect.Type
+	errorSchema  *Schema
+	errorContent string
+}
+
+func (g *generator) operation(method, path, tpl string, rt Route) *Operation {
+	op := &Operation{
+		OperationID: operationID(method, path),
+		Summary:     rt.Summary,
+		Tags:        rt.Tags,
+		Responses:   make(map[string]*Response),
+	}
+
+	if rt.Public {
+		op.Security = []map[string][]string{{}}
+	}
+
+	for _, m := range pathParam.FindAllStringSubmatch(tpl, -1) {
+		schema := &Schema{Type: "string"}
+		if strings.HasSuffix(m[1], "ID") {
+			schema = &Schema{Type: "integer"}
+		}
+		op.Parameters = append(op.Parameters, &Parameter{Name: m[1], In: "path", Required: true, Schema: schema})
+	}
+	op.Parameters = append(op.Parameters, rt.Params...)
+
+	bodies := rt.Bodies
+	if rt.Body != nil {
+		bodies = map[string]any{jsonContent: rt.Body}
+	}
+
+	if len(bodies) > 0 {
+		op.RequestBody = &RequestBody{Required: true, Content: make(map[string]*MediaType)}
+		for contentType, body := range bodies {
+			op.RequestBody.Content[contentType] = &MediaType{Schema: g.schema(reflect.TypeOf(body))}
+		}
+	}
+
+	status := rt.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	var schema *Schema
+	switch {
+	case rt.Response != nil:
+		schema = g.schema(reflect.TypeOf(rt.Response))
+	default:
+		schema = g.envelope(rt.Envelope)
+		if rt.Data != nil {
+			schema.Properties["data"] = g.schema(reflect.TypeOf(rt.Data))
+		}
+	}
+
+	produces := rt.Produces
+	if len(produces) == 0 {
+		produces = []string{jsonContent}
+	}
+	if status == http.StatusNoContent {
+		produces = nil
+	}
+
+	res := &Response{Description: http.StatusText(status), Content: make(map[string]*MediaType)}
+	for _, p := range produces {
+		res.Content[p] = &MediaType{Schema: schema}
+		if alt, ok := rt.Alternates[p]; ok {
+			res.Content[p] = &MediaType{Schema: g.schema(reflect.TypeOf(alt))}
+		}
+	}
+	op.Responses[fmt.Sprint(status)] = res
+	for _, also := range rt.Also {
+		op.Responses[fmt.Sprint(also)] = &Response{Description: http.StatusText(also), Content: res.Content}
+	}
+
+	op.Responses["default"] = &Response{
+		Description: "Error",
+		Content:     map[string]*MediaType{g.errorContent: {Schema: g.errorSchema}},
+	}
+
+	return op
+}
+
+// envelope describes the spec's envelope type holding only the named fields
+func (g *generator) envelope(fields []string) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema), AdditionalProperties: false}
+
+	t := g.envelopeType
+	if t == nil {
+		return s
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _ := jsonName(f)
+		if slices.Contains(fields, name) {
+			s.Properties[name] = g.schema(f.Type)
+		}
+	}
+
+	return s
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schema reflects t, pointers, slices and maps are nullable since nil ones
+// encode as json null
+func (g *generator) schema(t reflect.Type) *Schema {
+	nullable := false
+	for t.Kind() == reflect.Pointer {
+		t, nullable = t.Elem(), true
+	}
+
+	s := g.schemaOf(t)
+	switch t.Kind() {
+	case reflect.Slice, reflect.Map:
+		nullable = true
+	}
+
+	if nullable && (s.Type != "" || s.Ref != "") {
+		c := *s
+		c.Nullable = true
+		return &c
+	}
+	return s
+}
+
+func (g *generator) schemaOf(t reflect.Type) *Schema {
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		// json.RawMessage holds any json value
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: g.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schema(t.Elem())}
+	case reflect.Struct:
+		return g.component(t)
+	}
+
+	return &Schema{}
+}
+
+// component registers a struct under components/schemas and refers to it
+func (g *generator) component(t reflect.Type) *Schema {
+	name := t.Name()
+	if name == "" {
+		return g.object(t)
+	}
+	name = strings.ToUpper(name[:1]) + name[1:]
+
+	if _, ok := g.schemas[name]; !ok {
+		// placeholder so recursive types terminate
+		g.schemas[name] = &Schema{}
+		*g.schemas[name] = *g.object(t)
+	}
+
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+func (g *generator) object(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema), AdditionalProperties: false}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, ok := jsonName(f)
+		if !ok {
+			continue
+		}
+		s.Properties[name] = g.schema(f.Type)
+	}
+
+	return s
+}
+
+func jsonName(f reflect.StructField) (string, bool) {
+	if !f.IsExported() {
+		return "", false
+	}
+
+	tag := f.Tag.Get("json")
+	if tag == "-" {
+		return "", false
+	}
+
+	name, _, _ := strings.Cut(tag, ",")
+	if name == "" {
+		name = f.Name
+	}
+	return name, true
+}
+
+// operationID names an operation after its method and static path segments,
+// GET /astronauts/{astronautID}/history becomes getAstronautsHistory
+func operationID(method, path string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+
+	for _, seg := range strings.FieldsFunc(path, func(r rune) bool { return r == '/' || r == '-' || r == '.' }) {
+		if strings.HasPrefix(seg, "{") {
+			b.WriteString("By")
+			seg = strings.Trim(seg, "{}")
+		}
+		b.WriteString(strings.ToUpper(seg[:1]) + seg[1:])
+	}
+
+	return b.String()
+}
+
+func problemSchema() *Schema {
+	return &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"type":     {Type: "string"},
+			"title":    {Type: "string"},
+			"status":   {Type: "integer"},
+			"detail":   {Type: "string"},
+			"instance": {Type: "string"},
+			"code":     {Type: "string", Description: "Stable error code clients can branch on"},
+			"errors": {Type: "array", Items: &Schema{
+				Type:       "object",
+				Properties: map[string]*Schema{"field": {Type: "string"}, "message": {Type: "string"}},
+			}},
+		},
+	}
+}
@@ -0,0 +1,380 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+	"github.com/LaQuannT/astronaut-data-api/internal/transport/util"
+	"github.com/gorilla/mux"
+)
+
+const contractViolation = "response_contract_violation"
+
+var errInvalidBody = model.NewError(model.KindInvalid, "invalid_body", "request body is not valid json")
+
+// Validator checks requests, and optionally responses, against the
+// operations of a document before the handler sees them
+type Validator struct {
+	doc       *Document
+	prefix    string
+	responses bool
+	log       *slog.Logger
+}
+
+// NewValidator validates requests against doc, validateResponses also
+// replaces responses that drift from the document with a 500, meant for
+// tests
+func NewValidator(doc *Document, validateResponses bool, l *slog.Logger) *Validator {
+	prefix := ""
+	if len(doc.Servers) > 0 {
+		prefix = doc.Servers[0].URL
+	}
+
+	return &Validator{
+		doc:       doc,
+		prefix:    prefix,
+		responses: validateResponses,
+		log:       l,
+	}
+}
+
+func (v *Validator) Middleware(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		op := v.operation(r)
+		if op == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if err := v.validateRequest(r, op); err != nil {
+			v.log.Warn("request failed schema validation", slog.Any("error", err))
+			util.WriteError(w, r, err)
+			return
+		}
+
+		if !v.responses {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		if fields := v.validateResponse(rec, op); len(fields) > 0 {
+			v.log.Error("response does not match the api schema", slog.String("path", r.URL.Path), slog.Int("status", rec.status))
+			err := model.NewError(model.KindInternal, contractViolation, "response does not match the api schema")
+			err.Fields = fields
+			writeViolation(w, r, err)
+			return
+		}
+
+		w.WriteHeader(rec.status)
+		w.Write(rec.body.Bytes())
+	}
+	return http.HandlerFunc(fn)
+}
+
+func (v *Validator) operation(r *http.Request) *Operation {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return nil
+	}
+
+	tpl, err := route.GetPathTemplate()
+	if err != nil {
+		return nil
+	}
+
+	return v.doc.Paths[docPath(tpl, v.prefix)][strings.ToLower(r.Method)]
+}
+
+func (v *Validator) validateRequest(r *http.Request, op *Operation) error {
+	var fields []*model.FieldError
+
+	query := r.URL.Query()
+	for _, p := range op.Parameters {
+		switch p.In {
+		case "path":
+			fields = append(fields, v.validateParam(p, []string{mux.Vars(r)[p.Name]})...)
+		case "query":
+			fields = append(fields, v.validateParam(p, query[p.Name])...)
+		}
+	}
+
+	if op.RequestBody != nil {
+		bodyFields, err := v.validateBody(r, op)
+		if err != nil {
+			return err
+		}
+		fields = append(fields, bodyFields...)
+	}
+
+	if len(fields) > 0 {
+		err := model.NewError(model.KindValidation, "schema_violation", "request does not match the api schema")
+		err.Fields = fields
+		return err
+	}
+	return nil
+}
+
+// validateParam checks the raw values of a path or query parameter, headers
+// are left to the handlers
+func (v *Validator) validateParam(p *Parameter, values []string) []*model.FieldError {
+	var fields []*model.FieldError
+
+	if len(values) == 0 && p.Required {
+		return []*model.FieldError{{Field: p.Name, Message: "is required"}}
+	}
+
+	for _, raw := range values {
+		var value any = raw
+		switch p.Schema.Type {
+		case "integer", "number":
+			value = json.Number(raw)
+		case "boolean":
+			b, err := strconv.ParseBool(raw)
+			if err != nil {
+				fields = append(fields, &model.FieldError{Field: p.Name, Message: "must be a boolean"})
+				continue
+			}
+			value = b
+		}
+		fields = append(fields, v.doc.Validate(p.Schema, value, p.Name, false)...)
+	}
+
+	return fields
+}
+
+// validateBody checks json request bodies and leaves the body readable for
+// the handler, other content types are passed through
+func (v *Validator) validateBody(r *http.Request, op *Operation) ([]*model.FieldError, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	content, ok := op.RequestBody.Content[mediaType]
+	if !ok {
+		content, ok = op.RequestBody.Content[jsonContent]
+		mediaType = jsonContent
+	}
+	if !ok || !isJSON(mediaType) {
+		return nil, nil
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, model.WrapError(model.KindInvalid, "invalid_body", "request body could not be read", err)
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	if len(bytes.TrimSpace(body)) == 0 {
+		if op.RequestBody.Required {
+			return []*model.FieldError{{Field: "body", Message: "is required"}}, nil
+		}
+		return nil, nil
+	}
+
+	value, err := decode(body)
+	if err != nil {
+		return nil, errInvalidBody
+	}
+
+	// merge patches remove members with null
+	nullOK := r.Method == http.MethodPatch && mediaType != "application/json-patch+json"
+
+	return v.doc.Validate(content.Schema, value, "", nullOK), nil
+}
+
+func (v *Validator) validateResponse(rec *responseRecorder, op *Operation) []*model.FieldError {
+	res, ok := op.Responses[strconv.Itoa(rec.status)]
+	if !ok {
+		res, ok = op.Responses["default"]
+	}
+	if !ok || rec.body.Len() == 0 {
+		return nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(rec.Header().Get("Content-Type"))
+	if !isJSON(mediaType) {
+		return nil
+	}
+
+	content, ok := res.Content[mediaType]
+	if !ok {
+		return []*model.FieldError{{Field: "Content-Type", Message: fmt.Sprintf("%s is not documented for status %d", mediaType, rec.status)}}
+	}
+
+	value, err := decode(rec.body.Bytes())
+	if err != nil {
+		return []*model.FieldError{{Field: "body", Message: "is not valid json"}}
+	}
+
+	return v.doc.Validate(content.Schema, value, "", false)
+}
+
+// Validate checks a decoded json value, with numbers as json.Number, against
+// s. nullOK accepts null for every member, as merge patches need.
+func (d *Document) Validate(s *Schema, value any, path string, nullOK bool) []*model.FieldError {
+	field := path
+	if field == "" {
+		field = "body"
+	}
+	fail := func(format string, args ...any) []*model.FieldError {
+		return []*model.FieldError{{Field: field, Message: fmt.Sprintf(format, args...)}}
+	}
+
+	if value == nil {
+		if s.Nullable || nullOK || (s.Type == "" && s.Ref == "") {
+			return nil
+		}
+		return fail("must not be null")
+	}
+
+	if s.Ref != "" {
+		ref, ok := d.Components.Schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+		if !ok {
+			return fail("unknown schema %s", s.Ref)
+		}
+		return d.Validate(ref, value, path, nullOK)
+	}
+
+	switch s.Type {
+	case "object":
+		obj, ok := value.(map[string]any)
+		if !ok {
+			return fail("must be an object")
+		}
+		return d.validateObject(s, obj, path, nullOK)
+
+	case "array":
+		items, ok := value.([]any)
+		if !ok {
+			return fail("must be an array")
+		}
+
+		var fields []*model.FieldError
+		for i, item := range items {
+			if s.Items != nil {
+				fields = append(fields, d.Validate(s.Items, item, fmt.Sprintf("%s[%d]", path, i), nullOK)...)
+			}
+		}
+		return fields
+
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return fail("must be a string")
+		}
+		if len(s.Enum) > 0 && !slices.Contains(s.Enum, str) {
+			return fail("must be one of %s", strings.Join(s.Enum, ", "))
+		}
+		if s.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339, str); err != nil {
+				return fail("must be an RFC 3339 date-time")
+			}
+		}
+
+	case "integer":
+		n, ok := value.(json.Number)
+		if _, err := n.Int64(); !ok || err != nil {
+			return fail("must be an integer")
+		}
+
+	case "number":
+		n, ok := value.(json.Number)
+		if _, err := n.Float64(); !ok || err != nil {
+			return fail("must be a number")
+		}
+
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fail("must be a boolean")
+		}
+	}
+
+	return nil
+}
+
+func (d *Document) validateObject(s *Schema, obj map[string]any, path string, nullOK bool) []*model.FieldError {
+	var fields []*model.FieldError
+
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	for _, k := range keys {
+		member := k
+		if path != "" {
+			member = path + "." + k
+		}
+
+		if prop, ok := s.Properties[k]; ok {
+			fields = append(fields, d.Validate(prop, obj[k], member, nullOK)...)
+			continue
+		}
+
+		switch extra := s.AdditionalProperties.(type) {
+		case bool:
+			if !extra {
+				fields = append(fields, &model.FieldError{Field: member, Message: "is not a known field"})
+			}
+		case *Schema:
+			fields = append(fields, d.Validate(extra, obj[k], member, nullOK)...)
+		}
+	}
+
+	return fields
+}
+
+func decode(data []byte) (any, error) {
+	var value any
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	if err := dec.Decode(&value); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("unexpected data after json value")
+	}
+	return value, nil
+}
+
+func isJSON(mediaType string) bool {
+	return mediaType == jsonContent || strings.HasSuffix(mediaType, "+json")
+}
+
+// responseRecorder holds a response back until it has been validated
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	rec.status = status
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	return rec.body.Write(b)
+}
+
+// writeViolation replaces a held back response, dropping headers that
+// described it
+func writeViolation(w http.ResponseWriter, r *http.Request, err error) {
+	for _, h := range []string{"ETag", "Last-Modified", "Location", "Content-Disposition"} {
+		w.Header().Del(h)
+	}
+	util.WriteError(w, r, err)
+}
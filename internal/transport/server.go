@@ -1,46 +1,308 @@
 package transport
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"log/slog"
 	"net/http"
+	"time"
 
+	"github.com/LaQuannT/astronaut-data-api/internal/auth"
 	"github.com/LaQuannT/astronaut-data-api/internal/model"
 	usecase "github.com/LaQuannT/astronaut-data-api/internal/service"
 	"github.com/LaQuannT/astronaut-data-api/internal/transport/handler"
 	"github.com/LaQuannT/astronaut-data-api/internal/transport/middleware"
+	"github.com/LaQuannT/astronaut-data-api/internal/transport/openapi"
+	"github.com/LaQuannT/astronaut-data-api/internal/transport/util"
 	"github.com/gorilla/mux"
 )
 
+// v1 is served until its sunset, clients are pointed at v2 from the
+// deprecation date
+var (
+	v1Deprecation = time.Date(2026, time.November, 1, 0, 0, 0, 0, time.UTC)
+	v1Sunset      = time.Date(2027, time.May, 1, 0, 0, 0, 0, time.UTC)
+)
+
 type server struct {
-	log            *slog.Logger
-	userStore      model.UserStore
-	astronautStore model.AstronautStore
-	addr           string
+	log               *slog.Logger
+	userStore         model.UserStore
+	astronautStore    model.AstronautStore
+	roleStore         model.RoleStore
+	addr              string
+	validateResponses bool
+	idempotencyStore  model.IdempotencyStore
+	idempotencyTTL    time.Duration
+	tokenStore        model.RefreshTokenStore
+	signer            *auth.Signer
+	refreshTTL        time.Duration
+	rateLimitStore    model.RateLimitStore
+	rateLimits        model.RateLimits
+	metering          bool
+	usageStore        model.UsageStore
+	quotas            model.Quotas
+	usageService      model.UsageUsecase
+	lockout           bool
+	lockoutStore      model.LockoutStore
+	userTokenStore    model.UserTokenStore
+	mailer            model.Mailer
+	publicURL         string
+	resetTTL          time.Duration
+	verifyTTL         time.Duration
+	invitationStore   model.InvitationStore
+	invitationTTL     time.Duration
 }
 
-func NewServer(addr string, us model.UserStore, as model.AstronautStore, l *slog.Logger) *server {
+func NewServer(addr string, us model.UserStore, as model.AstronautStore, rs model.RoleStore, l *slog.Logger) *server {
 	return &server{
 		addr:           addr,
 		userStore:      us,
 		astronautStore: as,
+		roleStore:      rs,
 		log:            l,
 	}
 }
 
+// ValidateResponses makes every response be checked against the openapi
+// document, handlers drifting from it answer 500 instead
+func (s *server) ValidateResponses() *server {
+	s.validateResponses = true
+	return s
+}
+
+// WithIdempotency stores responses to POST requests sent with an
+// Idempotency-Key for ttl, without it the header is ignored
+func (s *server) WithIdempotency(is model.IdempotencyStore, ttl time.Duration) *server {
+	s.idempotencyStore = is
+	s.idempotencyTTL = ttl
+	return s
+}
+
+// WithAuth enables email and password login, access tokens are signed by
+// signer and refresh tokens last refreshTTL. Without it only API keys are
+// accepted.
+func (s *server) WithAuth(ts model.RefreshTokenStore, signer *auth.Signer, refreshTTL time.Duration) *server {
+	s.tokenStore = ts
+	s.signer = signer
+	s.refreshTTL = refreshTTL
+	return s
+}
+
+// WithRateLimit limits requests with token buckets kept in rs, sized by
+// limits. Without it requests are not limited.
+func (s *server) WithRateLimit(rs model.RateLimitStore, limits model.RateLimits) *server {
+	s.rateLimitStore = rs
+	s.rateLimits = limits
+	return s
+}
+
+// WithUsage meters requests per user and endpoint into us and enforces the
+// monthly quotas. Without it requests are neither metered nor limited.
+func (s *server) WithUsage(us model.UsageStore, quotas model.Quotas) *server {
+	s.metering = true
+	s.usageStore = us
+	s.quotas = quotas
+	return s
+}
+
+// WithLockout counts failed logins and API key lookups in ls, slowing down
+// and locking out accounts and client IPs that keep failing. Without it
+// attempts are unlimited.
+func (s *server) WithLockout(ls model.LockoutStore) *server {
+	s.lockout = true
+	s.lockoutStore = ls
+	return s
+}
+
+// WithAccounts enables password resets and email verification with tokens
+// kept in ts and mailed through m, the mails point at publicURL. Without it
+// sign ups are not mailed and passwords are only reset by their user.
+func (s *server) WithAccounts(ts model.UserTokenStore, m model.Mailer, publicURL string, resetTTL, verifyTTL time.Duration) *server {
+	s.userTokenStore = ts
+	s.mailer = m
+	s.publicURL = publicURL
+	s.resetTTL = resetTTL
+	s.verifyTTL = verifyTTL
+	return s
+}
+
+// WithInvitations lets admins invite admins with invitations kept in is for
+// ttl, mailed through the mailer of WithAccounts. Without either admins are
+// only made by changing a user's role.
+func (s *server) WithInvitations(is model.InvitationStore, ttl time.Duration) *server {
+	s.invitationStore = is
+	s.invitationTTL = ttl
+	return s
+}
+
 func (s *server) Serve() {
+	r := s.router()
+
+	if s.idempotencyStore != nil {
+		go s.purge("idempotency keys", usecase.NewIdempotencyUsecase(s.idempotencyStore, s.idempotencyTTL).Purge)
+	}
+	if s.signer != nil {
+		go s.purge("refresh tokens", usecase.NewAuthUsecase(s.userStore, s.tokenStore, s.lockoutStore, s.signer, s.refreshTTL).Purge)
+	}
+	if s.usageService != nil {
+		go s.flushUsage()
+	}
+	if s.mailer != nil {
+		go s.purge("user tokens", s.accountService().Purge)
+	}
+	if s.invitations() {
+		go s.purge("invitations", s.invitationService().Purge)
+	}
+	if s.lockoutStore != nil {
+		go s.purge("failed attempts", usecase.NewLockoutUsecase(s.lockoutStore, s.userStore).Purge)
+	}
+	if s.rateLimitStore != nil {
+		go s.purge("rate limit buckets", usecase.NewRateLimitUsecase(s.rateLimitStore, s.rateLimits).Purge)
+	}
+
+	s.log.Info(fmt.Sprintf("Server listening on '%s'", s.addr))
+	log.Fatal(http.ListenAndServe(s.addr, r))
+}
+
+func (s *server) router() *mux.Router {
 	r := mux.NewRouter()
+	r.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		util.WriteError(w, r, model.NewError(model.KindNotFound, "route_not_found", "no route matches the request path"))
+	})
+	r.MethodNotAllowedHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		util.WriteError(w, r, model.NewError(model.KindMethodNotAllowed, "method_not_allowed", "method is not allowed on this route"))
+	})
 
-	sr := r.PathPrefix("/api/v1").Subrouter()
-	sr.Use(middleware.HTTPLogger(s.log))
+	var authService model.AuthUsecase
+	if s.signer != nil {
+		authService = usecase.NewAuthUsecase(s.userStore, s.tokenStore, s.lockoutStore, s.signer, s.refreshTTL)
+	}
+
+	sr := r.PathPrefix(handler.APIPrefix).Subrouter()
+	sr.Use(middleware.HTTPLogger(s.log), middleware.Deprecated(v1Deprecation, v1Sunset, handler.APIv2Prefix), middleware.ClientIP, middleware.BearerAuthentication(authService, s.log))
+
+	v2 := r.PathPrefix(handler.APIv2Prefix).Subrouter()
+	v2.Use(middleware.HTTPLogger(s.log), handler.V2Errors, middleware.ClientIP, middleware.BearerAuthentication(authService, s.log))
 
-	userService := usecase.NewUserUsecase(s.userStore)
 	astronautService := usecase.NewAstronautUsecase(s.astronautStore, s.userStore)
+	reconcileService := usecase.NewReconcileUsecase(s.astronautStore)
+	qualityService := usecase.NewDataQualityUsecase(s.astronautStore)
+	roleService := usecase.NewRoleUsecase(s.roleStore)
 
-	handler.RegisterUserHandlers(userService, sr, s.log)
-	handler.RegisterAstronautHandlers(astronautService, userService, sr, s.log)
+	var accountService model.AccountUsecase
+	if s.mailer != nil {
+		accountService = s.accountService()
+	}
 
-	s.log.Info(fmt.Sprintf("Server listening on '%s'", s.addr))
-	log.Fatal(http.ListenAndServe(s.addr, r))
+	userService := usecase.NewUserUsecase(s.userStore, s.roleStore, s.lockoutStore, accountService)
+
+	var idempotencyService model.IdempotencyUsecase
+	if s.idempotencyStore != nil {
+		idempotencyService = usecase.NewIdempotencyUsecase(s.idempotencyStore, s.idempotencyTTL)
+	}
+
+	var rateLimitService model.RateLimitUsecase
+	if s.rateLimitStore != nil {
+		rateLimitService = usecase.NewRateLimitUsecase(s.rateLimitStore, s.rateLimits)
+	}
+
+	// the usage service buffers counts until flushUsage writes them, so
+	// every handler shares the one Serve flushes
+	var usageService model.UsageUsecase
+	if s.metering {
+		usageService = usecase.NewUsageUsecase(s.usageStore, s.userStore, s.quotas)
+		s.usageService = usageService
+	}
+
+	handler.RegisterUserHandlers(userService, accountService, rateLimitService, usageService, sr, s.log)
+	handler.RegisterAstronautHandlers(astronautService, userService, idempotencyService, rateLimitService, usageService, sr, s.log)
+	handler.RegisterAdminHandlers(astronautService, reconcileService, qualityService, userService, rateLimitService, usageService, sr, s.log)
+	handler.RegisterRoleHandlers(roleService, userService, rateLimitService, usageService, sr, s.log)
+	if usageService != nil {
+		handler.RegisterUsageHandlers(usageService, userService, rateLimitService, sr, s.log)
+	}
+	if s.lockout {
+		handler.RegisterLockoutHandlers(usecase.NewLockoutUsecase(s.lockoutStore, s.userStore), userService, rateLimitService, usageService, sr, s.log)
+	}
+	handler.RegisterDocsHandlers(handler.V1Spec, r, sr, s.log)
+	if authService != nil {
+		handler.RegisterAuthHandlers(authService, sr, s.log)
+	}
+	if accountService != nil {
+		handler.RegisterAccountHandlers(accountService, rateLimitService, sr, s.log)
+	}
+	if s.invitations() {
+		handler.RegisterInvitationHandlers(s.invitationService(), userService, rateLimitService, usageService, sr, s.log)
+	}
+
+	handler.RegisterV2UserHandlers(userService, accountService, rateLimitService, usageService, v2, s.log)
+	handler.RegisterV2AstronautHandlers(astronautService, userService, idempotencyService, rateLimitService, usageService, v2, s.log)
+	handler.RegisterDocsHandlers(handler.V2Spec, r, v2, s.log)
+
+	s.validate(r, sr, handler.V1Spec)
+	s.validate(r, v2, handler.V2Spec)
+
+	return r
+}
+
+func (s *server) accountService() model.AccountUsecase {
+	return usecase.NewAccountUsecase(s.userStore, s.userTokenStore, s.mailer, s.publicURL+handler.APIPrefix+"/auth", s.resetTTL, s.verifyTTL)
+}
+
+func (s *server) invitations() bool {
+	return s.mailer != nil && s.invitationTTL > 0
+}
+
+func (s *server) invitationService() model.InvitationUsecase {
+	return usecase.NewInvitationUsecase(s.invitationStore, s.userStore, s.roleStore, s.mailer, s.publicURL+handler.APIPrefix+"/auth", s.invitationTTL)
+}
+
+// validate checks requests on sr against the document of spec. The check
+// runs where each handler subrouter adds middleware.Validation, after its
+// authentication and rate limiting, so unauthenticated requests get 401
+// before their body is looked at.
+func (s *server) validate(root, sr *mux.Router, spec openapi.Spec) {
+	doc, err := openapi.Build(root, spec)
+	if err != nil {
+		s.log.Warn("openapi document is incomplete", slog.String("prefix", spec.Prefix), slog.Any("error", err))
+	}
+	if doc != nil {
+		sr.Use(middleware.Validator(openapi.NewValidator(doc, s.validateResponses, s.log).Middleware))
+	}
+}
+
+// purgeInterval is how often expired idempotency keys, refresh and user
+// tokens, invitations, idle rate limit buckets and old failed attempts are
+// deleted
+const purgeInterval = time.Hour
+
+// purge runs fn every purgeInterval, what names the records it deletes
+func (s *server) purge(what string, fn func(context.Context) (int64, error)) {
+	ticker := time.NewTicker(purgeInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		n, err := fn(context.Background())
+		if err != nil {
+			s.log.Error("unable to purge "+what, slog.Any("error", err))
+			continue
+		}
+		s.log.Info("purged expired "+what, slog.Int64("count", n))
+	}
+}
+
+// usageFlushInterval is how often metered requests are written, requests
+// counted since the last flush are lost when the process exits
+const usageFlushInterval = 10 * time.Second
+
+func (s *server) flushUsage() {
+	ticker := time.NewTicker(usageFlushInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := s.usageService.Flush(context.Background()); err != nil {
+			s.log.Error("unable to flush usage", slog.Any("error", err))
+		}
+	}
 }
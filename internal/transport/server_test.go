@@ -0,0 +1,201 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/auth"
+	"github.com/LaQuannT/astronaut-data-api/internal/mail"
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+	"github.com/LaQuannT/astronaut-data-api/internal/transport/handler"
+	"github.com/LaQuannT/astronaut-data-api/internal/transport/openapi"
+)
+
+var testSigner = auth.NewSigner([]byte("test-secret"), "test", time.Minute)
+
+// testUserStore serves the admin with id 1 to bearer tokens
+type testUserStore struct {
+	model.UserStore
+}
+
+func (testUserStore) Get(ctx context.Context, id int) (*model.User, error) {
+	if id != 1 {
+		return nil, model.NewError(model.KindNotFound, "not_found", "user not found")
+	}
+	return &model.User{ID: 1, Role: model.AdminUser, Permissions: model.Permissions}, nil
+}
+
+// testUsageStore has no requests recorded, so no quota is used up
+type testUsageStore struct {
+	model.UsageStore
+}
+
+func (testUsageStore) Requests(ctx context.Context, userID int, from time.Time) (int64, error) {
+	return 0, nil
+}
+
+func testServer() *server {
+	return NewServer("", testUserStore{}, nil, nil, slog.New(slog.NewTextHandler(io.Discard, nil))).
+		ValidateResponses().
+		WithAuth(nil, testSigner, time.Hour).
+		WithUsage(testUsageStore{}, model.Quotas{}).
+		WithLockout(nil).
+		WithAccounts(nil, mail.NewLogMailer(slog.New(slog.NewTextHandler(io.Discard, nil))), "", time.Hour, time.Hour).
+		WithInvitations(nil, time.Hour)
+}
+
+func TestEveryRouteIsDocumented(t *testing.T) {
+	r := testServer().router()
+
+	for _, spec := range []openapi.Spec{handler.V1Spec, handler.V2Spec} {
+		doc, err := openapi.Build(r, spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(doc.Paths) == 0 {
+			t.Fatalf("%s openapi document has no paths", spec.Prefix)
+		}
+	}
+}
+
+func TestRequestValidation(t *testing.T) {
+	token, err := testSigner.Sign(auth.Claims{Subject: "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		// authenticated requests carry a bearer token of the admin
+		authenticated bool
+		status        int
+		fields        []string
+	}{
+		{"unknown and mistyped fields", http.MethodPost, "/api/v1/users", `{"firstName": 5, "nickname": "x"}`, false, http.StatusUnprocessableEntity, []string{"firstName", "nickname"}},
+		{"malformed body", http.MethodPost, "/api/v1/users", `{"firstName"`, false, http.StatusBadRequest, nil},
+		{"path param", http.MethodGet, "/api/v1/astronauts/abc", "", true, http.StatusUnprocessableEntity, []string{"astronautID"}},
+		{"query param", http.MethodGet, "/api/v1/astronauts?limit=ten&format=pdf", "", true, http.StatusUnprocessableEntity, []string{"limit", "format"}},
+		{"login credentials", http.MethodPost, "/api/v1/auth/login", `{"email": 5}`, false, http.StatusUnprocessableEntity, []string{"email"}},
+		{"invitation without token", http.MethodPost, "/api/v1/auth/invitations/accept", `{"firstName": "Ada", "role": "admin"}`, false, http.StatusUnprocessableEntity, []string{"role"}},
+		{"password reset token", http.MethodPost, "/api/v1/auth/password/reset", `{"token": 5, "password": "x"}`, false, http.StatusUnprocessableEntity, []string{"token"}},
+		{"batch operation", http.MethodPost, "/api/v1/astronauts/batch", `{"operations": [{"op": 1}]}`, true, http.StatusUnprocessableEntity, []string{"operations[0].op"}},
+		{"openapi document", http.MethodGet, "/api/v1/openapi.json", "", false, http.StatusOK, nil},
+		{"valid request reaches auth", http.MethodGet, "/api/v1/astronauts?limit=10", "", false, http.StatusUnauthorized, nil},
+		{"v2 unknown field", http.MethodPost, "/api/v2/users", `{"surename": "x"}`, false, http.StatusUnprocessableEntity, []string{"surename"}},
+		{"v2 openapi document", http.MethodGet, "/api/v2/openapi.json", "", false, http.StatusOK, nil},
+		{"v2 valid request reaches auth", http.MethodGet, "/api/v2/astronauts", "", false, http.StatusUnauthorized, nil},
+		{"unauthenticated invalid body", http.MethodPost, "/api/v1/astronauts", `{"name": 5}`, false, http.StatusUnauthorized, nil},
+		{"unauthenticated invalid path param", http.MethodGet, "/api/v1/astronauts/abc", "", false, http.StatusUnauthorized, nil},
+		{"v2 unauthenticated invalid body", http.MethodPost, "/api/v2/astronauts", `{"name": 5}`, false, http.StatusUnauthorized, nil},
+	}
+
+	r := testServer().router()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			if tt.authenticated {
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+
+			var p struct {
+				Errors []struct {
+					Field string `json:"field"`
+				} `json:"errors"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&p); err != nil {
+				t.Fatal(err)
+			}
+
+			var fields []string
+			for _, e := range p.Errors {
+				fields = append(fields, e.Field)
+			}
+			if strings.Join(fields, ",") != strings.Join(tt.fields, ",") {
+				t.Errorf("fields = %v, want %v", fields, tt.fields)
+			}
+		})
+	}
+}
+
+func TestV1IsDeprecated(t *testing.T) {
+	r := testServer().router()
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/openapi.json", nil))
+
+	if got := w.Header().Get("Deprecation"); got == "" {
+		t.Error("v1 response has no Deprecation header")
+	}
+	if got := w.Header().Get("Sunset"); got == "" {
+		t.Error("v1 response has no Sunset header")
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v2/openapi.json", nil))
+
+	if got := w.Header().Get("Deprecation"); got != "" {
+		t.Errorf("v2 response has Deprecation header %q", got)
+	}
+}
+
+func TestBearerAuthentication(t *testing.T) {
+	expired, err := auth.NewSigner([]byte("test-secret"), "test", -time.Minute).Sign(auth.Claims{Subject: "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged, err := auth.NewSigner([]byte("other-secret"), "test", time.Minute).Sign(auth.Claims{Subject: "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		authorization string
+		code          string
+	}{
+		{"not a bearer token", "Basic dXNlcjpwYXNz", "invalid_authorization"},
+		{"malformed token", "Bearer abc", "invalid_token"},
+		{"wrong signature", "Bearer " + forged, "invalid_token"},
+		{"expired token", "Bearer " + expired, "token_expired"},
+	}
+
+	r := testServer().router()
+
+	for _, tt := range tests {
+		for _, target := range []string{"/api/v1/astronauts", "/api/v2/astronauts"} {
+			t.Run(tt.name+" "+target, func(t *testing.T) {
+				req := httptest.NewRequest(http.MethodGet, target, nil)
+				req.Header.Set("Authorization", tt.authorization)
+
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, req)
+
+				if w.Code != http.StatusUnauthorized {
+					t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusUnauthorized, w.Body)
+				}
+				if !strings.Contains(w.Body.String(), `"`+tt.code+`"`) {
+					t.Errorf("body %s does not carry code %q", w.Body, tt.code)
+				}
+			})
+		}
+	}
+}
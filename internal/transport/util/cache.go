@@ -0,0 +1,72 @@
+package util
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// responses depend on the caller's API key, so shared caches must not store
+// them and clients have to revalidate before reuse
+const cacheControl = "private, no-cache"
+
+// ListETag derives a strong entity tag from the parts identifying a list page,
+// typically its query and the id and version of every item, for its
+// mediaType representation
+func ListETag(mediaType string, parts ...any) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s;", mediaType)
+	for _, p := range parts {
+		fmt.Fprintf(h, "%v;", p)
+	}
+	return fmt.Sprintf(`"%s"`, hex.EncodeToString(h.Sum(nil))[:32])
+}
+
+// NotModified sets the caching headers of a GET response and reports whether
+// the client's copy is still fresh, in which case a 304 has been written.
+// If-None-Match takes precedence over If-Modified-Since as RFC 9110 requires.
+func NotModified(w http.ResponseWriter, r *http.Request, etag string, lastModified time.Time) bool {
+	w.Header().Set("Cache-Control", cacheControl)
+	w.Header().Set("ETag", etag)
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		if !etagListMatches(inm, etag) {
+			return false
+		}
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+
+	ims, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil || lastModified.IsZero() {
+		return false
+	}
+
+	if lastModified.Truncate(time.Second).After(ims) {
+		return false
+	}
+
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// etagListMatches applies the weak comparison If-None-Match calls for
+func etagListMatches(header, etag string) bool {
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
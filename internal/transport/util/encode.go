@@ -0,0 +1,345 @@
+package util
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+)
+
+// ResponseTypes are the media types Respond can serve, json is the default
+var ResponseTypes = []string{JSONContentType, XMLContentType, YAMLContentType, CSVContentType}
+
+var ErrNotAcceptable = model.NewError(model.KindNotAcceptable, "not_acceptable", "none of the requested media types can be served")
+
+// Respond writes v in the media type negotiated from the request, or answers
+// 406 when none of ResponseTypes is acceptable
+func Respond(w http.ResponseWriter, r *http.Request, status int, v any) {
+	mediaType := Negotiate(w, r, ResponseTypes...)
+	if mediaType == "" {
+		WriteError(w, r, ErrNotAcceptable)
+		return
+	}
+
+	Write(w, r, mediaType, status, v)
+}
+
+// Write encodes v as mediaType. The xml, yaml and csv forms are built from
+// the json encoding of v so field names and order match across formats.
+func Write(w http.ResponseWriter, r *http.Request, mediaType string, status int, v any) {
+	if mediaType == JSONContentType {
+		WriteJSON(w, status, v)
+		return
+	}
+
+	buf := new(bytes.Buffer)
+	if err := encode(buf, mediaType, v); err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", mediaType)
+	w.WriteHeader(status)
+	w.Write(buf.Bytes())
+}
+
+func encode(buf *bytes.Buffer, mediaType string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("error encoding response: %w", err)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	root, err := decodeNode(dec)
+	if err != nil {
+		return fmt.Errorf("error decoding response: %w", err)
+	}
+
+	switch mediaType {
+	case XMLContentType:
+		return encodeXML(buf, root)
+	case YAMLContentType:
+		encodeYAML(buf, root, 0)
+		return nil
+	case CSVContentType:
+		return encodeCSV(buf, root)
+	}
+	return ErrNotAcceptable
+}
+
+type nodeKind int
+
+const (
+	scalarNode nodeKind = iota
+	objectNode
+	arrayNode
+)
+
+// node is a decoded json value that keeps the order of object keys
+type node struct {
+	kind   nodeKind
+	keys   []string
+	fields []*node
+	items  []*node
+	value  json.Token
+}
+
+func decodeNode(dec *json.Decoder) (*node, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		return &node{kind: scalarNode, value: tok}, nil
+	}
+
+	n := &node{kind: arrayNode}
+	if delim == '{' {
+		n.kind = objectNode
+	}
+
+	for dec.More() {
+		if n.kind == objectNode {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			n.keys = append(n.keys, key.(string))
+		}
+
+		child, err := decodeNode(dec)
+		if err != nil {
+			return nil, err
+		}
+
+		if n.kind == objectNode {
+			n.fields = append(n.fields, child)
+		} else {
+			n.items = append(n.items, child)
+		}
+	}
+
+	// closing delimiter
+	_, err = dec.Token()
+	return n, err
+}
+
+func (n *node) text() string {
+	switch v := n.value.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return ""
+}
+
+// itemName names the elements of an xml list after its singular parent
+func itemName(name string) string {
+	if len(name) > 1 && strings.HasSuffix(name, "s") {
+		return strings.TrimSuffix(name, "s")
+	}
+	return "item"
+}
+
+func encodeXML(buf *bytes.Buffer, root *node) error {
+	buf.WriteString(xml.Header)
+
+	enc := xml.NewEncoder(buf)
+	enc.Indent("", "  ")
+
+	if err := writeXMLNode(enc, "response", root); err != nil {
+		return fmt.Errorf("error encoding xml response: %w", err)
+	}
+	return enc.Flush()
+}
+
+func writeXMLNode(enc *xml.Encoder, name string, n *node) error {
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+
+	switch n.kind {
+	case objectNode:
+		for i, key := range n.keys {
+			if err := writeXMLNode(enc, key, n.fields[i]); err != nil {
+				return err
+			}
+		}
+	case arrayNode:
+		for _, item := range n.items {
+			if err := writeXMLNode(enc, itemName(name), item); err != nil {
+				return err
+			}
+		}
+	default:
+		if err := enc.EncodeToken(xml.CharData(n.text())); err != nil {
+			return err
+		}
+	}
+
+	return enc.EncodeToken(start.End())
+}
+
+var (
+	yamlPlain    = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9 ._/()-]*[A-Za-z0-9._/()-]$|^[A-Za-z]$`)
+	yamlReserved = []string{"y", "n", "yes", "no", "on", "off", "true", "false", "null"}
+)
+
+// yamlScalar writes strings plain when that cannot change their type and
+// double quoted with json escapes otherwise
+func yamlScalar(n *node) string {
+	switch v := n.value.(type) {
+	case nil:
+		return "null"
+	case string:
+		if yamlPlain.MatchString(v) && !containsFold(yamlReserved, v) {
+			return v
+		}
+		return strconv.Quote(v)
+	}
+	return n.text()
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func encodeYAML(buf *bytes.Buffer, n *node, indent int) {
+	pad := strings.Repeat(" ", indent)
+
+	switch {
+	case n.kind == objectNode && len(n.keys) == 0:
+		buf.WriteString(pad + "{}\n")
+	case n.kind == arrayNode && len(n.items) == 0:
+		buf.WriteString(pad + "[]\n")
+	case n.kind == scalarNode:
+		buf.WriteString(pad + yamlScalar(n) + "\n")
+	case n.kind == objectNode:
+		for i, key := range n.keys {
+			child := n.fields[i]
+			buf.WriteString(pad + yamlScalar(&node{value: key}) + ":")
+			if child.kind == scalarNode || len(child.keys)+len(child.items) == 0 {
+				buf.WriteString(" ")
+				encodeYAML(buf, child, 0)
+				continue
+			}
+			buf.WriteString("\n")
+			encodeYAML(buf, child, indent+2)
+		}
+	default:
+		// each item is rendered one level deeper, then its first line's
+		// indent is replaced by the sequence marker
+		for _, item := range n.items {
+			b := new(bytes.Buffer)
+			encodeYAML(b, item, indent+2)
+			buf.WriteString(pad + "- ")
+			buf.Write(b.Bytes()[indent+2:])
+		}
+	}
+}
+
+// encodeCSV writes the single resource or list held by a response as rows,
+// nested objects become dotted columns and lists are joined with ';'
+func encodeCSV(buf *bytes.Buffer, root *node) error {
+	var table *node
+	for _, f := range root.fields {
+		if f.kind == scalarNode {
+			continue
+		}
+		if table != nil {
+			return ErrNotAcceptable
+		}
+		table = f
+	}
+
+	rows := make([]map[string]string, 0)
+	columns := make([]string, 0)
+	seen := make(map[string]bool)
+
+	addRow := func(n *node) {
+		row := make(map[string]string)
+		flatten("", n, row, func(col string) {
+			if !seen[col] {
+				seen[col] = true
+				columns = append(columns, col)
+			}
+		})
+		rows = append(rows, row)
+	}
+
+	switch {
+	case table == nil:
+	case table.kind == objectNode:
+		addRow(table)
+	default:
+		for _, item := range table.items {
+			addRow(item)
+		}
+	}
+
+	cw := csv.NewWriter(buf)
+	if len(columns) > 0 {
+		cw.Write(columns)
+	}
+
+	for _, row := range rows {
+		record := make([]string, len(columns))
+		for i, col := range columns {
+			record[i] = row[col]
+		}
+		cw.Write(record)
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func flatten(prefix string, n *node, row map[string]string, column func(string)) {
+	if n.kind != objectNode {
+		if prefix == "" {
+			prefix = "value"
+		}
+		column(prefix)
+		row[prefix] = cell(n)
+		return
+	}
+
+	for i, key := range n.keys {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		flatten(key, n.fields[i], row, column)
+	}
+}
+
+func cell(n *node) string {
+	if n.kind != arrayNode {
+		return n.text()
+	}
+
+	values := make([]string, 0, len(n.items))
+	for _, item := range n.items {
+		values = append(values, cell(item))
+	}
+	return strings.Join(values, ";")
+}
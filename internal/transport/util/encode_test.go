@@ -0,0 +1,94 @@
+package util
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type testResource struct {
+	ID       int      `json:"id"`
+	Name     string   `json:"name"`
+	Missions []string `json:"missions"`
+	Status   *string  `json:"status"`
+}
+
+type testResponse struct {
+	Items   []testResource `json:"items,omitempty"`
+	Message string         `json:"message,omitempty"`
+}
+
+func TestEncode(t *testing.T) {
+	v := testResponse{Items: []testResource{
+		{ID: 1, Name: "John Glenn", Missions: []string{"mercury 6", "sts-95"}},
+		{ID: 2, Name: "true", Missions: []string{}},
+	}}
+
+	tests := []struct {
+		mediaType string
+		want      string
+	}{
+		{CSVContentType, "id,name,missions,status\n1,John Glenn,mercury 6;sts-95,\n2,true,,\n"},
+		{YAMLContentType, `items:
+  - id: 1
+    name: John Glenn
+    missions:
+      - mercury 6
+      - sts-95
+    status: null
+  - id: 2
+    name: "true"
+    missions: []
+    status: null
+`},
+		{XMLContentType, `<?xml version="1.0" encoding="UTF-8"?>
+<response>
+  <items>
+    <item>
+      <id>1</id>
+      <name>John Glenn</name>
+      <missions>
+        <mission>mercury 6</mission>
+        <mission>sts-95</mission>
+      </missions>
+      <status></status>
+    </item>
+    <item>
+      <id>2</id>
+      <name>true</name>
+      <missions></missions>
+      <status></status>
+    </item>
+  </items>
+</response>`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mediaType, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			if err := encode(buf, tt.mediaType, v); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRespondNotAcceptable(t *testing.T) {
+	for _, target := range []string{"/users?format=pdf", "/users"} {
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		if target == "/users" {
+			r.Header.Set("Accept", "application/pdf")
+		}
+
+		w := httptest.NewRecorder()
+		Respond(w, r, http.StatusOK, testResponse{Message: "ok"})
+
+		if w.Code != http.StatusNotAcceptable {
+			t.Errorf("%s: status = %d, want %d", target, w.Code, http.StatusNotAcceptable)
+		}
+	}
+}
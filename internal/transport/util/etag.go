@@ -0,0 +1,79 @@
+package util
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+)
+
+var (
+	ErrMissingIfMatch   = model.NewError(model.KindPreconditionRequired, "if_match_required", "If-Match header required")
+	ErrInvalidIfMatch   = model.NewError(model.KindInvalid, "invalid_if_match", "If-Match header must be * or a list of entity tags")
+	ErrAmbiguousIfMatch = model.NewError(model.KindInvalid, "ambiguous_if_match", "If-Match header must name a single version")
+)
+
+// ETag formats a resource version as a strong entity tag of its mediaType
+// representation. Each representation gets its own tag, as responses vary by
+// Accept, and json keeps the bare version writes return.
+func ETag(version int, mediaType string) string {
+	if mediaType == JSONContentType {
+		return fmt.Sprintf(`"%d"`, version)
+	}
+	return fmt.Sprintf(`"%d-%s"`, version, formatOf(mediaType))
+}
+
+// SetETag tags the json representation written after a write
+func SetETag(w http.ResponseWriter, version int) {
+	w.Header().Set("ETag", ETag(version, JSONContentType))
+}
+
+// IfMatchVersion returns the resource version a write request expects, "*"
+// matches any version and is returned as 0. A list may hold the tags of
+// several representations as long as they name the same version.
+func IfMatchVersion(r *http.Request) (int, error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" {
+		return 0, ErrMissingIfMatch
+	}
+
+	if header == "*" {
+		return 0, nil
+	}
+
+	version := 0
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+
+		// If-Match uses strong comparison so weak tags never match
+		weak := strings.HasPrefix(tag, "W/")
+		tag = strings.TrimPrefix(tag, "W/")
+
+		if len(tag) < 2 || !strings.HasPrefix(tag, `"`) || !strings.HasSuffix(tag, `"`) || strings.Contains(tag[1:len(tag)-1], `"`) {
+			return 0, ErrInvalidIfMatch
+		}
+		if weak {
+			continue
+		}
+
+		// the tag of any representation names the version it was served at,
+		// a tag this api never served matches no version
+		prefix, _, _ := strings.Cut(tag[1:len(tag)-1], "-")
+		v, err := strconv.Atoi(prefix)
+		if err != nil || v < 1 {
+			continue
+		}
+
+		if version != 0 && version != v {
+			return 0, ErrAmbiguousIfMatch
+		}
+		version = v
+	}
+
+	if version == 0 {
+		return 0, model.ErrVersionConflict
+	}
+	return version, nil
+}
@@ -0,0 +1,50 @@
+package util
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+)
+
+func TestIfMatchVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		version int
+		err     error
+	}{
+		{"missing", "", 0, ErrMissingIfMatch},
+		{"any version", "*", 0, nil},
+		{"json tag", `"3"`, 3, nil},
+		{"csv tag", `"3-csv"`, 3, nil},
+		{"tags of one version", `"3", "3-csv"`, 3, nil},
+		{"weak tag in a list", `W/"2", "3"`, 3, nil},
+		{"weak tag", `W/"3"`, 0, model.ErrVersionConflict},
+		{"tag never served", `"abc"`, 0, model.ErrVersionConflict},
+		{"tags of two versions", `"3", "4"`, 0, ErrAmbiguousIfMatch},
+		{"unquoted", "abc", 0, ErrInvalidIfMatch},
+		{"unterminated", `"3`, 0, ErrInvalidIfMatch},
+		{"empty list entry", `"3",`, 0, ErrInvalidIfMatch},
+		{"any version in a list", `*, "3"`, 0, ErrInvalidIfMatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPut, "/api/v1/astronauts/1", nil)
+			if tt.header != "" {
+				r.Header.Set("If-Match", tt.header)
+			}
+
+			version, err := IfMatchVersion(r)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if version != tt.version {
+				t.Fatalf("version = %d, want %d", version, tt.version)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package util
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// formats maps values of the 'format' query parameter to media types
+var formats = map[string]string{
+	"json":   JSONContentType,
+	"jsonld": JSONLDContentType,
+	"xml":    XMLContentType,
+	"yaml":   YAMLContentType,
+	"csv":    CSVContentType,
+}
+
+// formatOf returns the 'format' query value of a media type, it names the
+// representation in entity tags
+func formatOf(mediaType string) string {
+	for format, mt := range formats {
+		if mt == mediaType {
+			return format
+		}
+	}
+	return mediaType
+}
+
+type acceptRange struct {
+	mediaType string
+	q         float64
+}
+
+// Negotiate picks the offered media type the request's Accept header prefers,
+// earlier offers win ties. A 'format' query parameter overrides the header.
+// The first offer is returned when neither is given and "" when nothing
+// offered is acceptable.
+func Negotiate(w http.ResponseWriter, r *http.Request, offers ...string) string {
+	w.Header().Add("Vary", "Accept")
+
+	if format := r.URL.Query().Get("format"); format != "" {
+		mediaType := formats[strings.ToLower(format)]
+		if slices.Contains(offers, mediaType) {
+			return mediaType
+		}
+		return ""
+	}
+
+	header := r.Header.Get("Accept")
+	if header == "" {
+		return offers[0]
+	}
+
+	ranges := parseAccept(header)
+
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		q := quality(ranges, offer)
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+
+	return best
+}
+
+// BaseURL rebuilds the scheme and host the client used to reach the api
+func BaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+
+	return fmt.Sprintf("%s://%s", scheme, r.Host)
+}
+
+func parseAccept(header string) []acceptRange {
+	var ranges []acceptRange
+
+	for _, part := range strings.Split(header, ",") {
+		mediaType, params, _ := strings.Cut(part, ";")
+		ar := acceptRange{mediaType: strings.ToLower(strings.TrimSpace(mediaType)), q: 1}
+
+		for _, p := range strings.Split(params, ";") {
+			k, v, ok := strings.Cut(strings.TrimSpace(p), "=")
+			if !ok || strings.TrimSpace(k) != "q" {
+				continue
+			}
+			if q, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+				ar.q = q
+			}
+		}
+
+		if ar.mediaType != "" {
+			ranges = append(ranges, ar)
+		}
+	}
+
+	return ranges
+}
+
+// quality returns the q value of the most specific range matching the offer
+func quality(ranges []acceptRange, offer string) float64 {
+	offerType, _, _ := strings.Cut(offer, "/")
+
+	q, specificity := 0.0, -1
+	for _, ar := range ranges {
+		s := -1
+		switch {
+		case ar.mediaType == offer:
+			s = 2
+		case ar.mediaType == offerType+"/*":
+			s = 1
+		case ar.mediaType == "*/*":
+			s = 0
+		}
+
+		if s > specificity {
+			q, specificity = ar.q, s
+		}
+	}
+
+	return q
+}
@@ -0,0 +1,135 @@
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+)
+
+const ProblemContentType = "application/problem+json"
+
+// problem is an RFC 7807 problem details document, code and errors are
+// extension members
+type problem struct {
+	Type     string              `json:"type"`
+	Title    string              `json:"title"`
+	Status   int                 `json:"status"`
+	Detail   string              `json:"detail,omitempty"`
+	Instance string              `json:"instance,omitempty"`
+	Code     string              `json:"code"`
+	Errors   []*model.FieldError `json:"errors,omitempty"`
+}
+
+var kindStatus = map[model.ErrorKind]int{
+	model.KindInvalid:              http.StatusBadRequest,
+	model.KindValidation:           http.StatusUnprocessableEntity,
+	model.KindUnauthenticated:      http.StatusUnauthorized,
+	model.KindForbidden:            http.StatusForbidden,
+	model.KindNotFound:             http.StatusNotFound,
+	model.KindMethodNotAllowed:     http.StatusMethodNotAllowed,
+	model.KindNotAcceptable:        http.StatusNotAcceptable,
+	model.KindConflict:             http.StatusConflict,
+	model.KindPreconditionFailed:   http.StatusPreconditionFailed,
+	model.KindPreconditionRequired: http.StatusPreconditionRequired,
+	model.KindUnsupported:          http.StatusUnsupportedMediaType,
+	model.KindTooManyRequests:      http.StatusTooManyRequests,
+	model.KindTimeout:              http.StatusGatewayTimeout,
+	model.KindUnavailable:          http.StatusServiceUnavailable,
+	model.KindInternal:             http.StatusInternalServerError,
+}
+
+// Status returns the HTTP status code for an error, errors that are not an
+// ApiError are internal
+func Status(err error) int {
+	var apiErr *model.ApiError
+	if !errors.As(err, &apiErr) {
+		return http.StatusInternalServerError
+	}
+
+	if status, ok := kindStatus[apiErr.Kind]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+type errorWriterKey struct{}
+
+// ErrorWriter answers a request with err in the error format of an api
+// version
+type ErrorWriter func(w http.ResponseWriter, r *http.Request, err error)
+
+// WithErrorWriter makes WriteError answer requests with fn, so middleware
+// shared between api versions replies in the format of the version serving
+// the request
+func WithErrorWriter(fn ErrorWriter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), errorWriterKey{}, fn)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// PublicError is the part of an error that is safe to show clients
+type PublicError struct {
+	Status int
+	Code   string
+	Detail string
+	Fields []*model.FieldError
+}
+
+// Public describes err for clients, details of server side failures are not
+// exposed
+func Public(err error) PublicError {
+	pe := PublicError{Status: Status(err), Code: "internal_error"}
+
+	var apiErr *model.ApiError
+	if errors.As(err, &apiErr) {
+		if apiErr.Code != "" {
+			pe.Code = apiErr.Code
+		}
+		pe.Detail = apiErr.Message
+		pe.Fields = apiErr.Fields
+	}
+
+	if pe.Status >= http.StatusInternalServerError && (apiErr == nil || apiErr.Kind == model.KindInternal) {
+		pe.Detail = "an unexpected error occurred"
+	}
+
+	return pe
+}
+
+// WriteError answers a request with the problem details of err, unless the
+// request carries an ErrorWriter
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	var apiErr *model.ApiError
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(apiErr.RetryAfter.Seconds()))))
+	}
+
+	if fn, ok := r.Context().Value(errorWriterKey{}).(ErrorWriter); ok {
+		fn(w, r, err)
+		return
+	}
+
+	pe := Public(err)
+
+	p := problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(pe.Status),
+		Status:   pe.Status,
+		Detail:   pe.Detail,
+		Instance: r.URL.Path,
+		Code:     pe.Code,
+		Errors:   pe.Fields,
+	}
+
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(pe.Status)
+	json.NewEncoder(w).Encode(p)
+}
@@ -0,0 +1,60 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/LaQuannT/astronaut-data-api/internal/model"
+)
+
+func TestWriteError(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+	}{
+		{"not found", fmt.Errorf("fetching: %w", model.NewError(model.KindNotFound, "astronaut_not_found", "astronaut not found")), http.StatusNotFound, "astronaut_not_found"},
+		{"forbidden", model.NewError(model.KindForbidden, "not_authorised", "user is not authorised"), http.StatusForbidden, "not_authorised"},
+		{"conflict", model.NewError(model.KindConflict, "email_taken", "email is already registered"), http.StatusConflict, "email_taken"},
+		{"stale version", model.ErrVersionConflict, http.StatusPreconditionFailed, "version_mismatch"},
+		{"timeout", model.NewError(model.KindTimeout, "database_timeout", "database did not respond in time"), http.StatusGatewayTimeout, "database_timeout"},
+		{"untyped", fmt.Errorf("boom"), http.StatusInternalServerError, "internal_error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			WriteError(w, httptest.NewRequest(http.MethodGet, "/api/v1/astronauts/1", nil), tt.err)
+
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d", w.Code, tt.status)
+			}
+			if ct := w.Header().Get("Content-Type"); ct != ProblemContentType {
+				t.Errorf("content type = %q, want %q", ct, ProblemContentType)
+			}
+
+			var p problem
+			if err := json.NewDecoder(w.Body).Decode(&p); err != nil {
+				t.Fatal(err)
+			}
+			if p.Code != tt.code || p.Status != tt.status || p.Instance != "/api/v1/astronauts/1" {
+				t.Errorf("problem = %+v", p)
+			}
+		})
+	}
+}
+
+func TestWriteErrorHidesInternalDetail(t *testing.T) {
+	w := httptest.NewRecorder()
+	WriteError(w, httptest.NewRequest(http.MethodGet, "/", nil), fmt.Errorf("password=hunter2"))
+
+	var p problem
+	json.NewDecoder(w.Body).Decode(&p)
+	if p.Detail != "an unexpected error occurred" {
+		t.Errorf("detail = %q", p.Detail)
+	}
+}
@@ -1,14 +1,36 @@
 package util
 
 import (
+	"encoding/csv"
 	"encoding/json"
 	"net/http"
 )
 
-const jsonContentType = "application/json"
+const (
+	JSONContentType   = "application/json"
+	JSONLDContentType = "application/ld+json"
+	XMLContentType    = "application/xml"
+	YAMLContentType   = "application/yaml"
+	CSVContentType    = "text/csv"
+)
 
 func WriteJSON(w http.ResponseWriter, status int, v any) {
-	w.Header().Set("Content-Type", jsonContentType)
+	w.Header().Set("Content-Type", JSONContentType)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func WriteJSONLD(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", JSONLDContentType)
 	w.WriteHeader(status)
 	json.NewEncoder(w).Encode(v)
 }
+
+func WriteCSV(w http.ResponseWriter, status int, header []string, records [][]string) {
+	w.Header().Set("Content-Type", CSVContentType)
+	w.WriteHeader(status)
+
+	cw := csv.NewWriter(w)
+	cw.Write(header)
+	cw.WriteAll(records)
+}
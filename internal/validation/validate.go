@@ -39,7 +39,7 @@ func (v *Validator) Validate(data map[string]Check) []error {
 		for _, rk := range check.RuleKey {
 			rule := v.rules[rk]
 			if err := rule(key, check.Value); err != nil {
-				errs = append(errs, err)
+				errs = append(errs, &model.FieldError{Field: key, Message: err.Error()})
 			}
 
 		}
@@ -115,19 +115,6 @@ func Password(min int) Rule {
 	}
 }
 
-func Role(key string, value interface{}) error {
-	str, ok := value.(string)
-	if !ok {
-		return fmt.Errorf("%s is not a string", key)
-	}
-
-	if str == model.AdminUser || str == model.BaseUser {
-		return nil
-	} else {
-		return fmt.Errorf("%s is not a valid role", key)
-	}
-}
-
 func Status(key string, value interface{}) error {
 	str, ok := value.(string)
 	if !ok {